@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/mail"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/dsphub/go-simple-crud-sample/passhash"
+	"github.com/dsphub/go-simple-crud-sample/usertoken"
+)
+
+const (
+	verificationTokenTTL = 48 * time.Hour
+	resetTokenTTL        = time.Hour
+)
+
+// WithAccountMail mails a verification link to the users who register:
+// they can't log in until they followed it. It also enables
+// POST /auth/password-reset, which mails a reset token, and
+// POST /auth/password-reset/confirm, which sets the password given along
+// with the token. The links of the mails point to baseURL.
+func WithAccountMail(mailer mail.Mailer, tokens *usertoken.Signer, baseURL string) ServerOption {
+	return func(p *PostServer) {
+		p.mailer = mailer
+		p.userTokens = tokens
+		p.baseURL = baseURL
+	}
+}
+
+// mailVerification mails the verification link to user. A failure is
+// logged: the user may ask for a password reset, which verifies the email
+// too.
+func (p *PostServer) mailVerification(user User) {
+	link := p.baseURL + "/auth/verify?token=" + url.QueryEscape(p.userTokens.Sign(usertoken.VerifyEmail, user, verificationTokenTTL))
+	err := p.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Welcome! Confirm your email address by opening this link within %v:\n\n%s\n\n"+
+			"If you did not register, you can ignore this mail.\n", verificationTokenTTL, link),
+	})
+	if err != nil {
+		p.log.Error("can't mail verification", "user", user.ID, "err", err)
+	}
+}
+
+// verifyEmail spends the token of the verification link.
+func (p *PostServer) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	user, ok := p.verifyUserToken(w, r, usertoken.VerifyEmail)
+	if !ok {
+		return
+	}
+	if err := p.users.VerifyUser(user.ID); err != nil {
+		p.requestLog(r).Error("can't verify user", "user", user.ID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestPasswordReset mails a reset token to the email of the form. It
+// answers 202 whether the email is registered or not, not to tell which
+// are.
+func (p *PostServer) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	user, err := p.users.GetUserByEmail(p.tenant, NormalizeEmail(r.Form.Get("email")))
+	switch {
+	case err == ErrorUserDoesNotExist:
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case err != nil:
+		p.requestLog(r).Error("can't get user", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	token := p.userTokens.Sign(usertoken.ResetPassword, user, resetTokenTTL)
+	err = p.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Set a new password within %v by posting it along with this token:\n\n%s\n\n"+
+			"to %s/auth/password-reset/confirm. If you did not ask for it, you can ignore this mail.\n", resetTokenTTL, token, p.baseURL),
+	})
+	if err != nil {
+		p.requestLog(r).Error("can't mail password reset", "user", user.ID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resetPassword sets the password of the form for the user of the token,
+// and ends the sessions of the user. Receiving the token proves the email,
+// so it is verified too.
+func (p *PostServer) resetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	password := r.Form.Get("password")
+	if !ValidPassword(password) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	user, ok := p.verifyUserToken(w, r, usertoken.ResetPassword)
+	if !ok {
+		return
+	}
+	hash, err := passhash.Hash(password)
+	if err == nil {
+		err = p.users.SetUserPassword(user.ID, hash)
+	}
+	if err == nil {
+		err = p.users.VerifyUser(user.ID)
+	}
+	if err == nil {
+		err = p.sessions.DeleteUserSessions(user.ID)
+	}
+	if err != nil {
+		p.requestLog(r).Error("can't reset password", "user", user.ID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyUserToken checks the token form value of the request. It answers 422
+// to an invalid, expired or spent token.
+func (p *PostServer) verifyUserToken(w http.ResponseWriter, r *http.Request, purpose usertoken.Purpose) (User, bool) {
+	user, err := p.userTokens.Verify(r.Form.Get("token"), purpose, p.users.GetUserByID)
+	switch {
+	case err == usertoken.ErrorTokenInvalid || err == usertoken.ErrorTokenExpired:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return user, false
+	case err != nil:
+		p.requestLog(r).Error("can't verify token", "purpose", purpose, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return user, false
+	}
+	return user, true
+}
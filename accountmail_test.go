@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"mime/quotedprintable"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/mail"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+	"github.com/dsphub/go-simple-crud-sample/usertoken"
+)
+
+var tokenPattern = regexp.MustCompile(`[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+
+func TestAccountMail(t *testing.T) {
+	smtpServer, err := NewFakeSMTPServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smtpServer.Close()
+	users := NewStubUserStore()
+	mailer := mail.NewSMTPMailer(smtpServer.Addr, "crud@example.com", "", "")
+	server := NewPostServer(std, EmptyInMemoryPostStore(),
+		WithAccounts(users, users, time.Hour),
+		WithAccountMail(mailer, usertoken.NewSigner([]byte("secret")), "https://crud.example.com"))
+
+	serve := func(path string, form url.Values) int {
+		request, _ := http.NewRequest(http.MethodPost, path+"?"+form.Encode(), nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response.Code
+	}
+	login := func(password string) int {
+		return serve("/auth/login", url.Values{"email": {"alice@example.com"}, "password": {password}})
+	}
+
+	assertStatus(t, serve("/auth/register", url.Values{"email": {"alice@example.com"}, "password": {"correct horse"}}), http.StatusCreated)
+
+	t.Run("verify the email", func(t *testing.T) {
+		body := lastMailBody(t, smtpServer, "alice@example.com")
+		if !strings.Contains(body, "https://crud.example.com/auth/verify?token=") {
+			t.Fatalf("got mail %q, want a verification link", body)
+		}
+		token := tokenPattern.FindString(body[strings.Index(body, "token="):])
+		assertStatus(t, login("correct horse"), http.StatusForbidden)
+
+		assertStatus(t, serve("/auth/verify", url.Values{"token": {token}}), http.StatusNoContent)
+		assertStatus(t, serve("/auth/verify", url.Values{"token": {token}}), http.StatusUnprocessableEntity)
+		assertStatus(t, login("correct horse"), http.StatusOK)
+	})
+
+	t.Run("reset the password", func(t *testing.T) {
+		assertStatus(t, serve("/auth/password-reset", url.Values{"email": {"nobody@example.com"}}), http.StatusAccepted)
+		assertStatus(t, serve("/auth/password-reset", url.Values{"email": {"alice@example.com"}}), http.StatusAccepted)
+		if len(smtpServer.Messages()) != 2 {
+			t.Fatalf("got %d mails, unknown emails should get none", len(smtpServer.Messages()))
+		}
+		token := tokenPattern.FindString(lastMailBody(t, smtpServer, "alice@example.com"))
+
+		assertStatus(t, serve("/auth/password-reset/confirm", url.Values{"token": {token}, "password": {"short"}}), http.StatusUnprocessableEntity)
+		assertStatus(t, serve("/auth/password-reset/confirm", url.Values{"token": {token}, "password": {"battery staple"}}), http.StatusNoContent)
+		assertStatus(t, serve("/auth/password-reset/confirm", url.Values{"token": {token}, "password": {"another one"}}), http.StatusUnprocessableEntity)
+
+		assertStatus(t, login("correct horse"), http.StatusUnauthorized)
+		assertStatus(t, login("battery staple"), http.StatusOK)
+		if len(users.Sessions) != 1 {
+			t.Errorf("got %d sessions, the reset should end the sessions before it", len(users.Sessions))
+		}
+	})
+}
+
+// lastMailBody returns the decoded body of the last mail, sent to to.
+func lastMailBody(t *testing.T, server *FakeSMTPServer, to string) string {
+	t.Helper()
+	messages := server.Messages()
+	if len(messages) == 0 {
+		t.Fatal("no mail was sent")
+	}
+	last := messages[len(messages)-1]
+	if len(last.To) != 1 || last.To[0] != to {
+		t.Fatalf("got mail to %v, want %s", last.To, to)
+	}
+	body := last.Data[strings.Index(last.Data, "\r\n\r\n")+4:]
+	decoded, err := ioutil.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(decoded)
+}
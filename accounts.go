@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/authz"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/dsphub/go-simple-crud-sample/passhash"
+	"github.com/dsphub/go-simple-crud-sample/ratelimit"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+const (
+	sessionCookie                = "session"
+	userContextKey    contextKey = "user"
+	defaultSessionTTL            = 14 * 24 * time.Hour
+	// maxLoginFailures failed logins in a row lock the logins of a user
+	// for loginLockout.
+	maxLoginFailures = 5
+	loginLockout     = 15 * time.Minute
+)
+
+// newUserRoles are the roles of the users who register.
+var newUserRoles = []string{string(authz.Author)}
+
+// WithAccounts enables POST /auth/register, /auth/login and /auth/logout.
+// A login sets an HttpOnly session cookie valid for ttl, which
+// authenticates the requests like an API key with the posts:read and
+// posts:write scopes, and admin for the users with the admin role.
+func WithAccounts(users UserStore, sessions SessionStore, ttl time.Duration) ServerOption {
+	return func(p *PostServer) {
+		p.users = users
+		p.sessions = sessions
+		p.sessionTTL = ttl
+		p.loginLimiter = ratelimit.NewMemoryLimiter()
+	}
+}
+
+// loginIPLimit and loginEmailLimit bound the password checks, which are
+// costly on purpose: per client IP, and per email so that an account can't
+// be guessed at from many IPs either.
+var (
+	loginIPLimit    = ratelimit.Limit{Rate: 1, Burst: 10}
+	loginEmailLimit = ratelimit.Limit{Rate: 0.2, Burst: 10}
+)
+
+// allowLogin takes a token from the login buckets of the client IP and of
+// the email in the tenant, with the limiter of WithRateLimits if set so that
+// they are shared by the instances, or else one of the server's own.
+func (p *PostServer) allowLogin(w http.ResponseWriter, r *http.Request, email string) bool {
+	limiter := p.limiter
+	if limiter == nil {
+		limiter = p.loginLimiter
+	}
+	sum := sha256.Sum256([]byte(p.tenant + "\x00" + email))
+	return p.takeToken(w, r, limiter, "ip:"+clientIP(r)+" login", loginIPLimit, false) &&
+		p.takeToken(w, r, limiter, "email:"+hex.EncodeToString(sum[:16])+" login", loginEmailLimit, false)
+}
+
+// requestAccount returns the user whose session the request was
+// authenticated with.
+func requestAccount(r *http.Request) (User, bool) {
+	user, ok := r.Context().Value(userContextKey).(User)
+	return user, ok
+}
+
+func accountID(user User) string {
+	return "user:" + strconv.Itoa(user.ID)
+}
+
+func sessionToken(r *http.Request) string {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// authenticateSession clears the cookie of an unknown or expired session,
+// and then serves the request as an anonymous one if it may be, or answers
+// 401.
+func (p *PostServer) authenticateSession(w http.ResponseWriter, r *http.Request) (*http.Request, []string, bool) {
+	session, err := p.sessions.GetSession(HashAPIKey(sessionToken(r)))
+	var user User
+	if err == nil {
+		user, err = p.users.GetUserByID(session.UserID)
+	}
+	switch {
+	case err == ErrorSessionDoesNotExist || err == ErrorUserDoesNotExist:
+		clearSessionCookie(w)
+		if scope, anonymous := requiredScope(r); anonymous {
+			return r, []string{scope}, true
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		return r, nil, false
+	case err != nil:
+		p.requestLog(r).Error("can't authenticate session", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return r, nil, false
+	}
+	scopes := []string{ScopePostsRead, ScopePostsWrite}
+	if HasScope(user.Roles, string(authz.Admin)) {
+		scopes = append(scopes, ScopeAdmin)
+	}
+	return r.WithContext(context.WithValue(r.Context(), userContextKey, user)), scopes, true
+}
+
+// register creates a user from the email and password of the form, in the
+// tenant of the request.
+func (p *PostServer) register(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	email, password := NormalizeEmail(r.Form.Get("email")), r.Form.Get("password")
+	if !ValidEmail(email) || !ValidPassword(password) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	hash, err := passhash.Hash(password)
+	if err != nil {
+		p.log.Error("can't hash password", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	user, err := p.users.CreateUser(User{Email: email, PasswordHash: hash, Roles: newUserRoles, TenantID: p.tenant})
+	switch {
+	case err == ErrorUserExists:
+		w.WriteHeader(http.StatusConflict)
+		return
+	case err != nil:
+		p.log.Error("can't register user", "email", email, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if p.mailer != nil {
+		p.mailVerification(user)
+	}
+	setResponseContentTypeAsJSON(w)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+// login starts a session for the email and password of the form. Logins
+// are rate limited per client IP and per email, see allowLogin. After
+// maxLoginFailures failures in a row, the logins of the user are refused
+// with 429 for loginLockout, even with the right password. With
+// WithAccountMail, users who didn't verify their email get 403.
+func (p *PostServer) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	email, password := NormalizeEmail(r.Form.Get("email")), r.Form.Get("password")
+	if !p.allowLogin(w, r, email) {
+		return
+	}
+	user, err := p.users.GetUserByEmail(p.tenant, email)
+	now := time.Now()
+	switch {
+	case err == ErrorUserDoesNotExist:
+		// Take as long as for a user, not to tell which emails are
+		// registered.
+		passhash.Check(unknownUserPasswordHash(), password)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	case err != nil:
+		p.requestLog(r).Error("can't get user", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	case user.Locked(now):
+		retryAfter := math.Ceil(user.LockedUntil.Sub(now).Seconds())
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	case !passhash.Check(user.PasswordHash, password):
+		if _, err := p.users.RecordLoginFailure(user.ID, maxLoginFailures, loginLockout); err != nil {
+			p.requestLog(r).Error("can't record login failure", "user", user.ID, "err", err)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	case p.mailer != nil && user.VerifiedAt == nil:
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := p.users.ResetLoginFailures(user.ID); err != nil {
+			p.requestLog(r).Error("can't reset login failures", "user", user.ID, "err", err)
+		}
+	}
+	if token := sessionToken(r); token != "" {
+		p.sessions.DeleteSession(HashAPIKey(token))
+	}
+	token, hash, err := NewSessionToken()
+	if err == nil {
+		err = p.sessions.CreateSession(Session{Hash: hash, UserID: user.ID, ExpiresAt: now.Add(p.sessionTTL)})
+	}
+	if err != nil {
+		p.requestLog(r).Error("can't create session", "user", user.ID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  now.Add(p.sessionTTL),
+		MaxAge:   int(p.sessionTTL.Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	setResponseContentTypeAsJSON(w)
+	json.NewEncoder(w).Encode(user)
+}
+
+// logout ends the session of the request, if any.
+func (p *PostServer) logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if token := sessionToken(r); token != "" {
+		if err := p.sessions.DeleteSession(HashAPIKey(token)); err != nil {
+			p.requestLog(r).Error("can't delete session", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	clearSessionCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+var (
+	unknownUserHash     string
+	unknownUserHashOnce sync.Once
+)
+
+// unknownUserPasswordHash returns the hash of a random password, hashed
+// with the work factor of the users.
+func unknownUserPasswordHash() string {
+	unknownUserHashOnce.Do(func() {
+		token, _, _ := NewSessionToken()
+		unknownUserHash, _ = passhash.Hash(token)
+	})
+	return unknownUserHash
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/authz"
+	"github.com/dsphub/go-simple-crud-sample/passhash"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+func init() {
+	// The production work factor makes every login take a fraction of a
+	// second.
+	passhash.DefaultParams = passhash.Params{Memory: 64, Time: 1, Threads: 1}
+}
+
+func newAccountRequest(path, email, password string) *http.Request {
+	data := url.Values{"email": {email}, "password": {password}}
+	request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s?%s", path, data.Encode()), nil)
+	return request
+}
+
+func TestAccounts(t *testing.T) {
+	users := NewStubUserStore()
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithAccounts(users, users, time.Hour), WithPolicy(authz.DefaultPolicy))
+
+	serve := func(request *http.Request, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("register", func(t *testing.T) {
+		assertStatus(t, serve(newAccountRequest("/auth/register", "Alice@Example.com", "correct horse")).Code, http.StatusCreated)
+		assertStatus(t, serve(newAccountRequest("/auth/register", "alice@example.com", "battery staple")).Code, http.StatusConflict)
+		assertStatus(t, serve(newAccountRequest("/auth/register", "bob@example.com", "short")).Code, http.StatusUnprocessableEntity)
+		assertStatus(t, serve(newAccountRequest("/auth/register", "bob", "long enough")).Code, http.StatusUnprocessableEntity)
+
+		if users.Users[0].Email != "alice@example.com" || !passhash.Check(users.Users[0].PasswordHash, "correct horse") {
+			t.Errorf("got user %+v", users.Users[0])
+		}
+	})
+
+	var session *http.Cookie
+	t.Run("log in", func(t *testing.T) {
+		assertStatus(t, serve(newAccountRequest("/auth/login", "alice@example.com", "wrong password")).Code, http.StatusUnauthorized)
+		assertStatus(t, serve(newAccountRequest("/auth/login", "nobody@example.com", "correct horse")).Code, http.StatusUnauthorized)
+
+		response := serve(newAccountRequest("/auth/login", "ALICE@example.com", "correct horse"))
+
+		assertStatus(t, response.Code, http.StatusOK)
+		cookies := response.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly || !cookies[0].Secure {
+			t.Fatalf("got cookies %v, want a secure HttpOnly session cookie", cookies)
+		}
+		session = cookies[0]
+		if users.Users[0].FailedLogins != 0 {
+			t.Errorf("got %d failed logins, a login should reset them", users.Users[0].FailedLogins)
+		}
+	})
+
+	t.Run("the session authenticates requests", func(t *testing.T) {
+		assertStatus(t, serve(newCreatePostRequest("title", "text")).Code, http.StatusUnauthorized)
+
+		response := serve(newCreatePostRequest("title", "text"), session)
+
+		assertStatus(t, response.Code, http.StatusCreated)
+		if post := getSinglePostFromResponse(t, response.Body); post.AuthorID != "user:1" {
+			t.Errorf("got post %+v, want it by the user", post)
+		}
+	})
+
+	t.Run("log out", func(t *testing.T) {
+		assertStatus(t, serve(newAccountRequest("/auth/logout", "", ""), session).Code, http.StatusNoContent)
+
+		assertStatus(t, serve(newCreatePostRequest("title", "text"), session).Code, http.StatusUnauthorized)
+		response := serve(newGetAllPostsRequest(), session)
+		assertStatus(t, response.Code, http.StatusOK)
+		if cookies := response.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+			t.Errorf("got cookies %v, the stale session cookie should be cleared", cookies)
+		}
+	})
+}
+
+func TestAccountsPerTenant(t *testing.T) {
+	users := NewStubUserStore()
+	server := NewPostServer(std, EmptyInMemoryPostStore(), WithAccounts(users, users, time.Hour), WithTenants(CredentialTenant(HeaderTenant)))
+	passwords := map[string]string{"acme": "acme password", "globex": "globex password"}
+	for tenant, password := range passwords {
+		response := serveForTenant(server, tenant, newAccountRequest("/auth/register", "alice@example.com", password))
+		assertStatus(t, response.Code, http.StatusCreated)
+	}
+
+	t.Run("log in to the account of the tenant", func(t *testing.T) {
+		for tenant, password := range passwords {
+			response := serveForTenant(server, tenant, newAccountRequest("/auth/login", "alice@example.com", password))
+
+			assertStatus(t, response.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("return 401 on the password of another tenant", func(t *testing.T) {
+		response := serveForTenant(server, "acme", newAccountRequest("/auth/login", "alice@example.com", passwords["globex"]))
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+		if user, _ := users.GetUserByEmail("globex", "alice@example.com"); user.FailedLogins != 0 {
+			t.Errorf("got user %+v, the login failed in another tenant", user)
+		}
+	})
+}
+
+func TestLoginRateLimits(t *testing.T) {
+	users := NewStubUserStore()
+	server := NewPostServer(std, EmptyInMemoryPostStore(), WithAccounts(users, users, time.Hour))
+	login := func(remoteAddr, email string) *httptest.ResponseRecorder {
+		request := newAccountRequest("/auth/login", email, "wrong password")
+		request.RemoteAddr = remoteAddr
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("limit the logins of a client IP", func(t *testing.T) {
+		for i := 0; i < loginIPLimit.Burst; i++ {
+			assertStatus(t, login("10.0.0.1:1000", fmt.Sprintf("user%d@example.com", i)).Code, http.StatusUnauthorized)
+		}
+
+		response := login("10.0.0.1:1000", "someone@example.com")
+
+		assertStatus(t, response.Code, http.StatusTooManyRequests)
+		if response.Header().Get("Retry-After") == "" {
+			t.Errorf("got headers %v", response.Header())
+		}
+	})
+
+	t.Run("limit the logins of an email", func(t *testing.T) {
+		for i := 0; i < loginEmailLimit.Burst; i++ {
+			assertStatus(t, login(fmt.Sprintf("10.0.1.%d:1000", i), "bob@example.com").Code, http.StatusUnauthorized)
+		}
+
+		assertStatus(t, login("10.0.2.1:1000", "bob@example.com").Code, http.StatusTooManyRequests)
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	users := NewStubUserStore()
+	server := NewPostServer(std, EmptyInMemoryPostStore(), WithAccounts(users, users, time.Hour))
+	login := func(password string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newAccountRequest("/auth/login", "alice@example.com", password))
+		return response
+	}
+	server.ServeHTTP(httptest.NewRecorder(), newAccountRequest("/auth/register", "alice@example.com", "correct horse"))
+
+	for i := 0; i < maxLoginFailures; i++ {
+		assertStatus(t, login("wrong password").Code, http.StatusUnauthorized)
+	}
+	response := login("correct horse")
+
+	assertStatus(t, response.Code, http.StatusTooManyRequests)
+	if response.Header().Get("Retry-After") != fmt.Sprint(int(loginLockout.Seconds())) {
+		t.Errorf("got headers %v, want Retry-After until the lockout ends", response.Header())
+	}
+
+	past := time.Now().Add(-time.Second)
+	users.Users[0].LockedUntil = &past
+	assertStatus(t, login("correct horse").Code, http.StatusOK)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+const apiKeyContextKey contextKey = "api-key"
+
+// WithAPIKeys requires an API key, given in the X-API-Key header, for
+// every write and for the webhooks and /admin/ endpoints. Reads of posts
+// remain open to anonymous clients.
+func WithAPIKeys(keys APIKeyStore) ServerOption {
+	return func(p *PostServer) {
+		p.apiKeys = keys
+	}
+}
+
+// requestAPIKey returns the API key the request was authenticated with.
+func requestAPIKey(r *http.Request) (APIKey, bool) {
+	key, ok := r.Context().Value(apiKeyContextKey).(APIKey)
+	return key, ok
+}
+
+// authenticateAPIKey answers 401 to the requests with an unknown, expired or
+// revoked key.
+func (p *PostServer) authenticateAPIKey(w http.ResponseWriter, r *http.Request) (*http.Request, []string, bool) {
+	key, err := p.apiKeys.GetAPIKeyByHash(HashAPIKey(r.Header.Get(apiKeyHeader)))
+	switch {
+	case err == ErrorAPIKeyDoesNotExist || (err == nil && !key.Valid(time.Now())):
+		w.WriteHeader(http.StatusUnauthorized)
+		return r, nil, false
+	case err != nil:
+		p.requestLog(r).Error("can't authenticate API key", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return r, nil, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)), key.Scopes, true
+}
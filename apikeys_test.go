@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+func TestAPIKeys(t *testing.T) {
+	keys := &StubAPIKeyStore{}
+	reader := keys.AddKey(DefaultTenant, ScopePostsRead)
+	writer := keys.AddKey(DefaultTenant, ScopePostsRead, ScopePostsWrite)
+	admin := keys.AddKey(DefaultTenant, ScopeAdmin)
+	revoked := keys.AddKey(DefaultTenant, ScopePostsWrite)
+	keys.RevokeAPIKey(4)
+	expired := keys.AddKey(DefaultTenant, ScopePostsWrite)
+	past := time.Now().Add(-time.Minute)
+	keys.Keys[4].ExpiresAt = &past
+
+	store := NewInMemoryPostStore()
+	server := NewPostServer(std, store, WithAPIKeys(keys), WithAudit(store, false))
+
+	serve := func(request *http.Request, key string) int {
+		if key != "" {
+			request.Header.Set(apiKeyHeader, key)
+		}
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	t.Run("anonymous clients can read", func(t *testing.T) {
+		assertStatus(t, serve(newGetAllPostsRequest(), ""), http.StatusOK)
+	})
+
+	t.Run("return 401 on writes without a valid key", func(t *testing.T) {
+		for _, key := range []string{"", "crud_unknown", revoked, expired} {
+			assertStatus(t, serve(newCreatePostRequest("title", "text"), key), http.StatusUnauthorized)
+		}
+		assertStatus(t, serve(newGetAllPostsRequest(), "crud_unknown"), http.StatusUnauthorized)
+	})
+
+	t.Run("return 403 on missing scope", func(t *testing.T) {
+		assertStatus(t, serve(newCreatePostRequest("title", "text"), reader), http.StatusForbidden)
+		assertStatus(t, serve(newGetAllPostsRequest(), admin), http.StatusForbidden)
+
+		request, _ := http.NewRequest(http.MethodGet, "/admin/audit", nil)
+		assertStatus(t, serve(request, writer), http.StatusForbidden)
+	})
+
+	t.Run("serve requests with the scope they need", func(t *testing.T) {
+		assertStatus(t, serve(newCreatePostRequest("title", "text"), writer), http.StatusCreated)
+
+		request, _ := http.NewRequest(http.MethodGet, "/admin/audit", nil)
+		assertStatus(t, serve(request, admin), http.StatusOK)
+		if got := store.Audit[len(store.Audit)-1].Actor; got != "apikey:2" {
+			t.Errorf("got actor %q, want the API key", got)
+		}
+	})
+}
+
+func TestCredentialTenant(t *testing.T) {
+	keys := &StubAPIKeyStore{}
+	acme := keys.AddKey("acme", ScopePostsRead, ScopePostsWrite)
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithAPIKeys(keys), WithTenants(CredentialTenant(HeaderTenant)))
+
+	request := newCreatePostRequest("title", "text")
+	request.Header.Set(apiKeyHeader, acme)
+	response := serveForTenant(server, "globex", request)
+
+	assertStatus(t, response.Code, http.StatusCreated)
+	if post := getSinglePostFromResponse(t, response.Body); post.TenantID != "acme" {
+		t.Errorf("got post %+v, the tenant of the key should win", post)
+	}
+
+	response = serveForTenant(server, "globex", newGetAllPostsRequest())
+	assertPosts(t, []Post{}, getPostsFromResponse(t, response.Body))
+}
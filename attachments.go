@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dsphub/go-simple-crud-sample/blob"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+	"github.com/dsphub/go-simple-crud-sample/thumbnail"
+)
+
+const (
+	attachmentFormField = "file"
+	sniffLength         = 512
+	// multipartOverhead leaves room for the multipart boundaries and the
+	// other form fields around the uploaded file.
+	multipartOverhead = 1 << 20
+)
+
+const (
+	errorAttachmentTooLarge    = PostError("attachment is too large")
+	errorAttachmentUnsupported = PostError("attachment type is not allowed")
+	errorAttachmentIsMissing   = PostError("attachment is missing")
+)
+
+// AttachmentLimits restricts what can be uploaded. AllowedTypes holds media
+// types such as "image/png" or wildcards such as "image/*"; the type is
+// sniffed from the content instead of trusting the client.
+type AttachmentLimits struct {
+	MaxSize      int64
+	AllowedTypes []string
+}
+
+func (l AttachmentLimits) allows(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range l.AllowedTypes {
+		if allowed == mediaType {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, allowed[:len(allowed)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithAttachments enables uploads to /posts/{id}/attachments and downloads
+// from /attachments/{hash}.
+func WithAttachments(attachments AttachmentStore, blobs *blob.Store, limits AttachmentLimits) ServerOption {
+	return func(p *PostServer) {
+		p.attachments = attachments
+		p.blobs = blobs
+		p.attachmentLimits = limits
+	}
+}
+
+func (p *PostServer) postAttachmentsHandler(w http.ResponseWriter, r *http.Request, postID int) {
+	switch r.Method {
+	case http.MethodGet:
+		p.getAttachments(w, r, postID)
+	case http.MethodPost:
+		p.uploadAttachment(w, r, postID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// getAttachments lists the attachments of a post, to the callers who may
+// read it only, see getPostByID.
+func (p *PostServer) getAttachments(w http.ResponseWriter, r *http.Request, postID int) {
+	post, err := p.store.GetPostByID(postID)
+	if err != nil || !p.mayRead(r, post) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	attachments, err := p.attachments.GetAttachments(postID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	setResponseContentTypeAsJSON(w)
+	json.NewEncoder(w).Encode(attachments)
+}
+
+func (p *PostServer) uploadAttachment(w http.ResponseWriter, r *http.Request, postID int) {
+	if _, err := p.store.GetPostByID(postID); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, p.attachmentLimits.MaxSize+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	part, err := nextFilePart(reader)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer part.Close()
+
+	attachment, err := p.storeAttachment(postID, part)
+	switch err {
+	case nil:
+		if p.thumbnails != nil {
+			p.thumbnails.Enqueue(attachment.Hash, attachment.ContentType)
+		}
+		setResponseContentTypeAsJSON(w)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(attachment)
+	case errorAttachmentTooLarge:
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	case errorAttachmentUnsupported:
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+	default:
+		p.log.Error("can't store attachment", "post", postID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func nextFilePart(reader *multipart.Reader) (*multipart.Part, error) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errorAttachmentIsMissing
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == attachmentFormField {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+func (p *PostServer) storeAttachment(postID int, part *multipart.Part) (Attachment, error) {
+	content := bufio.NewReaderSize(&sizeLimitReader{part, p.attachmentLimits.MaxSize}, sniffLength)
+	head, err := content.Peek(sniffLength)
+	if err != nil && err != io.EOF {
+		return Attachment{}, err
+	}
+	contentType := http.DetectContentType(head)
+	if !p.attachmentLimits.allows(contentType) {
+		return Attachment{}, errorAttachmentUnsupported
+	}
+
+	hash, size, err := p.blobs.Put(content)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return p.attachments.CreateAttachment(Attachment{
+		PostID:      postID,
+		Hash:        hash,
+		Name:        attachmentName(part.FileName()),
+		ContentType: contentType,
+		Size:        size,
+	})
+}
+
+func attachmentName(fileName string) string {
+	name := filepath.Base(strings.Replace(fileName, "\\", "/", -1))
+	if name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+// WithThumbnails renders thumbnails of uploaded images and serves them from
+// /attachments/{hash}/thumb/{size}.
+func WithThumbnails(thumbnails *thumbnail.Generator) ServerOption {
+	return func(p *PostServer) {
+		p.thumbnails = thumbnails
+	}
+}
+
+// attachmentsHandler serves attachment content by hash. http.ServeContent
+// takes care of Range and conditional requests.
+func (p *PostServer) attachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	path := strings.Split(r.URL.Path[len("/attachments/"):], "/")
+	switch {
+	case len(path) == 1:
+		p.getAttachmentContent(w, r, path[0])
+	case len(path) == 3 && path[1] == "thumb" && p.thumbnails != nil:
+		size, err := strconv.Atoi(path[2])
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		p.getAttachmentThumbnail(w, r, path[0], size)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (p *PostServer) getAttachmentContent(w http.ResponseWriter, r *http.Request, hash string) {
+	if !blob.ValidHash(hash) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	attachment, err := p.readableAttachment(r, hash)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	content, err := p.blobs.Open(hash)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer content.Close()
+	info, err := content.Stat()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("ETag", `"`+hash+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, attachment.Name, info.ModTime(), content)
+}
+
+// readableAttachment returns the attachment of the content hash to a post the
+// caller may read, or ErrorAttachmentDoesNotExist if there is none: the
+// content of drafts is not served to the others.
+func (p *PostServer) readableAttachment(r *http.Request, hash string) (Attachment, error) {
+	attachments, err := p.attachments.GetAttachmentsByHash(hash)
+	if err != nil {
+		return Attachment{}, err
+	}
+	for _, attachment := range attachments {
+		post, err := p.store.GetPostByID(attachment.PostID)
+		if err == nil && p.mayRead(r, post) {
+			return attachment, nil
+		}
+	}
+	return Attachment{}, ErrorAttachmentDoesNotExist
+}
+
+// getAttachmentThumbnail serves a rendered thumbnail. Thumbnails that are
+// missing, e.g. because the queue was full at upload time, are queued for
+// rendering again.
+func (p *PostServer) getAttachmentThumbnail(w http.ResponseWriter, r *http.Request, hash string, size int) {
+	attachment, err := p.readableAttachment(r, hash)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	content, contentType, err := p.thumbnails.Open(hash, attachment.ContentType, size)
+	if err == thumbnail.ErrorThumbnailDoesNotExist {
+		p.thumbnails.Enqueue(hash, attachment.ContentType)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer content.Close()
+	info, err := content.Stat()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%d"`, hash, size))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", info.ModTime(), content)
+}
+
+// sizeLimitReader fails with errorAttachmentTooLarge once more than
+// remaining bytes have been read.
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *sizeLimitReader) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return 0, errorAttachmentTooLarge
+	}
+	return n, err
+}
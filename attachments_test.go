@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/blob"
+	"github.com/dsphub/go-simple-crud-sample/logging"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+	"github.com/dsphub/go-simple-crud-sample/thumbnail"
+)
+
+var testAttachmentLimits = AttachmentLimits{
+	MaxSize:      1 << 10,
+	AllowedTypes: []string{"text/plain", "image/*"},
+}
+
+func TestUploadAttachment(t *testing.T) {
+	t.Run("store identical uploads once", func(t *testing.T) {
+		store := NewInMemoryPostStore()
+		server, cleanup := newAttachmentServer(t, store)
+		defer cleanup()
+
+		first := httptest.NewRecorder()
+		server.ServeHTTP(first, newUploadAttachmentRequest(1, "hello.txt", []byte("hello world")))
+		second := httptest.NewRecorder()
+		server.ServeHTTP(second, newUploadAttachmentRequest(1, "copy.txt", []byte("hello world")))
+
+		assertStatus(t, first.Code, http.StatusCreated)
+		assertStatus(t, second.Code, http.StatusCreated)
+		a, b := getAttachmentFromResponse(t, first), getAttachmentFromResponse(t, second)
+		if a.Hash != b.Hash || a.ID == b.ID {
+			t.Errorf("expected two attachments sharing one blob, got %v and %v", a, b)
+		}
+		if a.Name != "hello.txt" || a.ContentType != "text/plain; charset=utf-8" || a.Size != 11 {
+			t.Errorf("unexpected attachment %v", a)
+		}
+	})
+
+	t.Run("return 404 on missing post", func(t *testing.T) {
+		server, cleanup := newAttachmentServer(t, EmptyInMemoryPostStore())
+		defer cleanup()
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newUploadAttachmentRequest(1, "hello.txt", []byte("hello world")))
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("return 413 on too large attachment", func(t *testing.T) {
+		store := NewInMemoryPostStore()
+		server, cleanup := newAttachmentServer(t, store)
+		defer cleanup()
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newUploadAttachmentRequest(1, "big.txt", bytes.Repeat([]byte("a"), 2<<10)))
+
+		assertStatus(t, response.Code, http.StatusRequestEntityTooLarge)
+		assertPostCount(t, 0, len(store.Attachments))
+	})
+
+	t.Run("return 415 on disallowed type", func(t *testing.T) {
+		store := NewInMemoryPostStore()
+		server, cleanup := newAttachmentServer(t, store)
+		defer cleanup()
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newUploadAttachmentRequest(1, "doc.pdf", []byte("%PDF-1.4 dummy")))
+
+		assertStatus(t, response.Code, http.StatusUnsupportedMediaType)
+	})
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	store := NewInMemoryPostStore()
+	server, cleanup := newAttachmentServer(t, store)
+	defer cleanup()
+	upload := httptest.NewRecorder()
+	server.ServeHTTP(upload, newUploadAttachmentRequest(1, "hello.txt", []byte("hello world")))
+	attachment := getAttachmentFromResponse(t, upload)
+
+	t.Run("return the whole content", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/attachments/"+attachment.Hash, nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertResponseBody(t, "hello world", response.Body.String())
+	})
+
+	t.Run("return the requested range", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/attachments/"+attachment.Hash, nil)
+		request.Header.Set("Range", "bytes=6-")
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusPartialContent)
+		assertResponseBody(t, "world", response.Body.String())
+	})
+
+	t.Run("list the post attachments", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/posts/1/attachments", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		var got []Attachment
+		json.NewDecoder(response.Body).Decode(&got)
+		assertStatus(t, response.Code, http.StatusOK)
+		if len(got) != 1 || got[0].Hash != attachment.Hash {
+			t.Errorf("unexpected attachments %v", got)
+		}
+	})
+
+	t.Run("return 404 on unknown hash", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/attachments/unknown", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+}
+
+func TestDraftAttachments(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	draft, _ := store.CreatePost(Post{Title: "draft", Content: "text", Status: StatusDraft})
+	server, cleanup := newAttachmentServer(t, store)
+	defer cleanup()
+	upload := httptest.NewRecorder()
+	server.ServeHTTP(upload, newUploadAttachmentRequest(draft.ID, "secret.txt", []byte("secret")))
+	attachment := getAttachmentFromResponse(t, upload)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(http.MethodGet, path, nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("return 404 on the attachments of a draft", func(t *testing.T) {
+		response := get(fmt.Sprintf("/posts/%d/attachments", draft.ID))
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("return 404 on the content of a draft", func(t *testing.T) {
+		response := get("/attachments/" + attachment.Hash)
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+
+	t.Run("serve the content attached to a published post too", func(t *testing.T) {
+		published, _ := store.CreatePost(Post{Title: "published", Content: "text", Status: StatusPublished})
+		server.ServeHTTP(httptest.NewRecorder(), newUploadAttachmentRequest(published.ID, "public.txt", []byte("secret")))
+
+		response := get("/attachments/" + attachment.Hash)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if got := response.Header().Get("Content-Disposition"); got != `inline; filename=public.txt` {
+			t.Errorf("got content disposition %q, want the published attachment", got)
+		}
+	})
+}
+
+func TestAttachmentThumbnail(t *testing.T) {
+	store := NewInMemoryPostStore()
+	server, cleanup := newAttachmentServer(t, store)
+	defer cleanup()
+	content := new(bytes.Buffer)
+	png.Encode(content, image.NewNRGBA(image.Rect(0, 0, 64, 32)))
+	upload := httptest.NewRecorder()
+	server.ServeHTTP(upload, newUploadAttachmentRequest(1, "image.png", content.Bytes()))
+	attachment := getAttachmentFromResponse(t, upload)
+	server.thumbnails.Start()
+	defer server.thumbnails.Stop()
+
+	t.Run("return the rendered thumbnail", func(t *testing.T) {
+		var response *httptest.ResponseRecorder
+		for i := 0; i < 100; i++ {
+			request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/attachments/%s/thumb/16", attachment.Hash), nil)
+			response = httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+			if response.Code == http.StatusOK {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		assertStatus(t, response.Code, http.StatusOK)
+		thumb, err := png.Decode(response.Body)
+		if err != nil {
+			t.Fatalf("Unable to decode thumbnail, '%v'", err)
+		}
+		if thumb.Bounds() != image.Rect(0, 0, 16, 8) {
+			t.Errorf("unexpected thumbnail bounds %v", thumb.Bounds())
+		}
+	})
+
+	t.Run("return 404 on unknown size", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/attachments/%s/thumb/17", attachment.Hash), nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+}
+
+func newAttachmentServer(t *testing.T, store *StubPostStore) (*PostServer, func()) {
+	dir, err := ioutil.TempDir("", "attachments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := blob.NewStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbnails, err := thumbnail.NewGenerator(std.Std(logging.Info), blobs, filepath.Join(dir, "thumbs"), []int{16}, 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewPostServer(std, store,
+		WithAttachments(store, blobs, testAttachmentLimits),
+		WithThumbnails(thumbnails))
+	return server, func() { os.RemoveAll(dir) }
+}
+
+func newUploadAttachmentRequest(postID int, fileName string, content []byte) *http.Request {
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	file, _ := form.CreateFormFile(attachmentFormField, fileName)
+	file.Write(content)
+	form.Close()
+
+	request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/posts/%d/attachments", postID), body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	return request
+}
+
+func getAttachmentFromResponse(t *testing.T, response *httptest.ResponseRecorder) (attachment Attachment) {
+	t.Helper()
+	if err := json.NewDecoder(response.Body).Decode(&attachment); err != nil {
+		t.Fatalf("Unable to parse response from server into Attachment, '%v'", err)
+	}
+	return
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// WithAudit records an audit entry for every post created, updated or
+// deleted through the server, in the transaction of the change, and enables
+// GET /admin/audit to list them to admins, if the server authenticates its
+// callers. Updates taking a post out of the archive are audited as
+// restores. With chain set, the entries are hash chained, see the
+// audit-verify command. The post store must implement AuditScoper.
+func WithAudit(audit AuditLog, chain bool) ServerOption {
+	return func(p *PostServer) {
+		if _, ok := p.store.(AuditScoper); !ok {
+			panic(fmt.Sprintf("%T can't audit its changes", p.store))
+		}
+		p.audit = audit
+		p.auditChain = chain
+	}
+}
+
+// anonymousActor is the actor of the audit entries of anonymous requests.
+const anonymousActor = "anonymous"
+
+// requestAuditEntry returns the audit entry of the changes the request
+// makes, but for their action and post, see AuditScoper.
+func requestAuditEntry(r *http.Request) AuditEntry {
+	actor := requestActor(r)
+	if actor == "" {
+		actor = anonymousActor
+	}
+	return AuditEntry{
+		Actor:     actor,
+		IP:        clientIP(r),
+		RequestID: requestID(r),
+	}
+}
+
+// requestActor identifies who made the request by its verified credential:
+// the API key, the subject of the bearer token with its issuer, e.g.
+// jwt:https://gateway#alice, or the user of the session. It is empty for
+// anonymous requests.
+func requestActor(r *http.Request) string {
+	if key, ok := requestAPIKey(r); ok {
+		return "apikey:" + strconv.Itoa(key.ID)
+	}
+	if claims, ok := requestClaims(r); ok {
+		return "jwt:" + claims.Issuer + "#" + claims.Subject
+	}
+	if user, ok := requestAccount(r); ok {
+		return accountID(user)
+	}
+	return ""
+}
+
+// clientIP returns the address of the peer. Forwarding headers are not
+// trusted.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// getAuditEntries lists the audit entries, e.g.
+// /admin/audit?post_id=1&actor=alice&action=post.deleted&since=2019-10-01T00:00:00Z.
+// Clients page with after_id, the ID of the last entry they got.
+func (p *PostServer) getAuditEntries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	entries, err := p.audit.GetAuditEntries(filter)
+	if err != nil {
+		p.log.Error("can't get audit entries", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+	setResponseContentTypeAsJSON(w)
+	json.NewEncoder(w).Encode(entries)
+}
+
+func parseAuditFilter(query url.Values) (AuditFilter, error) {
+	filter := AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Limit:  defaultAuditLimit,
+	}
+	var err error
+	if value := query.Get("post_id"); value != "" {
+		if filter.PostID, err = strconv.Atoi(value); err != nil {
+			return filter, err
+		}
+	}
+	if value := query.Get("after_id"); value != "" {
+		if filter.AfterID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return filter, err
+		}
+	}
+	if value := query.Get("since"); value != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, err
+		}
+	}
+	if value := query.Get("until"); value != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, err
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			return filter, err
+		}
+		if filter.Limit <= 0 || filter.Limit > maxAuditLimit {
+			return filter, errors.Errorf("invalid limit %d", filter.Limit)
+		}
+	}
+	return filter, nil
+}
+
+// verifyAuditChain checks the hash chain of every tenant, reading the whole
+// log in ID order. It returns the number of chained entries checked.
+func verifyAuditChain(audit AuditLog) (int, error) {
+	heads := make(map[string]string)
+	var checked int
+	filter := AuditFilter{Limit: maxAuditLimit}
+	for {
+		entries, err := audit.GetAuditEntries(filter)
+		if err != nil {
+			return checked, err
+		}
+		for _, e := range entries {
+			filter.AfterID = e.ID
+			if e.Hash == "" {
+				continue
+			}
+			if e.PrevHash != heads[e.TenantID] {
+				return checked, errors.Errorf("audit entry %d: chain broken, an entry before it was removed or altered", e.ID)
+			}
+			if e.ChainHash() != e.Hash {
+				return checked, errors.Errorf("audit entry %d: hash mismatch, the entry was altered", e.ID)
+			}
+			heads[e.TenantID] = e.Hash
+			checked++
+		}
+		if len(entries) < filter.Limit {
+			return checked, nil
+		}
+	}
+}
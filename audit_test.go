@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+func TestAuditLog(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithJWT(newTestVerifier()), WithAudit(store, true))
+	alice := "Bearer " + newToken("alice", ScopePostsWrite, nil)
+	bob := "Bearer " + newToken("bob", ScopePostsWrite, nil)
+
+	request := newCreatePostRequest("title", "text")
+	request.Header.Set("Authorization", alice)
+	request.Header.Set(requestIDHeader, "req-1")
+	request.RemoteAddr = "10.0.0.1:4321"
+	server.ServeHTTP(httptest.NewRecorder(), request)
+	for _, request := range []*http.Request{newUpdatePostRequest(1, "new title", "new text"), newDeletePostRequest(1), newDeletePostRequest(1)} {
+		request.Header.Set("Authorization", bob)
+		server.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	t.Run("record every change", func(t *testing.T) {
+		entries := getAuditEntries(t, server, "")
+
+		if len(entries) != 3 {
+			t.Fatalf("got entries %+v, the failed delete should not be audited", entries)
+		}
+		first := entries[0]
+		if first.Action != EventPostCreated || first.PostID != 1 || first.Actor != tokenActor("alice") || first.IP != "10.0.0.1" || first.RequestID != "req-1" {
+			t.Errorf("got entry %+v", first)
+		}
+		if entries[1].Action != EventPostUpdated || entries[2].Action != EventPostDeleted {
+			t.Errorf("got entries %+v", entries)
+		}
+	})
+
+	t.Run("filter entries", func(t *testing.T) {
+		entries := getAuditEntries(t, server, "?actor="+url.QueryEscape(tokenActor("alice")))
+		if len(entries) != 1 {
+			t.Errorf("got entries %+v", entries)
+		}
+
+		entries = getAuditEntries(t, server, "?action=post.deleted&after_id=1")
+		if len(entries) != 1 || entries[0].ID != 3 {
+			t.Errorf("got entries %+v", entries)
+		}
+	})
+
+	t.Run("return 403 to non admins", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/admin/audit", nil)
+		request.Header.Set("Authorization", alice)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusForbidden)
+	})
+
+	t.Run("return 422 on invalid filter", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/admin/audit?since=yesterday", nil)
+		request.Header.Set("Authorization", "Bearer "+newToken("root", ScopeAdmin, nil))
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("verify the chain", func(t *testing.T) {
+		count, err := verifyAuditChain(store)
+		if err != nil || count != 3 {
+			t.Fatalf("got %d checked entries, error %v", count, err)
+		}
+
+		store.Audit[1].Actor = "mallory"
+		if _, err := verifyAuditChain(store); err == nil {
+			t.Error("an altered entry should break the chain")
+		}
+
+		store.Audit = append(store.Audit[:1], store.Audit[2:]...)
+		if _, err := verifyAuditChain(store); err == nil {
+			t.Error("a removed entry should break the chain")
+		}
+	})
+}
+
+func TestAuditRestores(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	store.Counter = 1
+	store.Posts[1] = Post{ID: 1, Title: "title", Content: "text", Status: StatusArchived}
+	server := NewPostServer(std, store, WithJWT(newTestVerifier()), WithAudit(store, false))
+	request, _ := http.NewRequest(http.MethodPut, "/posts/1?status=draft", nil)
+	request.Header.Set("Authorization", "Bearer "+newToken("alice", ScopePostsWrite, nil))
+	response := httptest.NewRecorder()
+
+	server.ServeHTTP(response, request)
+
+	assertStatus(t, response.Code, http.StatusOK)
+	if len(store.Audit) != 1 || store.Audit[0].Action != AuditPostRestored || store.Audit[0].Actor != tokenActor("alice") {
+		t.Errorf("got entries %+v", store.Audit)
+	}
+}
+
+func TestAuditAnonymousChanges(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithAudit(store, false))
+	request := newCreatePostRequest("title", "text")
+	request.Header.Set(userHeader, "mallory")
+	response := httptest.NewRecorder()
+
+	server.ServeHTTP(response, request)
+
+	assertStatus(t, response.Code, http.StatusCreated)
+	if len(store.Audit) != 1 || store.Audit[0].Actor != anonymousActor {
+		t.Errorf("got entries %+v, want one by %s", store.Audit, anonymousActor)
+	}
+}
+
+// failingAuditStore can't append audit entries.
+type failingAuditStore struct {
+	*StubPostStore
+}
+
+func (s failingAuditStore) AppendAudit(entry AuditEntry, chain bool) (AuditEntry, error) {
+	return entry, errors.New("disk full")
+}
+
+func (s failingAuditStore) ForAudit(entry AuditEntry, chain bool) AuditScoper {
+	return &StubAuditedPostStore{PostStore: s, Log: s, Entry: entry, Chain: chain}
+}
+
+func TestAuditFailureFailsTheRequest(t *testing.T) {
+	store := failingAuditStore{NewInMemoryPostStore()}
+	server := NewPostServer(std, store, WithJWT(newTestVerifier()), WithAudit(store, false))
+	request := newDeletePostRequest(1)
+	request.Header.Set("Authorization", "Bearer "+newToken("alice", ScopePostsWrite, nil))
+	response := httptest.NewRecorder()
+
+	server.ServeHTTP(response, request)
+
+	assertStatus(t, response.Code, http.StatusInternalServerError)
+}
+
+func TestAuditLogNotServedWithoutAuthentication(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithAudit(store, false))
+	request, _ := http.NewRequest(http.MethodGet, "/admin/audit", nil)
+	response := httptest.NewRecorder()
+
+	server.ServeHTTP(response, request)
+
+	assertStatus(t, response.Code, http.StatusNotFound)
+}
+
+func getAuditEntries(t *testing.T, server http.Handler, query string) (entries []AuditEntry) {
+	t.Helper()
+	request, _ := http.NewRequest(http.MethodGet, "/admin/audit"+query, nil)
+	request.Header.Set("Authorization", "Bearer "+newToken("root", ScopeAdmin, nil))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	assertStatus(t, response.Code, http.StatusOK)
+	if err := json.NewDecoder(response.Body).Decode(&entries); err != nil {
+		t.Fatalf("Unable to parse response from server %q, '%v'", response.Body, err)
+	}
+	return entries
+}
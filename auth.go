@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/dsphub/go-simple-crud-sample/authz"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+type contextKey string
+
+const scopesContextKey contextKey = "scopes"
+
+// requiredScope returns the scope a request needs and whether anonymous
+// clients may make it. The /auth/ endpoints need none: they authenticate
+// the clients themselves.
+func requiredScope(r *http.Request) (string, bool) {
+	if strings.HasPrefix(r.URL.Path, "/auth/") {
+		return "", true
+	}
+	if strings.HasPrefix(r.URL.Path, "/admin/") || strings.HasPrefix(r.URL.Path, "/webhooks/") {
+		return ScopeAdmin, false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ScopePostsRead, true
+	}
+	return ScopePostsWrite, false
+}
+
+// authenticator checks the credential of a request. It answers the request
+// itself when the credential is not valid, otherwise it returns the request
+// with the credential in its context and the scopes the credential grants.
+type authenticator func(w http.ResponseWriter, r *http.Request) (*http.Request, []string, bool)
+
+// authenticated answers 401 to the requests with an invalid credential and
+// to the anonymous requests that need one, and 403 to the requests whose
+// credential lacks the scope they need. A request is authenticated with a
+// bearer token if WithJWT is set, with an API key if WithAPIKeys is, or
+// with a session cookie if WithAccounts is.
+func (p *PostServer) authenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope, anonymous := requiredScope(r)
+		var authenticate authenticator
+		switch {
+		case scope == "":
+			next.ServeHTTP(w, r)
+			return
+		case p.jwt != nil && bearerToken(r) != "":
+			authenticate = p.authenticateBearer
+		case p.apiKeys != nil && r.Header.Get(apiKeyHeader) != "":
+			authenticate = p.authenticateAPIKey
+		case p.sessions != nil && sessionToken(r) != "":
+			authenticate = p.authenticateSession
+		case anonymous:
+			next.ServeHTTP(w, r)
+			return
+		default:
+			if p.jwt != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if !p.allowCredentialAttempt(w, r) {
+			return
+		}
+		r, scopes, ok := authenticate(w, r)
+		if !ok {
+			return
+		}
+		if !HasScope(scopes, scope) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scopesContextKey, scopes)))
+	})
+}
+
+// authenticates returns whether the server verifies credentials, see
+// authenticated. The admin routes are not served otherwise.
+func (p *PostServer) authenticates() bool {
+	return p.apiKeys != nil || p.jwt != nil || p.sessions != nil
+}
+
+// adminOnly answers 403 unless the verified credential of the request grants
+// the admin scope and, with a policy, its caller holds the admin role.
+func (p *PostServer) adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !HasScope(requestScopes(r), ScopeAdmin) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if p.policy != nil {
+			if subject := requestSubject(r, p.policy); subject.ID == "" || !subject.HasRole(authz.Admin) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestScopes returns the scopes granted by the verified credential of the
+// request, none for anonymous requests.
+func requestScopes(r *http.Request) []string {
+	scopes, _ := r.Context().Value(scopesContextKey).([]string)
+	return scopes
+}
+
+// CredentialTenant resolves the tenant of the API key, the bearer token or
+// the session of the request, or of anonymous requests with fallback if it
+// is not nil.
+func CredentialTenant(fallback TenantResolver) TenantResolver {
+	return func(r *http.Request) (string, error) {
+		if key, ok := requestAPIKey(r); ok {
+			return key.TenantID, nil
+		}
+		if claims, ok := requestClaims(r); ok {
+			return claims.String(tenantClaim), nil
+		}
+		if user, ok := requestAccount(r); ok {
+			return user.TenantID, nil
+		}
+		if fallback == nil {
+			return "", ErrorTenantMissing
+		}
+		return fallback(r)
+	}
+}
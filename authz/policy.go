@@ -0,0 +1,167 @@
+// Package authz decides which roles may perform which operations on posts.
+package authz
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+type Role string
+
+const (
+	Reader Role = "reader"
+	Author Role = "author"
+	Editor Role = "editor"
+	Admin  Role = "admin"
+)
+
+var Roles = []Role{Reader, Author, Editor, Admin}
+
+type Operation string
+
+const (
+	ReadPosts    Operation = "posts.read"
+	CreatePost   Operation = "posts.create"
+	UpdatePost   Operation = "posts.update"
+	DeletePost   Operation = "posts.delete"
+	ReactToPost  Operation = "posts.react"
+	AttachToPost Operation = "posts.attach"
+)
+
+var Operations = []Operation{ReadPosts, CreatePost, UpdatePost, DeletePost, ReactToPost, AttachToPost}
+
+// Rule lists the roles allowed an operation on any post, and the roles
+// allowed it on their own posts only.
+type Rule struct {
+	Any []Role `json:"any"`
+	Own []Role `json:"own"`
+}
+
+// Policy maps every operation to its rule. Operations without a rule are
+// denied to everyone.
+type Policy struct {
+	// Anonymous are the roles of the callers that are not authenticated.
+	Anonymous  []Role             `json:"anonymous"`
+	Operations map[Operation]Rule `json:"operations"`
+}
+
+// DefaultPolicy lets everyone read and react, authors write their own
+// posts, editors and admins every post.
+var DefaultPolicy = Policy{
+	Anonymous: []Role{Reader},
+	Operations: map[Operation]Rule{
+		ReadPosts:    {Any: []Role{Reader, Author, Editor, Admin}},
+		CreatePost:   {Any: []Role{Author, Editor, Admin}},
+		UpdatePost:   {Any: []Role{Editor, Admin}, Own: []Role{Author}},
+		DeletePost:   {Any: []Role{Editor, Admin}, Own: []Role{Author}},
+		ReactToPost:  {Any: []Role{Reader, Author, Editor, Admin}},
+		AttachToPost: {Any: []Role{Editor, Admin}, Own: []Role{Author}},
+	},
+}
+
+// Subject is the caller an operation is authorized for. ID is what the
+// posts it creates are attributed to, empty for anonymous callers.
+type Subject struct {
+	ID    string
+	Roles []Role
+}
+
+// HasRole returns whether the subject holds role.
+func (s Subject) HasRole(role Role) bool {
+	return hasAnyRole(s.Roles, []Role{role})
+}
+
+type Decision int
+
+const (
+	Deny Decision = iota
+	Allow
+	// AllowOwner allows the operation on the posts of the subject only.
+	AllowOwner
+)
+
+// Decide returns whether subject may perform op.
+func (p Policy) Decide(subject Subject, op Operation) Decision {
+	rule := p.Operations[op]
+	if hasAnyRole(subject.Roles, rule.Any) {
+		return Allow
+	}
+	if subject.ID != "" && hasAnyRole(subject.Roles, rule.Own) {
+		return AllowOwner
+	}
+	return Deny
+}
+
+func hasAnyRole(roles []Role, allowed []Role) bool {
+	for _, role := range roles {
+		for _, a := range allowed {
+			if role == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p Policy) Validate() error {
+	if err := validateRoles(p.Anonymous); err != nil {
+		return errors.Wrap(err, "anonymous")
+	}
+	for op, rule := range p.Operations {
+		if !validOperation(op) {
+			return errors.Errorf("unknown operation %q", op)
+		}
+		for _, roles := range [][]Role{rule.Any, rule.Own} {
+			if err := validateRoles(roles); err != nil {
+				return errors.Wrap(err, string(op))
+			}
+		}
+		if len(rule.Own) > 0 && (op == ReadPosts || op == CreatePost) {
+			return errors.Errorf("%s: there is no owner to check", op)
+		}
+	}
+	return nil
+}
+
+func ValidRole(role Role) bool {
+	return hasAnyRole([]Role{role}, Roles)
+}
+
+func validateRoles(roles []Role) error {
+	for _, role := range roles {
+		if !ValidRole(role) {
+			return errors.Errorf("unknown role %q", role)
+		}
+	}
+	return nil
+}
+
+func validOperation(op Operation) bool {
+	for _, o := range Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadPolicy reads the policy from a JSON file, e.g.
+//
+//	{"anonymous": ["reader"],
+//	 "operations": {
+//	   "posts.read": {"any": ["reader", "author", "editor", "admin"]},
+//	   "posts.update": {"any": ["editor", "admin"], "own": ["author"]}}}
+func LoadPolicy(path string) (Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Policy{}, err
+	}
+	defer f.Close()
+	var policy Policy
+	if err := json.NewDecoder(f).Decode(&policy); err != nil {
+		return Policy{}, errors.Wrapf(err, "can't read policy from %s", path)
+	}
+	return policy, errors.Wrapf(policy.Validate(), "invalid policy in %s", path)
+}
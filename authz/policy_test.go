@@ -0,0 +1,67 @@
+package authz
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	anonymous := Subject{Roles: DefaultPolicy.Anonymous}
+	reader := Subject{ID: "rita", Roles: []Role{Reader}}
+	author := Subject{ID: "alice", Roles: []Role{Author}}
+	editor := Subject{ID: "eve", Roles: []Role{Editor}}
+
+	cases := []struct {
+		subject Subject
+		op      Operation
+		want    Decision
+	}{
+		{anonymous, ReadPosts, Allow},
+		{anonymous, CreatePost, Deny},
+		{reader, ReactToPost, Allow},
+		{reader, UpdatePost, Deny},
+		{author, CreatePost, Allow},
+		{author, UpdatePost, AllowOwner},
+		{author, DeletePost, AllowOwner},
+		{Subject{Roles: []Role{Author}}, UpdatePost, Deny},
+		{editor, UpdatePost, Allow},
+		{Subject{ID: "bob", Roles: []Role{Reader, Editor}}, DeletePost, Allow},
+		{Subject{ID: "x", Roles: []Role{"superuser"}}, ReadPosts, Deny},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, DefaultPolicy.Decide(c.subject, c.op), "%+v %s", c.subject, c.op)
+	}
+	assert.NoError(t, DefaultPolicy.Validate())
+}
+
+func TestUnlistedOperationsAreDenied(t *testing.T) {
+	policy := Policy{Operations: map[Operation]Rule{ReadPosts: {Any: []Role{Reader}}}}
+
+	assert.Equal(t, Deny, policy.Decide(Subject{ID: "admin", Roles: []Role{Admin}}, DeletePost))
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "authz")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.json")
+
+	ioutil.WriteFile(path, []byte(`{"anonymous": [], "operations": {"posts.update": {"any": ["admin"], "own": ["author"]}}}`), 0600)
+	policy, err := LoadPolicy(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Rule{Any: []Role{Admin}, Own: []Role{Author}}, policy.Operations[UpdatePost])
+
+	for _, invalid := range []string{
+		`{"operations": {"posts.publish": {"any": ["admin"]}}}`,
+		`{"operations": {"posts.read": {"any": ["root"]}}}`,
+		`{"operations": {"posts.create": {"own": ["author"]}}}`,
+		`{"anonymous": ["guest"]}`,
+	} {
+		ioutil.WriteFile(path, []byte(invalid), 0600)
+		_, err := LoadPolicy(path)
+		assert.Error(t, err, invalid)
+	}
+}
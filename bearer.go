@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/dsphub/go-simple-crud-sample/jwt"
+)
+
+const (
+	claimsContextKey contextKey = "jwt-claims"
+	// tenantClaim is the claim of the tenant a token is issued for.
+	tenantClaim = "tenant_id"
+)
+
+// WithJWT accepts the bearer tokens of the Authorization header that
+// verifier validates, in addition to the API keys if they are enabled.
+// Like API keys, tokens are required for every write and for the webhooks
+// and /admin/ endpoints, and grant the scopes of their scope claim.
+func WithJWT(verifier *jwt.Verifier) ServerOption {
+	return func(p *PostServer) {
+		p.jwt = verifier
+	}
+}
+
+// requestClaims returns the claims of the token the request was
+// authenticated with.
+func requestClaims(r *http.Request) (jwt.Claims, bool) {
+	claims, ok := r.Context().Value(claimsContextKey).(jwt.Claims)
+	return claims, ok
+}
+
+func bearerToken(r *http.Request) string {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// authenticateBearer answers 401 to the requests with a token that is
+// malformed, badly signed, expired or not meant for this service.
+func (p *PostServer) authenticateBearer(w http.ResponseWriter, r *http.Request) (*http.Request, []string, bool) {
+	claims, err := p.jwt.Verify(bearerToken(r))
+	if err != nil || claims.Subject == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return r, nil, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), claimsContextKey, claims)), claims.Scopes(), true
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/jwt"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+var jwtSecret = []byte("secret")
+
+func newTestVerifier() *jwt.Verifier {
+	return jwt.NewVerifier(jwt.StaticKeys{{ID: "test", Public: jwtSecret}}, "https://gateway", "posts", time.Minute)
+}
+
+// tokenActor returns the actor of the tokens of subject, see requestActor.
+func tokenActor(subject string) string {
+	return "jwt:https://gateway#" + subject
+}
+
+func newToken(subject, scope string, claims map[string]interface{}) string {
+	all := map[string]interface{}{
+		"sub":   subject,
+		"iss":   "https://gateway",
+		"aud":   "posts",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": scope,
+	}
+	for name, value := range claims {
+		all[name] = value
+	}
+	return SignToken(jwtSecret, "test", all)
+}
+
+func TestBearerTokens(t *testing.T) {
+	keys := &StubAPIKeyStore{}
+	writerKey := keys.AddKey(DefaultTenant, ScopePostsWrite)
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithJWT(newTestVerifier()), WithAPIKeys(keys), WithAudit(store, false))
+
+	serve := func(request *http.Request, token string) *httptest.ResponseRecorder {
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("return 401 on writes without a valid token", func(t *testing.T) {
+		expired := newToken("alice", ScopePostsWrite, map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})
+		for _, token := range []string{"", "garbage", expired, newToken("alice", ScopePostsWrite, map[string]interface{}{"aud": "billing"})} {
+			response := serve(newCreatePostRequest("title", "text"), token)
+
+			assertStatus(t, response.Code, http.StatusUnauthorized)
+			if response.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("got headers %v, want a bearer challenge", response.Header())
+			}
+		}
+	})
+
+	t.Run("return 403 on missing scope", func(t *testing.T) {
+		response := serve(newCreatePostRequest("title", "text"), newToken("alice", ScopePostsRead, nil))
+
+		assertStatus(t, response.Code, http.StatusForbidden)
+	})
+
+	t.Run("attribute posts to the subject of the token", func(t *testing.T) {
+		request := newCreatePostRequest("title", "text")
+		request.Header.Set(userHeader, "mallory")
+		response := serve(request, newToken("alice", ScopePostsRead+" "+ScopePostsWrite, nil))
+
+		assertStatus(t, response.Code, http.StatusCreated)
+		post := getSinglePostFromResponse(t, response.Body)
+		if post.AuthorID != tokenActor("alice") || store.Audit[0].Actor != tokenActor("alice") {
+			t.Errorf("got post %+v and audit entry %+v, want them by alice", post, store.Audit[0])
+		}
+	})
+
+	t.Run("API keys are still accepted", func(t *testing.T) {
+		request := newCreatePostRequest("title", "text")
+		request.Header.Set(apiKeyHeader, writerKey)
+		response := serve(request, "")
+
+		assertStatus(t, response.Code, http.StatusCreated)
+		if post := getSinglePostFromResponse(t, response.Body); post.AuthorID != "apikey:1" {
+			t.Errorf("got post %+v, want it by the API key", post)
+		}
+	})
+}
+
+func TestBearerTokenTenant(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithJWT(newTestVerifier()), WithTenants(CredentialTenant(HeaderTenant)))
+
+	request := newCreatePostRequest("title", "text")
+	request.Header.Set("Authorization", "Bearer "+newToken("alice", ScopePostsWrite, map[string]interface{}{tenantClaim: "acme"}))
+	response := serveForTenant(server, "globex", request)
+
+	assertStatus(t, response.Code, http.StatusCreated)
+	if post := getSinglePostFromResponse(t, response.Body); post.TenantID != "acme" {
+		t.Errorf("got post %+v, the tenant of the token should win", post)
+	}
+}
@@ -0,0 +1,89 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorBlobDoesNotExist is returned when no blob is stored under a hash.
+var ErrorBlobDoesNotExist = errors.New("could not find the blob")
+
+var hashPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// Store keeps blobs on the local file system keyed by the hex encoded
+// SHA-256 of their content, so identical uploads are stored only once.
+// Blobs are spread over sub directories named after the first two hash
+// characters to keep directories small.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "can't create blob directory")
+	}
+	return &Store{dir}, nil
+}
+
+// ValidHash reports whether hash looks like a key produced by Put.
+func ValidHash(hash string) bool {
+	return hashPattern.MatchString(hash)
+}
+
+// Put copies r into the store and returns the hash and size of the content.
+// The content is written to a temporary file first and renamed into place,
+// so readers never observe partially written blobs.
+func (s *Store) Put(r io.Reader) (string, int64, error) {
+	tmp, err := ioutil.TempFile(s.dir, ".upload-")
+	if err != nil {
+		return "", 0, errors.Wrap(err, "can't create temporary blob")
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, errors.Wrap(err, "can't write blob")
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	path := s.Path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, errors.Wrap(err, "can't create blob directory")
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, errors.Wrap(err, "can't store blob")
+	}
+	return hash, size, nil
+}
+
+// Open returns the blob stored under hash.
+func (s *Store) Open(hash string) (*os.File, error) {
+	if !ValidHash(hash) {
+		return nil, ErrorBlobDoesNotExist
+	}
+	f, err := os.Open(s.Path(hash))
+	if os.IsNotExist(err) {
+		return nil, ErrorBlobDoesNotExist
+	}
+	return f, err
+}
+
+// Path returns the location of the blob stored under hash.
+func (s *Store) Path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
@@ -0,0 +1,70 @@
+package blob
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const helloHash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func TestPutAndOpen(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	hash, size, err := s.Put(strings.NewReader("hello"))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, helloHash, hash)
+		assert.Equal(t, int64(5), size)
+	}
+	f, err := s.Open(hash)
+	if assert.NoError(t, err) {
+		defer f.Close()
+		content, _ := ioutil.ReadAll(f)
+		assert.Equal(t, "hello", string(content))
+	}
+}
+
+func TestPutStoresIdenticalContentOnce(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	first, _, err := s.Put(strings.NewReader("hello"))
+	assert.NoError(t, err)
+	second, _, err := s.Put(strings.NewReader("hello"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	entries, _ := ioutil.ReadDir(filepath.Join(s.dir, helloHash[:2]))
+	assert.Len(t, entries, 1)
+	temporary, _ := filepath.Glob(filepath.Join(s.dir, ".upload-*"))
+	assert.Empty(t, temporary)
+}
+
+func TestOpenMissingBlob(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	_, err := s.Open(helloHash)
+	assert.Equal(t, ErrorBlobDoesNotExist, err)
+
+	_, err = s.Open("../../etc/passwd")
+	assert.Equal(t, ErrorBlobDoesNotExist, err)
+}
+
+func newTestStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
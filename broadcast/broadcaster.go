@@ -0,0 +1,88 @@
+package broadcast
+
+import (
+	"sync"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+const subscriptionBuffer = 64
+
+// Broadcaster fans post change events out to subscribers in process. It
+// implements outbox.Sink, so a single instance can feed it from the outbox
+// relay; several instances feed it from Postgres LISTEN/NOTIFY instead.
+type Broadcaster struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]bool
+	closed bool
+}
+
+func New() *Broadcaster {
+	return &Broadcaster{subs: make(map[*Subscription]bool)}
+}
+
+// Subscription receives the events published after it was created. A
+// subscriber that can't keep up is dropped: its channel is closed and it is
+// expected to resubscribe and catch up from the event log.
+type Subscription struct {
+	C <-chan Event
+
+	c chan Event
+	b *Broadcaster
+}
+
+// Subscribe returns a new subscription. The channel of a subscription to a
+// closed broadcaster is closed right away.
+func (b *Broadcaster) Subscribe() *Subscription {
+	c := make(chan Event, subscriptionBuffer)
+	s := &Subscription{C: c, c: c, b: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(c)
+		return s
+	}
+	b.subs[s] = true
+	return s
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.remove(s)
+}
+
+func (b *Broadcaster) remove(s *Subscription) {
+	if b.subs[s] {
+		delete(b.subs, s)
+		close(s.c)
+	}
+}
+
+// Send publishes events to every subscriber.
+func (b *Broadcaster) Send(events []Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range events {
+		for s := range b.subs {
+			select {
+			case s.c <- e:
+			default:
+				b.remove(s)
+			}
+		}
+	}
+	return nil
+}
+
+// Close ends all subscriptions and refuses new ones.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		b.remove(s)
+	}
+	b.closed = true
+}
@@ -0,0 +1,51 @@
+package broadcast
+
+import (
+	"testing"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribersReceiveEvents(t *testing.T) {
+	b := New()
+	first, second := b.Subscribe(), b.Subscribe()
+	defer first.Close()
+
+	second.Close()
+	b.Send([]Event{{ID: 1, Type: EventPostCreated}, {ID: 2, Type: EventPostDeleted}})
+
+	assert.Equal(t, int64(1), (<-first.C).ID)
+	assert.Equal(t, int64(2), (<-first.C).ID)
+	_, open := <-second.C
+	assert.False(t, open, "closed subscription received events")
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b := New()
+	slow := b.Subscribe()
+
+	for i := 0; i <= subscriptionBuffer; i++ {
+		b.Send([]Event{{ID: int64(i)}})
+	}
+
+	received := 0
+	for range slow.C {
+		received++
+	}
+	assert.Equal(t, subscriptionBuffer, received)
+	slow.Close()
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	b := New()
+	before := b.Subscribe()
+
+	b.Close()
+	after := b.Subscribe()
+
+	_, open := <-before.C
+	assert.False(t, open)
+	_, open = <-after.C
+	assert.False(t, open)
+}
@@ -0,0 +1,147 @@
+package broadcast
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/dsphub/go-simple-crud-sample/health"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+// EventChannel is the Postgres notification channel announcing the ids of
+// new outbox events, see sql/outbox-notify.sql.
+const EventChannel = "post_events"
+
+// PingInterval is how often the listener checks its connection.
+const PingInterval = 90 * time.Second
+
+const (
+	minReconnectInterval = 10 * time.Second
+	maxReconnectInterval = time.Minute
+	catchUpLimit         = 1000
+)
+
+// Listener feeds a Broadcaster from Postgres LISTEN/NOTIFY, so that every
+// instance streams the changes made through any instance. Notifications
+// only carry event ids; the events themselves are read from the event log.
+type Listener struct {
+	log      *log.Logger
+	events   EventLog
+	b        *Broadcaster
+	listener *pq.Listener
+	lastSeq  int64
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	heartbeat health.Heartbeat
+}
+
+func NewListener(log *log.Logger, connInfo string, events EventLog, b *Broadcaster) (*Listener, error) {
+	report := func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("listener: %v", err)
+		}
+	}
+	listener := pq.NewListener(connInfo, minReconnectInterval, maxReconnectInterval, report)
+	if err := listener.Listen(EventChannel); err != nil {
+		listener.Close()
+		return nil, errors.Wrapf(err, "can't listen to %s", EventChannel)
+	}
+	return &Listener{
+		log:      log,
+		events:   events,
+		b:        b,
+		listener: listener,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start receives notifications in a background goroutine.
+func (l *Listener) Start() {
+	go l.run()
+}
+
+// Stop ends listening and closes the connection.
+func (l *Listener) Stop() {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+	l.listener.Close()
+}
+
+// Heartbeat beats when the loop starts and after every ping of the
+// connection that succeeded, so it stops while the connection is down.
+func (l *Listener) Heartbeat() *health.Heartbeat {
+	return &l.heartbeat
+}
+
+func (l *Listener) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(PingInterval)
+	defer ticker.Stop()
+
+	l.heartbeat.Beat()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case n := <-l.listener.Notify:
+			if n == nil {
+				// The connection was re-established, notifications sent in
+				// between are lost.
+				l.catchUp()
+				continue
+			}
+			l.announce(n.Extra)
+		case <-ticker.C:
+			if err := l.listener.Ping(); err != nil {
+				l.log.Printf("listener: %v", err)
+				continue
+			}
+			l.heartbeat.Beat()
+		}
+	}
+}
+
+func (l *Listener) announce(payload string) {
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		l.log.Printf("listener: invalid notification %q", payload)
+		return
+	}
+	e, err := l.events.GetEvent(id)
+	if err != nil {
+		l.log.Printf("listener: %v", err)
+		return
+	}
+	l.send([]Event{e})
+}
+
+func (l *Listener) catchUp() {
+	if l.lastSeq == 0 {
+		return
+	}
+	events, err := l.events.GetEventsSince(l.lastSeq, catchUpLimit)
+	if err != nil {
+		l.log.Printf("listener: %v", err)
+		return
+	}
+	l.send(events)
+}
+
+func (l *Listener) send(events []Event) {
+	for _, e := range events {
+		if e.Seq > l.lastSeq {
+			l.lastSeq = e.Seq
+		}
+	}
+	l.b.Send(events)
+}
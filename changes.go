@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+// WithChanges enables GET /posts/changes, the delta sync feed.
+func WithChanges(changes ChangeStore) ServerOption {
+	return func(p *PostServer) {
+		p.changes = changes
+	}
+}
+
+// getChanges returns the changes after the since checkpoint, e.g.
+// /posts/changes?since=42&limit=100. Clients start with since=0, apply the
+// changes in order and keep asking with the returned checkpoint while
+// has_more is set.
+func (p *PostServer) getChanges(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	if value := r.URL.Query().Get("since"); value != "" {
+		var err error
+		since, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || since < 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	limit := defaultChangesLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxChangesLimit {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	changes, err := p.changes.GetChangesSince(since, limit)
+	if err != nil {
+		p.log.Error("can't get changes", "since", since, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	feed := ChangeFeed{Changes: changes, Checkpoint: since, HasMore: len(changes) == limit}
+	if len(changes) > 0 {
+		feed.Checkpoint = changes[len(changes)-1].Seq
+	} else {
+		feed.Changes = []Change{}
+	}
+	setResponseContentTypeAsJSON(w)
+	json.NewEncoder(w).Encode(feed)
+}
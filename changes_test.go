@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+func TestChangesFeed(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithChanges(store))
+	first, _ := store.CreatePost(Post{Title: "first", Content: "text", Status: StatusPublished})
+	second, _ := store.CreatePost(Post{Title: "second", Content: "text", Status: StatusPublished})
+	store.CreatePost(Post{Title: "draft", Content: "text", Status: StatusDraft})
+
+	t.Run("sync from scratch in pages", func(t *testing.T) {
+		page := getChanges(t, server, 0, 2)
+
+		if len(page.Changes) != 2 || !page.HasMore || page.Checkpoint != page.Changes[1].Seq {
+			t.Fatalf("got page %+v", page)
+		}
+		page = getChanges(t, server, page.Checkpoint, 2)
+
+		if len(page.Changes) != 1 || page.HasMore || page.Changes[0].Type != ChangeDelete {
+			t.Errorf("drafts should be tombstones, got page %+v", page)
+		}
+	})
+
+	t.Run("resume with tombstones and latest versions only", func(t *testing.T) {
+		checkpoint := getChanges(t, server, 0, 100).Checkpoint
+		second.Title = "edited"
+		store.UpdatePost(second)
+		store.UpdatePost(second)
+		store.DeletePost(first.ID)
+
+		page := getChanges(t, server, checkpoint, 100)
+
+		if len(page.Changes) != 2 {
+			t.Fatalf("got changes %+v", page.Changes)
+		}
+		if page.Changes[0].Type != ChangeUpsert || page.Changes[0].Post.Title != "edited" {
+			t.Errorf("got upsert %+v", page.Changes[0])
+		}
+		if page.Changes[1].Type != ChangeDelete || page.Changes[1].PostID != first.ID || page.Changes[1].Post != nil {
+			t.Errorf("got tombstone %+v", page.Changes[1])
+		}
+	})
+
+	t.Run("nothing new keeps the checkpoint", func(t *testing.T) {
+		checkpoint := getChanges(t, server, 0, 100).Checkpoint
+
+		page := getChanges(t, server, checkpoint, 100)
+
+		if len(page.Changes) != 0 || page.Checkpoint != checkpoint {
+			t.Errorf("got page %+v", page)
+		}
+	})
+
+	t.Run("return 422 on invalid checkpoint", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/posts/changes?since=abc", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnprocessableEntity)
+	})
+}
+
+func getChanges(t *testing.T, server http.Handler, since int64, limit int) (feed ChangeFeed) {
+	t.Helper()
+	request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/posts/changes?since=%d&limit=%d", since, limit), nil)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	assertStatus(t, response.Code, http.StatusOK)
+	if err := json.NewDecoder(response.Body).Decode(&feed); err != nil {
+		t.Fatalf("Unable to parse response from server %q, '%v'", response.Body, err)
+	}
+	return feed
+}
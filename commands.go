@@ -0,0 +1,275 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/dsphub/go-simple-crud-sample/authz"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+	"github.com/dsphub/go-simple-crud-sample/transfer"
+)
+
+// command is a subcommand given after the options of the service, e.g.
+// `crud -dbname crud export --format=csv`. It gets the remaining arguments.
+type command func(log *log.Logger, store PostStore, args []string) error
+
+var commands = map[string]command{
+	"export":       exportCommand,
+	"import":       importCommand,
+	"audit-verify": auditVerifyCommand,
+
+	"apikey-create": apiKeyCreateCommand,
+	"apikey-list":   apiKeyListCommand,
+	"apikey-revoke": apiKeyRevokeCommand,
+
+	"user-roles": userRolesCommand,
+}
+
+func runCommand(log *log.Logger, opts *options, name string, args []string) {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		os.Exit(2)
+	}
+	store := initStore(log, opts.connInfo())
+	err := cmd(log, store, args)
+	store.Disconnect()
+	if err != nil {
+		log.Printf("%s: %v", name, err)
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// exportCommand writes every post to stdout or to the -output file.
+func exportCommand(log *log.Logger, store PostStore, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", transfer.FormatJSONL, "output format, jsonl or csv")
+	output := flags.String("output", "", "file to write the posts to instead of stdout")
+	flags.Parse(args)
+
+	w := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	count, err := transfer.Export(store, w, *format)
+	if err == nil && w != os.Stdout {
+		err = w.Close()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "exported %d posts", count)
+	}
+	log.Printf("exported %d posts", count)
+	return nil
+}
+
+// importCommand loads the posts of the file given as argument, or of stdin.
+// The format defaults to the extension of the file.
+func importCommand(log *log.Logger, store PostStore, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "input format, jsonl or csv")
+	upsert := flags.Bool("upsert", false, "update the posts that already exist")
+	dryRun := flags.Bool("dry-run", false, "check the posts without importing them")
+	flags.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if path := flags.Arg(0); path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+		if *format == "" {
+			*format = strings.TrimPrefix(filepath.Ext(path), ".")
+		}
+	}
+	if *format == "" {
+		*format = transfer.FormatJSONL
+	}
+
+	source, err := transfer.NewDecoder(r, *format)
+	if err != nil {
+		return err
+	}
+	count, err := transfer.Import(store, source, ImportOptions{Upsert: *upsert, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		log.Printf("checked %d posts, nothing imported", count)
+		fmt.Fprintf(os.Stderr, "checked %d posts, nothing imported\n", count)
+		return nil
+	}
+	log.Printf("imported %d posts", count)
+	fmt.Fprintf(os.Stderr, "imported %d posts\n", count)
+	return nil
+}
+
+// auditVerifyCommand checks the hash chains of the audit log and fails on
+// the first entry that was removed or altered.
+func auditVerifyCommand(log *log.Logger, store PostStore, args []string) error {
+	audit, ok := store.(AuditLog)
+	if !ok {
+		return errors.New("the store has no audit log")
+	}
+	count, err := verifyAuditChain(audit)
+	if err != nil {
+		return errors.Wrapf(err, "checked %d chained entries", count)
+	}
+	log.Printf("audit chain intact, checked %d entries", count)
+	fmt.Fprintf(os.Stderr, "audit chain intact, checked %d entries\n", count)
+	return nil
+}
+
+func apiKeyStore(store PostStore) (APIKeyStore, error) {
+	keys, ok := store.(APIKeyStore)
+	if !ok {
+		return nil, errors.New("the store has no API keys")
+	}
+	return keys, nil
+}
+
+// apiKeyCreateCommand creates an API key and prints it to stdout. The key
+// can't be shown again.
+func apiKeyCreateCommand(log *log.Logger, store PostStore, args []string) error {
+	flags := flag.NewFlagSet("apikey-create", flag.ExitOnError)
+	name := flags.String("name", "", "what the key is for")
+	tenant := flags.String("tenant", DefaultTenant, "tenant the key acts for")
+	scopes := flags.String("scopes", ScopePostsRead, "comma separated list of scopes: "+strings.Join(Scopes, ", "))
+	expires := flags.Duration("expires", 0, "validity of the key, e.g. 720h; it never expires by default")
+	flags.Parse(args)
+
+	keys, err := apiKeyStore(store)
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("the key needs a -name")
+	}
+	if !ValidTenant(*tenant) {
+		return ErrorTenantInvalid
+	}
+	k := APIKey{Name: *name, TenantID: *tenant, Scopes: strings.Split(*scopes, ",")}
+	for _, scope := range k.Scopes {
+		if !ValidScope(scope) {
+			return errors.Errorf("unknown scope %q", scope)
+		}
+	}
+	if *expires > 0 {
+		expiresAt := time.Now().Add(*expires).UTC()
+		k.ExpiresAt = &expiresAt
+	}
+
+	key, hash, err := NewAPIKey()
+	if err != nil {
+		return err
+	}
+	k.Hash = hash
+	k.Prefix = key[:12]
+	if k, err = keys.CreateAPIKey(k); err != nil {
+		return err
+	}
+	log.Printf("created API key %d %q for tenant %s", k.ID, k.Name, k.TenantID)
+	fmt.Println(key)
+	return nil
+}
+
+// apiKeyListCommand prints the API keys, revoked ones included.
+func apiKeyListCommand(log *log.Logger, store PostStore, args []string) error {
+	keys, err := apiKeyStore(store)
+	if err != nil {
+		return err
+	}
+	list, err := keys.GetAPIKeys()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPREFIX\tTENANT\tSCOPES\tEXPIRES\tSTATUS")
+	now := time.Now()
+	for _, k := range list {
+		expires, status := "never", "active"
+		if k.ExpiresAt != nil {
+			expires = k.ExpiresAt.Format(time.RFC3339)
+		}
+		if k.RevokedAt != nil {
+			status = "revoked"
+		} else if !k.Valid(now) {
+			status = "expired"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Prefix, k.TenantID, strings.Join(k.Scopes, ","), expires, status)
+	}
+	return w.Flush()
+}
+
+// apiKeyRevokeCommand revokes the API key whose ID is given as argument.
+func apiKeyRevokeCommand(log *log.Logger, store PostStore, args []string) error {
+	keys, err := apiKeyStore(store)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("usage: apikey-revoke ID")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "invalid API key ID %q", args[0])
+	}
+	if err := keys.RevokeAPIKey(id); err != nil {
+		return err
+	}
+	log.Printf("revoked API key %d", id)
+	fmt.Fprintf(os.Stderr, "revoked API key %d\n", id)
+	return nil
+}
+
+// userRolesCommand sets the roles of the user whose email is given, in the
+// tenant given or the default one, e.g.
+// `user-roles alice@example.com author,editor acme`.
+func userRolesCommand(log *log.Logger, store PostStore, args []string) error {
+	users, ok := store.(UserStore)
+	if !ok {
+		return errors.New("the store has no users")
+	}
+	if len(args) != 2 && len(args) != 3 {
+		return errors.New("usage: user-roles EMAIL ROLE[,ROLE...] [TENANT]")
+	}
+	tenant := DefaultTenant
+	if len(args) == 3 {
+		tenant = args[2]
+	}
+	var roles []string
+	for _, role := range strings.Split(args[1], ",") {
+		if !authz.ValidRole(authz.Role(role)) {
+			return errors.Errorf("unknown role %q", role)
+		}
+		roles = append(roles, role)
+	}
+	user, err := users.GetUserByEmail(tenant, NormalizeEmail(args[0]))
+	if err != nil {
+		return err
+	}
+	if err := users.SetUserRoles(user.ID, roles); err != nil {
+		return err
+	}
+	log.Printf("set roles of user %d to %s", user.ID, args[1])
+	fmt.Fprintf(os.Stderr, "set roles of user %d to %s\n", user.ID, args[1])
+	return nil
+}
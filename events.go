@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/broadcast"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+	eventStreamHeartbeat   = 15 * time.Second
+	eventReplayLimit       = 1000
+)
+
+// WithEventStream enables GET /posts/events, a Server-Sent Events stream of
+// post changes. Clients resuming with Last-Event-ID, the seq of the last event
+// they got, are first sent what they missed from the event log. The broadcaster must be sent the events as the
+// store makes them, e.g. by a broadcast.Listener for the Postgres store.
+func WithEventStream(broadcaster *broadcast.Broadcaster, events EventLog) ServerOption {
+	return func(p *PostServer) {
+		p.broadcaster = broadcaster
+		p.eventLog = events
+	}
+}
+
+func (p *PostServer) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	lastSeq, err := lastEventID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Subscribe before replaying, so nothing is lost in between.
+	sub := p.broadcaster.Subscribe()
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", eventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	replayed := make(map[int64]bool)
+	if lastSeq > 0 {
+		for {
+			events, err := p.eventLog.GetEventsSince(lastSeq, eventReplayLimit)
+			if err != nil {
+				p.log.Error("can't replay events", "since", lastSeq, "err", err)
+				return
+			}
+			for _, e := range events {
+				replayed[e.Seq] = true
+				lastSeq = e.Seq
+				e, ok := p.visibleEvent(r, e)
+				if !ok {
+					continue
+				}
+				if err := writeEvent(w, e); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+			if len(events) < eventReplayLimit {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			// The broadcaster sends the events of every tenant.
+			if replayed[e.Seq] || (p.tenant != "" && e.TenantID != p.tenant) {
+				continue
+			}
+			e, ok = p.visibleEvent(r, e)
+			if !ok {
+				continue
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// visibleEvent strips the payloads of an event the caller may not read,
+// see mayRead. It returns false if nothing of the event is left to send.
+func (p *PostServer) visibleEvent(r *http.Request, e Event) (Event, bool) {
+	if e.Before != nil && !p.mayRead(r, *e.Before) {
+		e.Before = nil
+	}
+	if e.After != nil && !p.mayRead(r, *e.After) {
+		e.After = nil
+	}
+	return e, e.Before != nil || e.After != nil
+}
+
+// lastEventID reads the seq of the last event a client has seen from the
+// Last-Event-ID header sent by reconnecting EventSources, or from the
+// lastEventId query parameter for the first connection.
+func lastEventID(r *http.Request) (int64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func writeEvent(w http.ResponseWriter, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data)
+	return err
+}
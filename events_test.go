@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dsphub/go-simple-crud-sample/broadcast"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+func TestEventStream(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	store.CreatePost(Post{Title: "first", Content: "text", Status: StatusPublished})
+	store.CreatePost(Post{Title: "second", Content: "text", Status: StatusPublished})
+	broadcaster := broadcast.New()
+	store.Publish = broadcaster.Send
+	server := httptest.NewServer(NewPostServer(std, store, WithEventStream(broadcaster, store)))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/posts/events", nil)
+	request.Header.Set("Last-Event-ID", "1")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	assertStatus(t, response.StatusCode, http.StatusOK)
+	if got := response.Header.Get("Content-Type"); got != eventStreamContentType {
+		t.Errorf("got content-type %q, want %q", got, eventStreamContentType)
+	}
+	events := bufio.NewReader(response.Body)
+
+	t.Run("replay missed events", func(t *testing.T) {
+		got := readStreamEvent(t, events)
+
+		if got.ID != 2 || got.Type != EventPostCreated || got.After.Title != "second" {
+			t.Errorf("unexpected replayed event %v", got)
+		}
+	})
+
+	t.Run("push the changes made over HTTP", func(t *testing.T) {
+		created, err := http.Post(server.URL+"/posts/new?"+url.Values{"title": {"third"}, "text": {"text"}}.Encode(), "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		created.Body.Close()
+		assertStatus(t, created.StatusCode, http.StatusCreated)
+
+		got := readStreamEvent(t, events)
+
+		if got.ID != 3 || got.Type != EventPostCreated || got.After.Title != "third" {
+			t.Errorf("unexpected pushed event %v", got)
+		}
+	})
+
+	t.Run("skip the replayed events delivered live again", func(t *testing.T) {
+		broadcaster.Send([]Event{store.Outbox[1]})
+		deleted, _ := http.NewRequest(http.MethodDelete, server.URL+"/posts/1", nil)
+		if response, err := http.DefaultClient.Do(deleted); err == nil {
+			response.Body.Close()
+		}
+
+		got := readStreamEvent(t, events)
+
+		if got.ID != 4 || got.Type != EventPostDeleted {
+			t.Errorf("unexpected pushed event %v", got)
+		}
+	})
+
+	t.Run("hide the drafts from anonymous subscribers", func(t *testing.T) {
+		store.CreatePost(Post{Title: "draft", Content: "text", Status: StatusDraft})
+		store.CreatePost(Post{Title: "fifth", Content: "text", Status: StatusPublished})
+
+		got := readStreamEvent(t, events)
+
+		if got.ID != 6 || got.After.Title != "fifth" {
+			t.Errorf("unexpected pushed event %v", got)
+		}
+	})
+}
+
+func TestEventStreamHidesReplayedDrafts(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	store.CreatePost(Post{Title: "first", Content: "text", Status: StatusPublished})
+	draft, _ := store.CreatePost(Post{Title: "draft", Content: "text", Status: StatusDraft})
+	draft.Status = StatusPublished
+	draft.Title = "published"
+	store.UpdatePost(draft)
+	server := httptest.NewServer(NewPostServer(std, store, WithEventStream(broadcast.New(), store)))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/posts/events", nil)
+	request.Header.Set("Last-Event-ID", "1")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	got := readStreamEvent(t, bufio.NewReader(response.Body))
+
+	if got.ID != 3 || got.Before != nil || got.After == nil || got.After.Title != "published" {
+		t.Errorf("unexpected replayed event %v", got)
+	}
+}
+
+func TestEventStreamRejectsInvalidLastEventID(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithEventStream(broadcast.New(), store))
+	request, _ := http.NewRequest(http.MethodGet, "/posts/events", nil)
+	request.Header.Set("Last-Event-ID", "latest")
+	response := httptest.NewRecorder()
+
+	server.ServeHTTP(response, request)
+
+	assertStatus(t, response.Code, http.StatusUnprocessableEntity)
+}
+
+func readStreamEvent(t *testing.T, r *bufio.Reader) (event Event) {
+	t.Helper()
+	var id, name string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Unable to read event stream, '%v'", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = line[len("id: "):]
+		case strings.HasPrefix(line, "event: "):
+			name = line[len("event: "):]
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(line[len("data: "):]), &event); err != nil {
+				t.Fatalf("Unable to parse event data, '%v'", err)
+			}
+		case line == "" && id != "":
+			if name != event.Type {
+				t.Errorf("event field %q does not match type %q", name, event.Type)
+			}
+			return
+		}
+	}
+}
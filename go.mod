@@ -7,4 +7,5 @@ require (
 	github.com/lib/pq v1.2.0
 	github.com/pkg/errors v0.8.1
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
 )
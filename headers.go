@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/dsphub/go-simple-crud-sample/headers"
+)
+
+// corsExposedHeaders are the response headers of the API the pages of other
+// origins may read.
+var corsExposedHeaders = []string{
+	"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After",
+	idempotentReplayedHeader, "WWW-Authenticate", requestIDHeader,
+}
+
+// WithCORS answers the preflight requests of the origins cors allows, and
+// lets them read the responses to their requests.
+func WithCORS(cors headers.CORS) ServerOption {
+	return func(p *PostServer) {
+		p.cors = &cors
+	}
+}
+
+// WithSecurityHeaders sets the security headers of rules on the responses,
+// e.g. Content-Security-Policy, by route prefix.
+func WithSecurityHeaders(rules headers.SecurityRules) ServerOption {
+	return func(p *PostServer) {
+		p.securityRules = rules
+	}
+}
+
+// corsHandled answers the preflight requests itself: they carry no
+// credentials, and must not be authenticated nor rate limited.
+func (p *PostServer) corsHandled(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.cors.Apply(w.Header(), r) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *PostServer) securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rule, ok := p.securityRules.Match(r.URL.Path); ok {
+			rule.Apply(w.Header())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
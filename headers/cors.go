@@ -0,0 +1,120 @@
+// Package headers configures the CORS and security headers of the
+// responses.
+package headers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CORS lets the pages of other origins call the API, see
+// https://fetch.spec.whatwg.org/#http-cors-protocol.
+type CORS struct {
+	// AllowedOrigins are the origins allowed, e.g. https://app.example.com,
+	// or "*" for any.
+	AllowedOrigins []string
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed, beside the
+	// CORS-safelisted ones.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers the pages can read, beside
+	// the CORS-safelisted ones.
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long, in seconds, preflight responses may be cached.
+	MaxAge int
+}
+
+func (c CORS) Validate() error {
+	for _, origin := range c.AllowedOrigins {
+		switch {
+		case origin == "*" && c.AllowCredentials:
+			return errors.New("any origin can't be allowed with credentials")
+		case origin != "*" && (!strings.Contains(origin, "://") || strings.HasSuffix(origin, "/")):
+			return errors.Errorf("invalid origin %q, want scheme://host[:port]", origin)
+		}
+	}
+	if c.MaxAge < 0 {
+		return errors.Errorf("invalid max age %d", c.MaxAge)
+	}
+	return nil
+}
+
+// Apply sets the CORS headers of the response to a request, and returns
+// whether the request is a preflight one, to be answered with them alone.
+// A preflight asking for a method or header that is not allowed gets no
+// CORS headers, so the browser fails it.
+func (c CORS) Apply(h http.Header, r *http.Request) (preflight bool) {
+	origin := r.Header.Get("Origin")
+	preflight = r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	h.Add("Vary", "Origin")
+	if preflight {
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+	}
+	allowed, any := c.allowsOrigin(origin)
+	if origin == "" || !allowed {
+		return preflight
+	}
+	if preflight && (!contains(c.AllowedMethods, r.Header.Get("Access-Control-Request-Method"), false) ||
+		!c.allowsHeaders(r.Header.Get("Access-Control-Request-Headers"))) {
+		return preflight
+	}
+
+	if any {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if len(c.ExposedHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+		}
+		return false
+	}
+	h.Set("Access-Control-Allow-Methods", strings.Join(c.AllowedMethods, ", "))
+	if len(c.AllowedHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+	}
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(c.MaxAge))
+	}
+	return true
+}
+
+func (c CORS) allowsOrigin(origin string) (allowed, any bool) {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			return true, true
+		}
+		if o == origin {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+func (c CORS) allowsHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !contains(c.AllowedHeaders, header, true) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string, foldCase bool) bool {
+	for _, v := range values {
+		if v == value || (foldCase && strings.EqualFold(v, value)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package headers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var cors = CORS{
+	AllowedOrigins:   []string{"https://app.example.com"},
+	AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+	AllowedHeaders:   []string{"Authorization", "Content-Type"},
+	ExposedHeaders:   []string{"Retry-After"},
+	AllowCredentials: true,
+	MaxAge:           600,
+}
+
+func newRequest(method, origin string, headers ...string) *http.Request {
+	r, _ := http.NewRequest(method, "/posts/", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for i := 0; i < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	return r
+}
+
+func TestCORSPreflight(t *testing.T) {
+	h := http.Header{}
+	preflight := cors.Apply(h, newRequest(http.MethodOptions, "https://app.example.com",
+		"Access-Control-Request-Method", "POST", "Access-Control-Request-Headers", "authorization, content-type"))
+
+	assert.True(t, preflight)
+	assert.Equal(t, "https://app.example.com", h.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", h.Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, POST", h.Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Authorization, Content-Type", h.Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", h.Get("Access-Control-Max-Age"))
+	assert.Contains(t, h["Vary"], "Origin")
+
+	for _, r := range []*http.Request{
+		newRequest(http.MethodOptions, "https://evil.example.com", "Access-Control-Request-Method", "POST"),
+		newRequest(http.MethodOptions, "https://app.example.com", "Access-Control-Request-Method", "DELETE"),
+		newRequest(http.MethodOptions, "https://app.example.com", "Access-Control-Request-Method", "POST", "Access-Control-Request-Headers", "X-Secret"),
+	} {
+		h := http.Header{}
+		assert.True(t, cors.Apply(h, r))
+		assert.Empty(t, h.Get("Access-Control-Allow-Origin"), "%v", r.Header)
+	}
+}
+
+func TestCORSActualRequests(t *testing.T) {
+	h := http.Header{}
+	preflight := cors.Apply(h, newRequest(http.MethodGet, "https://app.example.com"))
+
+	assert.False(t, preflight)
+	assert.Equal(t, "https://app.example.com", h.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Retry-After", h.Get("Access-Control-Expose-Headers"))
+	assert.Empty(t, h.Get("Access-Control-Allow-Methods"))
+
+	h = http.Header{}
+	cors.Apply(h, newRequest(http.MethodOptions, "https://app.example.com"))
+	assert.Empty(t, h.Get("Access-Control-Allow-Methods"), "an OPTIONS request without Access-Control-Request-Method is not a preflight")
+
+	h = http.Header{}
+	CORS{AllowedOrigins: []string{"*"}}.Apply(h, newRequest(http.MethodGet, "https://any.example.com"))
+	assert.Equal(t, "*", h.Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSValidate(t *testing.T) {
+	assert.NoError(t, cors.Validate())
+	assert.Error(t, CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true}.Validate())
+	assert.Error(t, CORS{AllowedOrigins: []string{"app.example.com"}}.Validate())
+	assert.Error(t, CORS{AllowedOrigins: []string{"https://app.example.com/"}}.Validate())
+}
+
+func TestSecurityRules(t *testing.T) {
+	rule, _ := DefaultSecurityRules.Match("/attachments/abc")
+	h := http.Header{}
+	rule.Apply(h)
+
+	assert.Contains(t, h.Get("Content-Security-Policy"), "sandbox")
+	assert.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+
+	dir, _ := ioutil.TempDir("", "headers")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "security.json")
+	ioutil.WriteFile(path, []byte(`[{"prefix": "/", "referrer_policy": "same-origin"}]`), 0600)
+	rules, err := LoadSecurityRules(path)
+	if assert.NoError(t, err) {
+		h := http.Header{}
+		rules[0].Apply(h)
+		assert.Equal(t, http.Header{"Referrer-Policy": {"same-origin"}}, h, "empty headers are not set")
+	}
+
+	ioutil.WriteFile(path, []byte(`[{"prefix": "posts"}]`), 0600)
+	_, err = LoadSecurityRules(path)
+	assert.Error(t, err)
+}
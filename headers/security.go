@@ -0,0 +1,95 @@
+package headers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Security sets the security headers of the responses of the routes under
+// Prefix. Empty headers are not set.
+type Security struct {
+	Prefix                  string `json:"prefix"`
+	ContentSecurityPolicy   string `json:"content_security_policy"`
+	StrictTransportSecurity string `json:"strict_transport_security"`
+	ContentTypeOptions      string `json:"content_type_options"`
+	ReferrerPolicy          string `json:"referrer_policy"`
+}
+
+func (s Security) Apply(h http.Header) {
+	for name, value := range map[string]string{
+		"Content-Security-Policy":   s.ContentSecurityPolicy,
+		"Strict-Transport-Security": s.StrictTransportSecurity,
+		"X-Content-Type-Options":    s.ContentTypeOptions,
+		"Referrer-Policy":           s.ReferrerPolicy,
+	} {
+		if value != "" {
+			h.Set(name, value)
+		}
+	}
+}
+
+type SecurityRules []Security
+
+// DefaultSecurityRules suit a JSON API. The attachments are sandboxed: they
+// are uploaded by the users.
+var DefaultSecurityRules = SecurityRules{
+	{
+		Prefix:                  "/",
+		ContentSecurityPolicy:   "default-src 'none'; frame-ancestors 'none'",
+		StrictTransportSecurity: "max-age=31536000; includeSubDomains",
+		ContentTypeOptions:      "nosniff",
+		ReferrerPolicy:          "no-referrer",
+	},
+	{
+		Prefix:                  "/attachments/",
+		ContentSecurityPolicy:   "default-src 'none'; img-src 'self'; style-src 'unsafe-inline'; sandbox",
+		StrictTransportSecurity: "max-age=31536000; includeSubDomains",
+		ContentTypeOptions:      "nosniff",
+		ReferrerPolicy:          "no-referrer",
+	},
+}
+
+// Match returns the rule with the longest prefix of path.
+func (rules SecurityRules) Match(path string) (Security, bool) {
+	var match Security
+	found := false
+	for _, rule := range rules {
+		if strings.HasPrefix(path, rule.Prefix) && (!found || len(rule.Prefix) > len(match.Prefix)) {
+			match = rule
+			found = true
+		}
+	}
+	return match, found
+}
+
+func (rules SecurityRules) Validate() error {
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.Prefix, "/") {
+			return errors.Errorf("invalid prefix %q", rule.Prefix)
+		}
+	}
+	return nil
+}
+
+// LoadSecurityRules reads the rules from a JSON file, e.g.
+//
+//	[{"prefix": "/", "content_security_policy": "default-src 'none'", "content_type_options": "nosniff"},
+//	 {"prefix": "/attachments/", "content_security_policy": "sandbox", "referrer_policy": "no-referrer"}]
+//
+// A route gets the headers of the longest matching prefix only.
+func LoadSecurityRules(path string) (SecurityRules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules SecurityRules
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return nil, errors.Wrapf(err, "can't read security headers from %s", path)
+	}
+	return rules, errors.Wrapf(rules.Validate(), "invalid security headers in %s", path)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dsphub/go-simple-crud-sample/headers"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+func TestCORS(t *testing.T) {
+	cors := headers.CORS{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{apiKeyHeader},
+		ExposedHeaders: corsExposedHeaders,
+		MaxAge:         600,
+	}
+	server := NewPostServer(std, NewInMemoryPostStore(), WithAPIKeys(&StubAPIKeyStore{}), WithCORS(cors))
+
+	t.Run("answer preflight requests without credentials", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodOptions, "/posts/new", nil)
+		request.Header.Set("Origin", "https://app.example.com")
+		request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		request.Header.Set("Access-Control-Request-Headers", "x-api-key")
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusNoContent)
+		if got := response.Header().Get("Access-Control-Allow-Headers"); got != apiKeyHeader {
+			t.Errorf("got allowed headers %q", got)
+		}
+	})
+
+	t.Run("let the allowed origins read the responses", func(t *testing.T) {
+		request := newGetAllPostsRequest()
+		request.Header.Set("Origin", "https://app.example.com")
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if got := response.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("got allowed origin %q", got)
+		}
+		if !strings.Contains(response.Header().Get("Access-Control-Expose-Headers"), "Retry-After") {
+			t.Errorf("got headers %v, want the rate limit headers exposed", response.Header())
+		}
+	})
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	server := NewPostServer(std, NewInMemoryPostStore(), WithAPIKeys(&StubAPIKeyStore{}), WithSecurityHeaders(headers.DefaultSecurityRules))
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, newCreatePostRequest("title", "text"))
+
+	assertStatus(t, response.Code, http.StatusUnauthorized)
+	if response.Header().Get("X-Content-Type-Options") != "nosniff" || response.Header().Get("Strict-Transport-Security") == "" {
+		t.Errorf("got headers %v, want the security headers on errors too", response.Header())
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, "/attachments/unknown", nil)
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if !strings.Contains(response.Header().Get("Content-Security-Policy"), "sandbox") {
+		t.Errorf("got headers %v, want the attachments sandboxed", response.Header())
+	}
+}
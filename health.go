@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/health"
+)
+
+// missedPasses is how many passes a background worker may miss before the
+// service is reported not ready.
+const missedPasses = 3
+
+// heartbeatWorker is a background worker reporting its passes.
+type heartbeatWorker interface {
+	Heartbeat() *health.Heartbeat
+}
+
+// addWorkerCheck adds the readiness check of a worker making a pass every
+// interval.
+func addWorkerCheck(checker *health.Checker, name string, worker heartbeatWorker, interval time.Duration) {
+	checker.AddReadiness(name, worker.Heartbeat().Check(missedPasses*interval))
+}
+
+// withHealth serves the liveness probe at /healthz and the readiness one at
+// /readyz, to anyone who can reach the service, and the other requests with
+// next.
+func withHealth(checker *health.Checker, next http.Handler) http.Handler {
+	router := http.NewServeMux()
+	router.Handle("/healthz", checker.LiveHandler())
+	router.Handle("/readyz", checker.ReadyHandler())
+	router.Handle("/", next)
+	return router
+}
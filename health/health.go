@@ -0,0 +1,184 @@
+// Package health serves the liveness and the readiness probes of the
+// service, e.g. for Kubernetes: the liveness one tells whether the process
+// should be restarted, the readiness one whether it should get traffic.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+var (
+	ErrorDraining = errors.New("draining for shutdown")
+	ErrorTimeout  = errors.New("check timed out")
+)
+
+// Check returns an error if what it checks is unhealthy. It should give up
+// once ctx is done.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Result is the outcome of a check, as served.
+type Result struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Report is the body of the probe responses.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs the checks of the probes, concurrently and each within the
+// timeout.
+type Checker struct {
+	timeout time.Duration
+
+	mu        sync.Mutex
+	liveness  []namedCheck
+	readiness []namedCheck
+	draining  bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// AddLiveness adds a check of the liveness probe. It should only fail when
+// restarting the process would help, not when a dependency is down.
+func (c *Checker) AddLiveness(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.liveness = append(c.liveness, namedCheck{name, check})
+}
+
+// AddReadiness adds a check of the readiness probe.
+func (c *Checker) AddReadiness(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readiness = append(c.readiness, namedCheck{name, check})
+}
+
+// Drain makes the readiness probe fail from now on, so that no new traffic
+// is sent while the server shuts down.
+func (c *Checker) Drain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.draining = true
+}
+
+func (c *Checker) drainCheck(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.draining {
+		return ErrorDraining
+	}
+	return nil
+}
+
+// Live runs the liveness checks.
+func (c *Checker) Live(ctx context.Context) Report {
+	c.mu.Lock()
+	checks := append([]namedCheck(nil), c.liveness...)
+	c.mu.Unlock()
+	return c.run(ctx, checks)
+}
+
+// Ready runs the readiness checks, and the "shutdown" one failing once the
+// checker drains.
+func (c *Checker) Ready(ctx context.Context) Report {
+	c.mu.Lock()
+	checks := append([]namedCheck{{"shutdown", c.drainCheck}}, c.readiness...)
+	c.mu.Unlock()
+	return c.run(ctx, checks)
+}
+
+func (c *Checker) run(ctx context.Context, checks []namedCheck) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.runCheck(ctx, check)
+		}(i, check.check)
+	}
+	wg.Wait()
+	for i, check := range checks {
+		report.Checks[check.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// runCheck runs check, giving up on it after the timeout. A check that
+// ignores its context is left running.
+func (c *Checker) runCheck(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ErrorTimeout
+	}
+	result := Result{Status: StatusOK, DurationMs: float64(time.Since(start)) / float64(time.Millisecond)}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LiveHandler serves the liveness probe, see serve.
+func (c *Checker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, c.Live)
+	})
+}
+
+// ReadyHandler serves the readiness probe, see serve.
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, c.Ready)
+	})
+}
+
+// serve responds with the report, with status 200 if every check passed or
+// else 503.
+func serve(w http.ResponseWriter, r *http.Request, run func(ctx context.Context) Report) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	report := run(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
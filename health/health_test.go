@@ -0,0 +1,104 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func probe(t *testing.T, handler http.Handler) (int, Report) {
+	t.Helper()
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report Report
+	if err := json.Unmarshal(response.Body.Bytes(), &report); err != nil {
+		t.Fatalf("can't parse report %q: %v", response.Body.String(), err)
+	}
+	return response.Code, report
+}
+
+func passing(ctx context.Context) error { return nil }
+
+func TestReadiness(t *testing.T) {
+	t.Run("pass when every check passes", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.AddReadiness("store", passing)
+
+		status, report := probe(t, checker.ReadyHandler())
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, StatusOK, report.Status)
+		assert.Equal(t, StatusOK, report.Checks["store"].Status)
+		assert.Equal(t, StatusOK, report.Checks["shutdown"].Status)
+	})
+
+	t.Run("report the failed checks", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.AddReadiness("store", passing)
+		checker.AddReadiness("schema", func(ctx context.Context) error { return errors.New("sql/tenant.sql not applied") })
+
+		status, report := probe(t, checker.ReadyHandler())
+
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, StatusFail, report.Status)
+		assert.Equal(t, StatusOK, report.Checks["store"].Status)
+		assert.Equal(t, Result{Status: StatusFail, Error: "sql/tenant.sql not applied", DurationMs: report.Checks["schema"].DurationMs}, report.Checks["schema"])
+	})
+
+	t.Run("give up on slow checks", func(t *testing.T) {
+		checker := NewChecker(10 * time.Millisecond)
+		release := make(chan struct{})
+		defer close(release)
+		checker.AddReadiness("store", func(ctx context.Context) error {
+			<-release
+			return nil
+		})
+
+		status, report := probe(t, checker.ReadyHandler())
+
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, ErrorTimeout.Error(), report.Checks["store"].Error)
+	})
+
+	t.Run("fail once draining", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.AddReadiness("store", passing)
+
+		checker.Drain()
+		status, report := probe(t, checker.ReadyHandler())
+
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, ErrorDraining.Error(), report.Checks["shutdown"].Error)
+		assert.Equal(t, StatusOK, report.Checks["store"].Status)
+	})
+}
+
+func TestLiveness(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.AddReadiness("store", func(ctx context.Context) error { return errors.New("connection refused") })
+	checker.AddLiveness("loop", passing)
+	checker.Drain()
+
+	status, report := probe(t, checker.LiveHandler())
+
+	assert.Equal(t, http.StatusOK, status, "the process is alive while its dependencies are down or it drains")
+	assert.Equal(t, map[string]Result{"loop": {Status: StatusOK, DurationMs: report.Checks["loop"].DurationMs}}, report.Checks)
+}
+
+func TestHeartbeat(t *testing.T) {
+	var h Heartbeat
+	check := h.Check(time.Minute)
+
+	assert.Equal(t, ErrorNotStarted, check(context.Background()))
+	h.Beat()
+	assert.NoError(t, check(context.Background()))
+
+	h.last = time.Now().Add(-2 * time.Minute)
+	assert.Error(t, check(context.Background()))
+}
@@ -0,0 +1,46 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrorNotStarted = errors.New("not started")
+
+// Heartbeat records when a background loop last made a pass, so that a loop
+// that exited or hangs can be told apart from a busy one.
+type Heartbeat struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// Beat records a pass of the loop.
+func (h *Heartbeat) Beat() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.last = time.Now()
+}
+
+// Last returns when the loop last made a pass, the zero time if never.
+func (h *Heartbeat) Last() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last
+}
+
+// Check returns a check failing if the loop made no pass within maxAge.
+func (h *Heartbeat) Check(maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		last := h.Last()
+		if last.IsZero() {
+			return ErrorNotStarted
+		}
+		if age := time.Since(last); age > maxAge {
+			return errors.Errorf("no pass for %s", age.Round(time.Second))
+		}
+		return nil
+	}
+}
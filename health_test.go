@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/health"
+	"github.com/dsphub/go-simple-crud-sample/logging"
+)
+
+func TestHealthProbes(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.AddReadiness("store", func(ctx context.Context) error { return nil })
+	handler := withHealth(checker, NewPostServer(logging.Discard, NewInMemoryPostStore()))
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+		assertStatus(t, response.Code, http.StatusOK)
+	}
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, newGetPostByIDRequest(1))
+	assertStatus(t, response.Code, http.StatusOK)
+
+	checker.Drain()
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assertStatus(t, response.Code, http.StatusServiceUnavailable)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	defaultIdempotencyKeysTTL = 24 * time.Hour
+)
+
+// WithIdempotency honors the Idempotency-Key header of POST /posts/new:
+// a retry with the same key and form is answered with the response to the
+// first request, and the post is created once. Keys are kept apart by the
+// principal who made the request, see requestActor, and forgotten after ttl.
+func WithIdempotency(store IdempotencyStore, ttl time.Duration) ServerOption {
+	return func(p *PostServer) {
+		p.idempotency = store
+		p.idempotencyTTL = ttl
+	}
+}
+
+// idempotent serves the request with handler unless it is a retry. Retries
+// are answered with the stored response, or with 409 while the first
+// request is in progress. A key reused for another request gets 422.
+// Responses with a 5xx status are not stored, so the request may be retried.
+func (p *PostServer) idempotent(w http.ResponseWriter, r *http.Request, handler func(w http.ResponseWriter)) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if p.idempotency == nil || key == "" {
+		handler(w)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	actor := requestActor(r)
+	fingerprint := requestFingerprint(r)
+	record, reserved, err := p.idempotency.ReserveIdempotencyKey(actor, key, fingerprint, p.idempotencyTTL)
+	if err != nil {
+		p.log.Error("can't reserve idempotency key", "key", key, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch {
+	case reserved:
+	case record.Fingerprint != fingerprint:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	case record.Status == 0:
+		w.WriteHeader(http.StatusConflict)
+		return
+	default:
+		w.Header().Set(idempotentReplayedHeader, "true")
+		if len(record.Body) > 0 {
+			setResponseContentTypeAsJSON(w)
+		}
+		w.WriteHeader(record.Status)
+		w.Write(record.Body)
+		return
+	}
+
+	response := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	handler(response)
+	if response.status >= http.StatusInternalServerError {
+		err = p.idempotency.ReleaseIdempotencyKey(actor, key)
+	} else {
+		err = p.idempotency.CompleteIdempotencyKey(actor, key, response.status, response.body.Bytes())
+	}
+	if err != nil {
+		p.log.Error("can't store the response for idempotency key", "key", key, "err", err)
+	}
+}
+
+// requestFingerprint identifies the request made with an idempotency key by
+// its actor, target and parsed form.
+func requestFingerprint(r *http.Request) string {
+	h := sha256.New()
+	for _, s := range []string{r.Method, r.URL.Path, requestActor(r), r.Form.Encode()} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes a response through and keeps a copy of its status
+// and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
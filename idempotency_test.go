@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+func TestIdempotentPostCreation(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithIdempotency(store, time.Hour))
+
+	create := func(key, title string) *httptest.ResponseRecorder {
+		request := newCreatePostRequest(title, "text")
+		request.Header.Set(idempotencyKeyHeader, key)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+
+	first := create("key-1", "title")
+	assertStatus(t, first.Code, http.StatusCreated)
+
+	t.Run("replay the response to a retry", func(t *testing.T) {
+		retry := create("key-1", "title")
+
+		assertStatus(t, retry.Code, http.StatusCreated)
+		assertResponseBody(t, first.Body.String(), retry.Body.String())
+		if retry.Header().Get(idempotentReplayedHeader) != "true" {
+			t.Errorf("replayed response should be flagged, got headers %v", retry.Header())
+		}
+		assertPostCount(t, 1, len(store.Posts))
+	})
+
+	t.Run("return 422 when the key is reused for another post", func(t *testing.T) {
+		response := create("key-1", "other title")
+
+		assertStatus(t, response.Code, http.StatusUnprocessableEntity)
+		assertPostCount(t, 1, len(store.Posts))
+	})
+
+	t.Run("return 409 while the first request is in progress", func(t *testing.T) {
+		store.ReserveIdempotencyKey("", "key-2", requestFingerprint(newParsedCreatePostRequest("title")), time.Hour)
+
+		response := create("key-2", "title")
+
+		assertStatus(t, response.Code, http.StatusConflict)
+	})
+
+	t.Run("create again once the key expired", func(t *testing.T) {
+		record := store.IdempotencyKeys["/key-1"]
+		record.ExpiresAt = time.Now().Add(-time.Second)
+		store.IdempotencyKeys["/key-1"] = record
+
+		response := create("key-1", "title")
+
+		assertStatus(t, response.Code, http.StatusCreated)
+		assertPostCount(t, 2, len(store.Posts))
+	})
+
+	t.Run("requests without a key are not deduplicated", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newCreatePostRequest("title", "text"))
+
+		assertStatus(t, response.Code, http.StatusCreated)
+		assertPostCount(t, 3, len(store.Posts))
+	})
+}
+
+func TestIdempotencyKeysArePerTenant(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithTenants(HeaderTenant), WithIdempotency(store, time.Hour))
+
+	for _, tenant := range []string{"acme", "globex"} {
+		request := newCreatePostRequest("title", "text")
+		request.Header.Set(idempotencyKeyHeader, "key-1")
+		response := serveForTenant(server, tenant, request)
+
+		assertStatus(t, response.Code, http.StatusCreated)
+		if post := getSinglePostFromResponse(t, response.Body); post.TenantID != tenant {
+			t.Errorf("got post %+v for tenant %s", post, tenant)
+		}
+	}
+	assertPostCount(t, 2, len(store.Posts))
+}
+
+func TestIdempotencyKeysArePerActor(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	keys := &StubAPIKeyStore{}
+	apiKeys := []string{
+		keys.AddKey(DefaultTenant, ScopePostsRead, ScopePostsWrite),
+		keys.AddKey(DefaultTenant, ScopePostsRead, ScopePostsWrite),
+	}
+	server := NewPostServer(std, store, WithAPIKeys(keys), WithIdempotency(store, time.Hour))
+
+	for i, apiKey := range apiKeys {
+		request := newCreatePostRequest("title", "text")
+		request.Header.Set(apiKeyHeader, apiKey)
+		request.Header.Set(idempotencyKeyHeader, "key-1")
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusCreated)
+		if response.Header().Get(idempotentReplayedHeader) != "" {
+			t.Errorf("got the response to another API key for key %d", i+1)
+		}
+		if post := getSinglePostFromResponse(t, response.Body); post.AuthorID != "apikey:"+strconv.Itoa(i+1) {
+			t.Errorf("got post %+v for key %d", post, i+1)
+		}
+	}
+	assertPostCount(t, 2, len(store.Posts))
+}
+
+func newParsedCreatePostRequest(title string) *http.Request {
+	request := newCreatePostRequest(title, "text")
+	request.ParseForm()
+	return request
+}
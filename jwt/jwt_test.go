@@ -0,0 +1,182 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+var now = time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func newVerifier(keys KeySource) *Verifier {
+	v := NewVerifier(keys, "https://gateway", "posts", time.Minute)
+	v.now = func() time.Time { return now }
+	return v
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   "alice",
+		"iss":   "https://gateway",
+		"aud":   []string{"posts", "other"},
+		"exp":   now.Add(time.Hour).Unix(),
+		"scope": "posts:read posts:write",
+	}
+}
+
+func TestVerifySupportedAlgorithms(t *testing.T) {
+	secret := []byte("secret")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	v := newVerifier(StaticKeys{
+		{ID: "hs", Alg: "HS256", Public: secret},
+		{ID: "rs", Public: &rsaKey.PublicKey},
+		{ID: "es", Public: &ecKey.PublicKey},
+	})
+
+	for kid, key := range map[string]interface{}{"hs": secret, "rs": rsaKey, "es": ecKey} {
+		t.Run(kid, func(t *testing.T) {
+			claims, err := v.Verify(SignToken(key, kid, validClaims()))
+
+			assert.NoError(t, err)
+			assert.Equal(t, "alice", claims.Subject)
+			assert.Equal(t, []string{"posts:read", "posts:write"}, claims.Scopes())
+			assert.Equal(t, "alice", claims.String("sub"))
+			assert.Equal(t, []string{"posts", "other"}, claims.Strings("aud"))
+		})
+	}
+
+	t.Run("reject a token signed with another key", func(t *testing.T) {
+		other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		_, err := v.Verify(SignToken(other, "es", validClaims()))
+		assert.Equal(t, ErrorSignatureInvalid, err)
+	})
+
+	t.Run("reject an RSA public key used as an HMAC secret", func(t *testing.T) {
+		v := newVerifier(StaticKeys{{ID: "rs", Public: &rsaKey.PublicKey}})
+		_, err := v.Verify(SignToken(rsaKey.PublicKey.N.Bytes(), "rs", validClaims()))
+		assert.Equal(t, ErrorSignatureInvalid, err)
+	})
+
+	t.Run("reject alg none", func(t *testing.T) {
+		token := encode(`{"alg":"none"}`) + "." + encode(`{"sub":"alice"}`) + "."
+		_, err := v.Verify(token)
+		assert.Equal(t, ErrorAlgorithmUnsupported, err)
+	})
+
+	t.Run("reject malformed tokens", func(t *testing.T) {
+		for _, token := range []string{"", "a.b", "!.b.c", encode(`{"alg":"HS256"}`) + ".b.!"} {
+			_, err := v.Verify(token)
+			assert.Equal(t, ErrorTokenMalformed, err, token)
+		}
+	})
+}
+
+func TestVerifyClaims(t *testing.T) {
+	secret := []byte("secret")
+	v := newVerifier(StaticKeys{{Public: secret}})
+
+	cases := []struct {
+		name  string
+		claim string
+		value interface{}
+		err   error
+	}{
+		{"accept a single audience", "aud", "posts", nil},
+		{"accept an expired token within the skew", "exp", now.Add(-30 * time.Second).Unix(), nil},
+		{"reject an expired token", "exp", now.Add(-2 * time.Minute).Unix(), ErrorTokenExpired},
+		{"reject a token without expiry", "exp", nil, ErrorTokenExpired},
+		{"accept a token not valid yet within the skew", "nbf", now.Add(30 * time.Second).Unix(), nil},
+		{"reject a token not valid yet", "nbf", now.Add(2 * time.Minute).Unix(), ErrorTokenNotValidYet},
+		{"reject another issuer", "iss", "https://elsewhere", ErrorIssuerInvalid},
+		{"reject another audience", "aud", "billing", ErrorAudienceInvalid},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			claims := validClaims()
+			if c.value == nil {
+				delete(claims, c.claim)
+			} else {
+				claims[c.claim] = c.value
+			}
+
+			_, err := v.Verify(SignToken(secret, "", claims))
+
+			assert.Equal(t, c.err, err)
+		})
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "oct", "kid": "hs", "alg": "HS256", "k": %q},
+		{"kty": "EC", "kid": "es", "crv": "P-256", "x": %q, "y": %q},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "AA"}
+	]}`, encode("secret"), encodeInt(ecKey.X), encodeInt(ecKey.Y))
+
+	keys, err := ParseJWKS([]byte(jwks))
+
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2, "keys for encryption and of unsupported types are skipped") {
+		assert.Equal(t, Key{ID: "hs", Alg: "HS256", Public: []byte("secret")}, keys[0])
+		assert.Equal(t, "es", keys[1].ID)
+		assert.True(t, ecKey.PublicKey.Equal(keys[1].Public))
+	}
+
+	_, err = ParseJWKS([]byte(`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`))
+	assert.Error(t, err, "points off the curve are rejected")
+}
+
+func TestFileKeySetReloadsOnChange(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jwks")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	write := func(secret string) {
+		ioutil.WriteFile(path, []byte(fmt.Sprintf(`{"keys": [{"kty": "oct", "k": %q}]}`, encode(secret))), 0600)
+	}
+	write("first")
+	set, err := NewFileKeySet(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	clock := now
+	set.now = func() time.Time { return clock }
+	set.checked = clock
+
+	write("second-secret")
+	keys, _ := set.Keys()
+	assert.Equal(t, []byte("first"), keys[0].Public, "the file is checked at most once a second")
+
+	clock = clock.Add(reloadCheckInterval)
+	keys, _ = set.Keys()
+	assert.Equal(t, []byte("second-secret"), keys[0].Public)
+
+	ioutil.WriteFile(path, []byte("{"), 0600)
+	clock = clock.Add(reloadCheckInterval)
+	keys, _ = set.Keys()
+	assert.Equal(t, []byte("second-secret"), keys[0].Public, "a broken file keeps the previous keys")
+	assert.Error(t, set.Err())
+}
+
+func encode(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.FillBytes(make([]byte, 32)))
+}
@@ -0,0 +1,189 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Key is a verification key of a JWKS. Public is a []byte secret for "oct"
+// keys, an *rsa.PublicKey or an *ecdsa.PublicKey.
+type Key struct {
+	ID     string
+	Alg    string
+	Public interface{}
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS reads the keys of a JSON Web Key Set. Keys of other types or
+// uses than the supported ones are skipped.
+func ParseJWKS(data []byte) ([]Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.Wrap(err, "invalid JWKS")
+	}
+	var keys []Key
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		public, err := k.public()
+		if err != nil {
+			return nil, errors.Wrapf(err, "key %d", i)
+		}
+		if public != nil {
+			keys = append(keys, Key{ID: k.Kid, Alg: k.Alg, Public: public})
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) public() (interface{}, error) {
+	switch k.Kty {
+	case "oct":
+		return decodeSegment(k.K)
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on P-256")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := decodeSegment(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// KeySource hands out the current verification keys.
+type KeySource interface {
+	Keys() ([]Key, error)
+}
+
+// StaticKeys is a fixed set of keys.
+type StaticKeys []Key
+
+func (k StaticKeys) Keys() ([]Key, error) {
+	return k, nil
+}
+
+// reloadCheckInterval bounds how often the JWKS file is checked for
+// changes.
+const reloadCheckInterval = time.Second
+
+// FileKeySet reads the keys from a JWKS file and reloads them when the
+// file changes, e.g. on key rotation. A file that can't be read or parsed
+// leaves the previous keys in place.
+type FileKeySet struct {
+	path string
+	now  func() time.Time
+
+	mu      sync.Mutex
+	keys    []Key
+	modTime time.Time
+	size    int64
+	checked time.Time
+	err     error
+}
+
+func NewFileKeySet(path string) (*FileKeySet, error) {
+	s := &FileKeySet{path: path, now: time.Now}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Keys returns the keys, reloading the file if it changed since the last
+// check.
+func (s *FileKeySet) Keys() ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := s.now(); now.Sub(s.checked) >= reloadCheckInterval {
+		s.checked = now
+		s.err = s.reload()
+	}
+	return s.keys, nil
+}
+
+// Err returns the error of the last reload, if it failed.
+func (s *FileKeySet) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *FileKeySet) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return errors.Wrap(err, "can't read JWKS")
+	}
+	if s.keys != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return errors.Wrap(err, "can't read JWKS")
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return errors.Wrap(err, s.path)
+	}
+	s.keys, s.modTime, s.size = keys, info.ModTime(), info.Size()
+	return nil
+}
@@ -0,0 +1,214 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrorTokenMalformed       = errors.New("malformed token")
+	ErrorAlgorithmUnsupported = errors.New("unsupported signing algorithm")
+	ErrorSignatureInvalid     = errors.New("invalid token signature")
+	ErrorTokenExpired         = errors.New("token is expired")
+	ErrorTokenNotValidYet     = errors.New("token is not valid yet")
+	ErrorIssuerInvalid        = errors.New("invalid token issuer")
+	ErrorAudienceInvalid      = errors.New("invalid token audience")
+)
+
+// Claims are the claims of a verified token. Raw holds them all, including
+// the registered ones.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+	// Scope is the space separated list of scopes granted to the token.
+	Scope string `json:"scope"`
+
+	Raw map[string]interface{} `json:"-"`
+}
+
+// Scopes splits the scope claim.
+func (c Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// String returns the claim name as a string, or "" if it is not one.
+func (c Claims) String(name string) string {
+	s, _ := c.Raw[name].(string)
+	return s
+}
+
+// Strings returns the claim name as a list of strings: an array of strings,
+// or a space separated string.
+func (c Claims) Strings(name string) []string {
+	switch value := c.Raw[name].(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		var values []string
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Audience is either a single string or an array of strings.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a Audience) Contains(audience string) bool {
+	for _, s := range a {
+		if s == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier checks the signature and the registered claims of tokens.
+// Tokens must expire; Issuer and Audience are checked when they are set.
+type Verifier struct {
+	keys     KeySource
+	issuer   string
+	audience string
+	skew     time.Duration
+	now      func() time.Time
+}
+
+func NewVerifier(keys KeySource, issuer, audience string, skew time.Duration) *Verifier {
+	return &Verifier{keys: keys, issuer: issuer, audience: audience, skew: skew, now: time.Now}
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify returns the claims of a compact serialized JWS token.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrorTokenMalformed
+	}
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil {
+		return Claims{}, ErrorTokenMalformed
+	}
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return Claims{}, ErrorTokenMalformed
+	}
+	if err := v.verifySignature(h, parts[0]+"."+parts[1], signature); err != nil {
+		return Claims{}, err
+	}
+
+	var claims Claims
+	if err := decodeJSON(parts[1], &claims); err != nil {
+		return Claims{}, ErrorTokenMalformed
+	}
+	if err := decodeJSON(parts[1], &claims.Raw); err != nil {
+		return Claims{}, ErrorTokenMalformed
+	}
+	return claims, v.checkClaims(claims)
+}
+
+// verifySignature tries the keys that fit the algorithm of the token. The
+// key type is tied to the algorithm, so that e.g. an RSA public key can't
+// be used as an HMAC secret.
+func (v *Verifier) verifySignature(h header, signed string, signature []byte) error {
+	keys, err := v.keys.Keys()
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(signed))
+	for _, key := range keys {
+		if (h.Kid != "" && key.ID != h.Kid) || (key.Alg != "" && key.Alg != h.Alg) {
+			continue
+		}
+		var ok bool
+		switch public := key.Public.(type) {
+		case []byte:
+			if h.Alg != "HS256" {
+				continue
+			}
+			mac := hmac.New(sha256.New, public)
+			mac.Write([]byte(signed))
+			ok = hmac.Equal(mac.Sum(nil), signature)
+		case *rsa.PublicKey:
+			if h.Alg != "RS256" {
+				continue
+			}
+			ok = rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil
+		case *ecdsa.PublicKey:
+			if h.Alg != "ES256" {
+				continue
+			}
+			if len(signature) != 64 {
+				return ErrorSignatureInvalid
+			}
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			ok = ecdsa.Verify(public, digest[:], r, s)
+		}
+		if ok {
+			return nil
+		}
+	}
+	switch h.Alg {
+	case "HS256", "RS256", "ES256":
+		return ErrorSignatureInvalid
+	}
+	return ErrorAlgorithmUnsupported
+}
+
+func (v *Verifier) checkClaims(c Claims) error {
+	now := v.now()
+	switch {
+	case c.ExpiresAt == 0 || !now.Add(-v.skew).Before(time.Unix(c.ExpiresAt, 0)):
+		return ErrorTokenExpired
+	case c.NotBefore != 0 && now.Add(v.skew).Before(time.Unix(c.NotBefore, 0)):
+		return ErrorTokenNotValidYet
+	case v.issuer != "" && c.Issuer != v.issuer:
+		return ErrorIssuerInvalid
+	case v.audience != "" && !c.Audience.Contains(v.audience):
+		return ErrorAudienceInvalid
+	}
+	return nil
+}
+
+func decodeJSON(segment string, v interface{}) error {
+	data, err := decodeSegment(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
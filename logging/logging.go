@@ -0,0 +1,243 @@
+// Package logging writes leveled, structured log records as JSON objects or
+// as logfmt lines:
+//
+//	{"time":"2020-01-02T15:04:05.000Z","level":"info","msg":"request","status":200}
+//	time=2020-01-02T15:04:05.000Z level=info msg=request status=200
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+func ParseLevel(name string) (Level, error) {
+	for l, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(l), nil
+		}
+	}
+	return Debug, errors.Errorf("unknown log level %q", name)
+}
+
+type Format string
+
+const (
+	JSON Format = "json"
+	Text Format = "text"
+)
+
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case JSON, Text:
+		return format, nil
+	}
+	return "", errors.Errorf("unknown log format %q", name)
+}
+
+const timeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// output is shared by a logger and the loggers derived from it, so that
+// their records don't interleave.
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format Format
+	level  Level
+	now    func() time.Time
+}
+
+// Logger writes the records of at least its level. The fields are pairs of a
+// string key and a value, e.g.
+//
+//	log.Info("post created", "post", post.ID, "author", post.AuthorID)
+//
+// Errors and fmt.Stringers are logged as their text, other values as JSON.
+type Logger struct {
+	out    *output
+	fields []interface{}
+}
+
+func New(w io.Writer, format Format, level Level) *Logger {
+	return &Logger{out: &output{w: w, format: format, level: level, now: time.Now}}
+}
+
+// Discard drops every record.
+var Discard = New(ioutil.Discard, Text, Error+1)
+
+// With returns a logger adding fields to every record.
+func (l *Logger) With(fields ...interface{}) *Logger {
+	all := make([]interface{}, 0, len(l.fields)+len(fields))
+	all = append(all, l.fields...)
+	return &Logger{out: l.out, fields: append(all, fields...)}
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.out.level
+}
+
+func (l *Logger) Debug(msg string, fields ...interface{}) { l.Log(Debug, msg, fields...) }
+func (l *Logger) Info(msg string, fields ...interface{})  { l.Log(Info, msg, fields...) }
+func (l *Logger) Warn(msg string, fields ...interface{})  { l.Log(Warn, msg, fields...) }
+func (l *Logger) Error(msg string, fields ...interface{}) { l.Log(Error, msg, fields...) }
+
+func (l *Logger) Log(level Level, msg string, fields ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	var b bytes.Buffer
+	r := record{format: l.out.format, b: &b}
+	r.begin()
+	r.field("time", l.out.now().UTC().Format(timeFormat))
+	r.field("level", level.String())
+	r.field("msg", msg)
+	r.fields(l.fields)
+	r.fields(fields)
+	r.end()
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(b.Bytes())
+}
+
+// Std returns a standard logger writing a record of level for every line,
+// for the code that logs with a *log.Logger.
+func (l *Logger) Std(level Level) *log.Logger {
+	return log.New(stdWriter{l, level}, "", 0)
+}
+
+type stdWriter struct {
+	l     *Logger
+	level Level
+}
+
+func (w stdWriter) Write(p []byte) (int, error) {
+	w.l.Log(w.level, strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger ctx carries, or nil.
+func FromContext(ctx context.Context) *Logger {
+	l, _ := ctx.Value(contextKey{}).(*Logger)
+	return l
+}
+
+type record struct {
+	format Format
+	b      *bytes.Buffer
+	n      int
+}
+
+func (r *record) begin() {
+	if r.format == JSON {
+		r.b.WriteByte('{')
+	}
+}
+
+func (r *record) end() {
+	if r.format == JSON {
+		r.b.WriteByte('}')
+	}
+	r.b.WriteByte('\n')
+}
+
+// fields writes the key value pairs of fields; a key without a value gets
+// the !MISSING one, so that a wrong call is noticed rather than dropped.
+func (r *record) fields(fields []interface{}) {
+	for i := 0; i < len(fields); i += 2 {
+		key := fmt.Sprint(fields[i])
+		if i+1 == len(fields) {
+			r.field(key, "!MISSING")
+			break
+		}
+		r.field(key, fields[i+1])
+	}
+}
+
+func (r *record) field(key string, value interface{}) {
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case time.Time:
+		value = v.UTC().Format(timeFormat)
+	case fmt.Stringer:
+		value = v.String()
+	}
+	if r.n > 0 {
+		if r.format == JSON {
+			r.b.WriteByte(',')
+		} else {
+			r.b.WriteByte(' ')
+		}
+	}
+	r.n++
+	if r.format == JSON {
+		r.b.Write(jsonValue(key))
+		r.b.WriteByte(':')
+		r.b.Write(jsonValue(value))
+		return
+	}
+	r.b.WriteString(textValue(key))
+	r.b.WriteByte('=')
+	if s, ok := value.(string); ok {
+		r.b.WriteString(textValue(s))
+	} else {
+		r.b.WriteString(textValue(string(jsonValue(value))))
+	}
+}
+
+func jsonValue(value interface{}) []byte {
+	b, err := json.Marshal(value)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return b
+}
+
+// textValue quotes s unless it is a plain word.
+func textValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, c := range s {
+		if c == '"' || c == '=' || c == '\\' || unicode.IsSpace(c) || !unicode.IsPrint(c) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
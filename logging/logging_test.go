@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogger(format Format, level Level) (*Logger, *bytes.Buffer) {
+	var b bytes.Buffer
+	l := New(&b, format, level)
+	l.out.now = func() time.Time { return time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC) }
+	return l, &b
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("write JSON records", func(t *testing.T) {
+		l, b := newTestLogger(JSON, Info)
+
+		l.With("request_id", "abc").Info("request", "status", 200, "err", errors.New("boom"), "latency", 1500*time.Millisecond)
+
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal(b.Bytes(), &record))
+		assert.Equal(t, map[string]interface{}{
+			"time":       "2020-01-02T15:04:05.000Z",
+			"level":      "info",
+			"msg":        "request",
+			"request_id": "abc",
+			"status":     float64(200),
+			"err":        "boom",
+			"latency":    "1.5s",
+		}, record)
+	})
+
+	t.Run("write logfmt records", func(t *testing.T) {
+		l, b := newTestLogger(Text, Info)
+
+		l.Warn("can't send", "to", "a b", "id", 7, "empty", "", "quote", `say "hi"`)
+
+		assert.Equal(t, `time=2020-01-02T15:04:05.000Z level=warn msg="can't send" to="a b" id=7 empty="" quote="say \"hi\""`+"\n", b.String())
+	})
+
+	t.Run("skip the records below the level", func(t *testing.T) {
+		l, b := newTestLogger(Text, Warn)
+
+		l.Debug("debug")
+		l.Info("info")
+		l.Error("error")
+
+		assert.Contains(t, b.String(), "msg=error")
+		assert.NotContains(t, b.String(), "info")
+		assert.NotContains(t, b.String(), "debug")
+	})
+
+	t.Run("keep the fields of the parent", func(t *testing.T) {
+		l, b := newTestLogger(Text, Info)
+		parent := l.With("a", 1)
+
+		parent.With("b", 2).Info("child")
+		parent.With("c", 3).Info("sibling")
+
+		assert.Contains(t, b.String(), "msg=child a=1 b=2\n")
+		assert.Contains(t, b.String(), "msg=sibling a=1 c=3\n")
+	})
+
+	t.Run("flag a key without value", func(t *testing.T) {
+		l, b := newTestLogger(Text, Info)
+
+		l.Info("odd", "key")
+
+		assert.Contains(t, b.String(), "key=!MISSING")
+	})
+
+	t.Run("escape line breaks", func(t *testing.T) {
+		l, b := newTestLogger(Text, Info)
+
+		l.Info("forged\nlevel=error msg=x")
+
+		assert.Equal(t, 1, bytes.Count(b.Bytes(), []byte("\n")))
+	})
+
+	t.Run("write a record per line of the standard logger", func(t *testing.T) {
+		l, b := newTestLogger(JSON, Info)
+
+		l.Std(Warn).Printf("scheduler: %v", "failed")
+
+		assert.Equal(t, `{"time":"2020-01-02T15:04:05.000Z","level":"warn","msg":"scheduler: failed"}`+"\n", b.String())
+	})
+
+	t.Run("carry the logger in the context", func(t *testing.T) {
+		l, _ := newTestLogger(JSON, Info)
+
+		assert.Nil(t, FromContext(context.Background()))
+		assert.Equal(t, l, FromContext(NewContext(context.Background(), l)))
+	})
+}
+
+func TestParse(t *testing.T) {
+	level, err := ParseLevel("WARN")
+	assert.NoError(t, err)
+	assert.Equal(t, Warn, level)
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+
+	format, err := ParseFormat("json")
+	assert.NoError(t, err)
+	assert.Equal(t, JSON, format)
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+// Package mail sends the mails of the service, e.g. to verify the email of
+// a user.
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Message is a plain text mail to one recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Format returns the message in the Internet Message Format, from from at
+// date.
+func (m Message) Format(from string, date time.Time) ([]byte, error) {
+	for _, header := range []string{from, m.To, m.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, errors.New("line break in mail header")
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	w := quotedprintable.NewWriter(&b)
+	w.Write([]byte(strings.Replace(m.Body, "\n", "\r\n", -1)))
+	w.Close()
+	return b.Bytes(), nil
+}
+
+// Mailer sends messages.
+type Mailer interface {
+	Send(m Message) error
+}
+
+// SMTPMailer sends the messages through an SMTP server, with STARTTLS if
+// the server supports it.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer sends from from through the server at addr, host:port. The
+// server is logged in to with PLAIN if username is not empty.
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	m := &SMTPMailer{addr: addr, from: from}
+	if username != "" {
+		host := addr
+		if i := strings.LastIndex(addr, ":"); i >= 0 {
+			host = addr[:i]
+		}
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	data, err := msg.Format(m.from, time.Now())
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, data), "can't mail %s", msg.To)
+}
+
+// FileMailer writes every message to a file of dir, for development.
+type FileMailer struct {
+	dir   string
+	from  string
+	count int64
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	now := time.Now()
+	data, err := msg.Format(m.from, now)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102T150405"), atomic.AddInt64(&m.count, 1))
+	return ioutil.WriteFile(filepath.Join(m.dir, name), data, 0600)
+}
+
+// LogMailer logs the messages instead of sending them, for development.
+type LogMailer struct {
+	Log *log.Logger
+}
+
+func (m LogMailer) Send(msg Message) error {
+	m.Log.Printf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
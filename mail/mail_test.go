@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+func TestSMTPMailer(t *testing.T) {
+	server, err := NewFakeSMTPServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer server.Close()
+	mailer := NewSMTPMailer(server.Addr, "crud@example.com", "", "")
+
+	err = mailer.Send(Message{To: "alice@example.com", Subject: "Vérifiez", Body: "Hello\n.hidden line\n"})
+
+	assert.NoError(t, err)
+	messages := server.Messages()
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, "crud@example.com", messages[0].From)
+		assert.Equal(t, []string{"alice@example.com"}, messages[0].To)
+		assert.Contains(t, messages[0].Data, "Subject: =?utf-8?q?V=C3=A9rifiez?=\r\n")
+		assert.Contains(t, messages[0].Data, "\r\n\r\nHello\r\n.hidden line\r\n")
+	}
+}
+
+func TestFormatRejectsHeaderInjection(t *testing.T) {
+	_, err := Message{To: "alice@example.com\r\nBcc: mallory@example.com", Subject: "hi"}.Format("crud@example.com", time.Now())
+
+	assert.Error(t, err)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mail")
+	defer os.RemoveAll(dir)
+	mailer, _ := NewFileMailer(dir, "crud@example.com")
+
+	assert.NoError(t, mailer.Send(Message{To: "alice@example.com", Subject: "first", Body: "text"}))
+	assert.NoError(t, mailer.Send(Message{To: "bob@example.com", Subject: "second", Body: "text"}))
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 2)
+}
+
+func TestLogMailer(t *testing.T) {
+	var b bytes.Buffer
+	LogMailer{Log: log.New(&b, "", 0)}.Send(Message{To: "alice@example.com", Subject: "hi", Body: "text"})
+
+	assert.True(t, strings.HasPrefix(b.String(), "mail to alice@example.com: hi\ntext"), b.String())
+}
@@ -1,16 +1,38 @@
 package main
 
 import (
+	"crypto/rand"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/dsphub/go-simple-crud-sample/authz"
+	"github.com/dsphub/go-simple-crud-sample/blob"
+	"github.com/dsphub/go-simple-crud-sample/broadcast"
+	"github.com/dsphub/go-simple-crud-sample/headers"
+	"github.com/dsphub/go-simple-crud-sample/health"
+	"github.com/dsphub/go-simple-crud-sample/jwt"
+	"github.com/dsphub/go-simple-crud-sample/logging"
+	"github.com/dsphub/go-simple-crud-sample/mail"
+	"github.com/dsphub/go-simple-crud-sample/metrics"
+	"github.com/dsphub/go-simple-crud-sample/outbox"
+	"github.com/dsphub/go-simple-crud-sample/ratelimit"
+	"github.com/dsphub/go-simple-crud-sample/scheduler"
 	. "github.com/dsphub/go-simple-crud-sample/store"
+	"github.com/dsphub/go-simple-crud-sample/thumbnail"
+	"github.com/dsphub/go-simple-crud-sample/trace"
+	"github.com/dsphub/go-simple-crud-sample/usertoken"
+	"github.com/dsphub/go-simple-crud-sample/views"
+	"github.com/dsphub/go-simple-crud-sample/webhook"
 	_ "github.com/lib/pq"
 )
 
@@ -25,20 +47,186 @@ const (
 const domainName = "localhost"
 const httpServerPort = "5000"
 const logFileName = "log.out"
+const thumbnailQueueSize = 100
+const serviceName = "go-simple-crud-sample"
 
 func main() {
-	log.Println("Start service")
+	opts := initOptions()
+	logger := initLogger(logFileName, opts)
+	log := logger.Std(logging.Info)
+	if flag.NArg() > 0 {
+		runCommand(log, opts, flag.Arg(0), flag.Args()[1:])
+		return
+	}
+
+	logger.Info("start service", "addr", fmt.Sprintf("%s:%s", domainName, httpServerPort))
+	store := initStore(log, opts.connInfo())
+	blobs := initBlobStore(log, *opts.attachmentsDir)
+	thumbnails := initThumbnails(log, blobs, opts)
+	thumbnails.Start()
+	viewCounter := views.NewCounter(log, store, *opts.viewsFlushInterval)
+	viewCounter.Start()
+	broadcaster := broadcast.New()
+	listener := initListener(log, opts.connInfo(), store, broadcaster)
+	listener.Start()
+	serverOptions := []ServerOption{
+		WithAttachments(store, blobs, opts.attachmentLimits()),
+		WithThumbnails(thumbnails),
+		WithReactions(store),
+		WithViews(viewCounter, store),
+		WithEventStream(broadcaster, store),
+		WithWebhooks(store),
+		WithChanges(store),
+		WithAudit(store, *opts.auditChain),
+		WithIdempotency(store, *opts.idempotencyTTL),
+	}
+	if limiter := initRateLimiter(log, store, *opts.rateLimiter); limiter != nil {
+		serverOptions = append(serverOptions, WithRateLimits(limiter, initRateLimits(log, *opts.rateLimits)))
+	}
+	if *opts.apiKeys {
+		serverOptions = append(serverOptions, WithAPIKeys(store))
+	}
+	if *opts.jwks != "" {
+		serverOptions = append(serverOptions, WithJWT(initJWTVerifier(log, opts)))
+	}
+	if *opts.accounts {
+		serverOptions = append(serverOptions, WithAccounts(store, store, *opts.sessionTTL))
+		if mailer := initMailer(log, opts); mailer != nil {
+			tokens := usertoken.NewSigner(initTokenSecret(log, *opts.tokenSecret))
+			serverOptions = append(serverOptions, WithAccountMail(mailer, tokens, *opts.baseURL))
+		}
+	}
+	if *opts.rbac {
+		serverOptions = append(serverOptions, WithPolicy(initPolicy(log, *opts.policy)))
+	}
+	if *opts.multiTenant && (*opts.apiKeys || *opts.jwks != "" || *opts.accounts) {
+		serverOptions = append(serverOptions, WithTenants(CredentialTenant(HeaderTenant)))
+	} else if *opts.multiTenant {
+		serverOptions = append(serverOptions, WithTenants(HeaderTenant))
+	}
+	if *opts.corsOrigins != "" {
+		serverOptions = append(serverOptions, WithCORS(initCORS(log, opts)))
+	}
+	serverOptions = append(serverOptions, WithSecurityHeaders(initSecurityRules(log, *opts.securityHeaders)))
+	tracer, exporter := initTracer(log, opts)
+	if tracer != nil {
+		serverOptions = append(serverOptions, WithTracing(tracer))
+	}
+	var postStore PostStore = store
+	var registry *metrics.Registry
+	if *opts.metricsAddr != "" {
+		registry = initMetrics(store)
+		postStore = metrics.NewStoreMetrics(registry).Instrument(store)
+	}
+	server := NewPostServer(logger, postStore, serverOptions...)
+	var handler http.Handler = server
+	var metricsSrv *http.Server
+	if registry != nil {
+		handler = withMetrics(registry, server)
+		metricsSrv = serveMetrics(logger, registry, *opts.metricsAddr)
+	}
+
+	publisher := scheduler.New(log, store, *opts.publishInterval)
+	publisher.Start()
+	dispatcher := webhook.NewDispatcher(log, store, *opts.webhookInterval)
+	dispatcher.SetTracer(tracer)
+	dispatcher.Start()
+	relay := outbox.NewRelay(log, store, *opts.outboxInterval, outbox.LogSink{Log: log}, dispatcher)
+	relay.Start()
+
+	checker := health.NewChecker(*opts.healthTimeout)
+	checker.AddReadiness("store", store.Ping)
+	checker.AddReadiness("schema", store.CheckSchema)
+	addWorkerCheck(checker, "scheduler", publisher, *opts.publishInterval)
+	addWorkerCheck(checker, "outbox", relay, *opts.outboxInterval)
+	addWorkerCheck(checker, "webhooks", dispatcher, *opts.webhookInterval)
+	addWorkerCheck(checker, "views", viewCounter, *opts.viewsFlushInterval)
+	addWorkerCheck(checker, "listener", listener, broadcast.PingInterval)
+	handler = withHealth(checker, handler)
+
+	srv := &http.Server{Handler: handler, ErrorLog: logger.Std(logging.Error)}
+	// The event streams only end with their subscriptions.
+	srv.RegisterOnShutdown(broadcaster.Close)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	exitCode := 0
+	l, err := net.Listen("tcp", fmt.Sprintf("%s:%s", domainName, httpServerPort))
+	if err == nil {
+		err = serve(logger, srv, l, checker, signals, *opts.drainDelay, *opts.shutdownTimeout)
+	}
+	if err != nil {
+		logger.Error("can't serve", "err", err)
+		exitCode = 1
+	}
+
+	// The workers are stopped before the store they use, the view counter
+	// flushing the buffered views.
+	var steps []shutdownStep
+	if metricsSrv != nil {
+		steps = append(steps, shutdownStep{"metrics", metricsSrv.Close})
+	}
+	steps = append(steps, []shutdownStep{
+		{"thumbnails", stopping(thumbnails.Stop)},
+		{"scheduler", stopping(publisher.Stop)},
+		{"outbox", stopping(relay.Stop)},
+		{"webhooks", stopping(dispatcher.Stop)},
+		{"listener", stopping(listener.Stop)},
+		{"views", stopping(viewCounter.Stop)},
+		{"store", store.Disconnect},
+	}...)
+	if exporter != nil {
+		steps = append(steps, shutdownStep{"traces", exporter.Close})
+	}
+	if !stopAll(logger, steps) {
+		exitCode = 1
+	}
+	logger.Info("service stopped")
+	os.Exit(exitCode)
+}
+
+// initTracer returns the tracer of the service and its exporter, to be
+// closed once the service stops; nil ones if tracing is off.
+func initTracer(log *log.Logger, opts *options) (*trace.Tracer, *trace.WriterExporter) {
+	var exporter *trace.WriterExporter
+	switch *opts.traceExporter {
+	case "stdout":
+		exporter = trace.NewWriterExporter(os.Stdout)
+	case "file":
+		var err error
+		exporter, err = trace.NewFileExporter(*opts.traceFile)
+		if err != nil {
+			log.Panic(err)
+		}
+	case "off":
+		return nil, nil
+	default:
+		log.Panicf("unknown trace exporter %q", *opts.traceExporter)
+	}
+	return trace.NewTracer(serviceName, exporter), exporter
+}
 
-	log := initLogger(logFileName)
-	connInfo := initOptions(log)
-	store := initStore(log, connInfo)
-	server := NewPostServer(log, store)
+func initMetrics(store *PostgresPostStore) *metrics.Registry {
+	registry := metrics.NewRegistry()
+	metrics.RegisterRuntime(registry)
+	metrics.RegisterDBStats(registry, store.Stats)
+	return registry
+}
 
-	if err := http.ListenAndServe(fmt.Sprintf("%s:%s", domainName, httpServerPort), server); err != nil {
-		store.Disconnect()
-		log.Fatalf("could not listen on port %s %v", httpServerPort, err)
+// serveMetrics serves the metrics of registry on addr, apart from the API,
+// until the returned server is closed.
+func serveMetrics(logger *logging.Logger, registry *metrics.Registry, addr string) *http.Server {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Std(logging.Info).Panic(err)
 	}
-	waitTerminateSignal(log, store)
+	logger.Info("serve metrics", "addr", addr)
+	srv := &http.Server{Handler: metricsHandler(registry), ErrorLog: logger.Std(logging.Error)}
+	go func() {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			logger.Error("can't serve metrics", "err", err)
+		}
+	}()
+	return srv
 }
 
 func initStore(log *log.Logger, connInfo string) *PostgresPostStore {
@@ -52,17 +240,197 @@ func initStore(log *log.Logger, connInfo string) *PostgresPostStore {
 	return postStore
 }
 
+func initListener(log *log.Logger, connInfo string, store *PostgresPostStore, broadcaster *broadcast.Broadcaster) *broadcast.Listener {
+	listener, err := broadcast.NewListener(log, connInfo, store, broadcaster)
+	if err != nil {
+		log.Panic(err)
+	}
+	return listener
+}
+
+func initJWTVerifier(log *log.Logger, opts *options) *jwt.Verifier {
+	keys, err := jwt.NewFileKeySet(*opts.jwks)
+	if err != nil {
+		log.Panic(err)
+	}
+	return jwt.NewVerifier(keys, *opts.jwtIssuer, *opts.jwtAudience, *opts.jwtSkew)
+}
+
+func initBlobStore(log *log.Logger, dir string) *blob.Store {
+	blobs, err := blob.NewStore(dir)
+	if err != nil {
+		log.Panic(err)
+	}
+	return blobs
+}
+
+func initThumbnails(log *log.Logger, blobs *blob.Store, opts *options) *thumbnail.Generator {
+	var sizes []int
+	for _, value := range strings.Split(*opts.thumbnailSizes, ",") {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			log.Panicf("invalid thumbnail size %q", value)
+		}
+		sizes = append(sizes, size)
+	}
+	thumbnails, err := thumbnail.NewGenerator(log, blobs, *opts.thumbnailsDir, sizes, *opts.thumbnailWorkers, thumbnailQueueSize)
+	if err != nil {
+		log.Panic(err)
+	}
+	return thumbnails
+}
+
+func initRateLimiter(log *log.Logger, store *PostgresPostStore, kind string) ratelimit.Limiter {
+	switch kind {
+	case "memory":
+		return ratelimit.NewMemoryLimiter()
+	case "postgres":
+		return ratelimit.NewStoreLimiter(store)
+	case "off":
+		return nil
+	}
+	log.Panicf("unknown rate limiter %q", kind)
+	return nil
+}
+
+func initRateLimits(log *log.Logger, path string) ratelimit.Rules {
+	if path == "" {
+		return ratelimit.DefaultRules
+	}
+	rules, err := ratelimit.LoadRules(path)
+	if err != nil {
+		log.Panic(err)
+	}
+	return rules
+}
+
+func initMailer(log *log.Logger, opts *options) mail.Mailer {
+	switch *opts.mailer {
+	case "smtp":
+		return mail.NewSMTPMailer(*opts.smtpAddr, *opts.mailFrom, *opts.smtpUser, *opts.smtpPassword)
+	case "file":
+		mailer, err := mail.NewFileMailer(*opts.mailDir, *opts.mailFrom)
+		if err != nil {
+			log.Panic(err)
+		}
+		return mailer
+	case "log":
+		return mail.LogMailer{Log: log}
+	case "off":
+		return nil
+	}
+	log.Panicf("unknown mailer %q", *opts.mailer)
+	return nil
+}
+
+// initTokenSecret returns the secret the mailed tokens are signed with. A
+// random one is made if none is given, the tokens are then void once the
+// service restarts.
+func initTokenSecret(log *log.Logger, secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	log.Println("no -token-secret, the mailed tokens won't survive a restart")
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Panic(err)
+	}
+	return b
+}
+
+func initCORS(log *log.Logger, opts *options) headers.CORS {
+	cors := headers.CORS{
+		AllowedOrigins:   strings.Split(*opts.corsOrigins, ","),
+		AllowedMethods:   strings.Split(*opts.corsMethods, ","),
+		AllowedHeaders:   strings.Split(*opts.corsHeaders, ","),
+		ExposedHeaders:   corsExposedHeaders,
+		AllowCredentials: *opts.corsCredentials,
+		MaxAge:           int(opts.corsMaxAge.Seconds()),
+	}
+	if err := cors.Validate(); err != nil {
+		log.Panic(err)
+	}
+	return cors
+}
+
+func initSecurityRules(log *log.Logger, path string) headers.SecurityRules {
+	if path == "" {
+		return headers.DefaultSecurityRules
+	}
+	rules, err := headers.LoadSecurityRules(path)
+	if err != nil {
+		log.Panic(err)
+	}
+	return rules
+}
+
+func initPolicy(log *log.Logger, path string) authz.Policy {
+	if path == "" {
+		return authz.DefaultPolicy
+	}
+	policy, err := authz.LoadPolicy(path)
+	if err != nil {
+		log.Panic(err)
+	}
+	return policy
+}
+
 type options struct {
-	host       *string
-	portNumber *int
-	user       *string
-	password   *string
-	dbname     *string
-	ssl        *bool
+	host               *string
+	portNumber         *int
+	user               *string
+	password           *string
+	dbname             *string
+	ssl                *bool
+	publishInterval    *time.Duration
+	attachmentsDir     *string
+	attachmentsSize    *int64
+	attachmentTypes    *string
+	thumbnailsDir      *string
+	thumbnailSizes     *string
+	thumbnailWorkers   *int
+	viewsFlushInterval *time.Duration
+	outboxInterval     *time.Duration
+	webhookInterval    *time.Duration
+	multiTenant        *bool
+	auditChain         *bool
+	idempotencyTTL     *time.Duration
+	rateLimiter        *string
+	rateLimits         *string
+	apiKeys            *bool
+	jwks               *string
+	jwtIssuer          *string
+	jwtAudience        *string
+	jwtSkew            *time.Duration
+	rbac               *bool
+	policy             *string
+	accounts           *bool
+	sessionTTL         *time.Duration
+	mailer             *string
+	mailFrom           *string
+	mailDir            *string
+	smtpAddr           *string
+	smtpUser           *string
+	smtpPassword       *string
+	tokenSecret        *string
+	baseURL            *string
+	corsOrigins        *string
+	corsMethods        *string
+	corsHeaders        *string
+	corsCredentials    *bool
+	corsMaxAge         *time.Duration
+	securityHeaders    *string
+	logFormat          *string
+	logLevel           *string
+	metricsAddr        *string
+	traceExporter      *string
+	traceFile          *string
+	healthTimeout      *time.Duration
+	drainDelay         *time.Duration
+	shutdownTimeout    *time.Duration
 }
 
-func initOptions(log *log.Logger) string {
-	log.Println("Parse command-line options")
+func initOptions() *options {
 	opts := &options{}
 	opts.host = flag.String("host", "localhost", "service host name")
 	opts.portNumber = flag.Int("port", 5432, "service port number")
@@ -70,7 +438,64 @@ func initOptions(log *log.Logger) string {
 	opts.user = flag.String("user", "postgres", "db user")
 	opts.password = flag.String("password", "", "db password")
 	opts.ssl = flag.Bool("ssl", false, "db ssl support")
+	opts.publishInterval = flag.Duration("publish-interval", 10*time.Second, "how often scheduled posts are checked for publishing")
+	opts.attachmentsDir = flag.String("attachments-dir", "attachments", "directory the attachment blobs are stored in")
+	opts.attachmentsSize = flag.Int64("attachments-max-size", 10<<20, "maximum attachment size in bytes")
+	opts.attachmentTypes = flag.String("attachments-types", "image/jpeg,image/png,image/gif,application/pdf,text/plain", "comma separated list of allowed attachment media types")
+	opts.thumbnailsDir = flag.String("thumbnails-dir", "thumbnails", "directory the rendered thumbnails are stored in")
+	opts.thumbnailSizes = flag.String("thumbnail-sizes", "64,256", "comma separated list of thumbnail sizes in pixels")
+	opts.thumbnailWorkers = flag.Int("thumbnail-workers", 2, "number of thumbnail rendering workers")
+	opts.viewsFlushInterval = flag.Duration("views-flush-interval", 10*time.Second, "how often buffered post views are written to the db")
+	opts.outboxInterval = flag.Duration("outbox-interval", time.Second, "how often pending post change events are relayed")
+	opts.webhookInterval = flag.Duration("webhook-interval", 5*time.Second, "how often due webhook deliveries are attempted")
+	opts.multiTenant = flag.Bool("multi-tenant", false, "serve every request for the tenant given by the X-Tenant-ID header")
+	opts.auditChain = flag.Bool("audit-chain", false, "hash chain the audit entries, see the audit-verify command")
+	opts.idempotencyTTL = flag.Duration("idempotency-ttl", defaultIdempotencyKeysTTL, "how long idempotency keys are remembered")
+	opts.rateLimiter = flag.String("rate-limiter", "memory", "where the rate limit buckets are kept: memory, postgres to share them between instances, or off")
+	opts.rateLimits = flag.String("rate-limits", "", "JSON file of the rate limits by route, see ratelimit.LoadRules")
+	opts.apiKeys = flag.Bool("api-keys", false, "require an API key for writes, see the apikey-create command")
+	opts.jwks = flag.String("jwks", "", "JWKS file of the keys bearer tokens are verified with, reloaded when it changes; no bearer tokens if empty")
+	opts.jwtIssuer = flag.String("jwt-issuer", "", "required iss claim of bearer tokens")
+	opts.jwtAudience = flag.String("jwt-audience", "", "required aud claim of bearer tokens")
+	opts.jwtSkew = flag.Duration("jwt-skew", time.Minute, "clock skew tolerated when checking the exp and nbf claims of bearer tokens")
+	opts.rbac = flag.Bool("rbac", false, "authorize the operations on posts by the roles of the caller, use with -api-keys or -jwks")
+	opts.policy = flag.String("policy", "", "JSON file of the roles allowed each operation on posts, see authz.LoadPolicy; the default policy if empty")
+	opts.accounts = flag.Bool("accounts", false, "let users register and log in with a password, see the user-roles command")
+	opts.sessionTTL = flag.Duration("session-ttl", defaultSessionTTL, "how long a login lasts")
+	opts.mailer = flag.String("mailer", "log", "how the account mails are sent: smtp, file to write them to -mail-dir, log, or off to skip email verification")
+	opts.mailFrom = flag.String("mail-from", "crud@localhost", "sender of the account mails")
+	opts.mailDir = flag.String("mail-dir", "mail", "directory the account mails are written to with -mailer file")
+	opts.smtpAddr = flag.String("smtp-addr", "localhost:25", "SMTP server host:port")
+	opts.smtpUser = flag.String("smtp-user", "", "SMTP user name, no authentication if empty")
+	opts.smtpPassword = flag.String("smtp-password", "", "SMTP password")
+	opts.tokenSecret = flag.String("token-secret", "", "secret the tokens of the account mails are signed with")
+	opts.baseURL = flag.String("base-url", "http://"+domainName+":"+httpServerPort, "URL of the service the links of the account mails point to")
+	opts.corsOrigins = flag.String("cors-origins", "", "comma separated list of the origins allowed to call the API, e.g. https://app.example.com, or * for any; no CORS if empty")
+	opts.corsMethods = flag.String("cors-methods", "GET,HEAD,POST,PUT,DELETE", "comma separated list of the methods allowed to the other origins")
+	opts.corsHeaders = flag.String("cors-headers", "Authorization,Content-Type,X-API-Key,X-Tenant-ID,X-Request-ID,Idempotency-Key", "comma separated list of the request headers allowed to the other origins")
+	opts.corsCredentials = flag.Bool("cors-credentials", false, "let the other origins send cookies, e.g. the session one")
+	opts.corsMaxAge = flag.Duration("cors-max-age", 10*time.Minute, "how long browsers may cache the preflight responses")
+	opts.securityHeaders = flag.String("security-headers", "", "JSON file of the security headers by route, see headers.LoadSecurityRules")
+	opts.logFormat = flag.String("log-format", "text", "format of the log records: text for logfmt lines, or json")
+	opts.logLevel = flag.String("log-level", "info", "least level of the logged records: debug, info, warn or error")
+	opts.metricsAddr = flag.String("metrics-addr", "", "host:port the Prometheus metrics are served at /metrics on, apart from the API and without authentication, e.g. localhost:9090; no metrics if empty")
+	opts.traceExporter = flag.String("trace-exporter", "off", "where the trace spans are written: stdout, file to append them to -trace-file, or off")
+	opts.traceFile = flag.String("trace-file", "traces.jsonl", "file the trace spans are appended to with -trace-exporter file")
+	opts.healthTimeout = flag.Duration("health-timeout", 2*time.Second, "how long each check of the /healthz and /readyz probes may take")
+	opts.drainDelay = flag.Duration("drain-delay", 0, "how long the service keeps accepting connections once asked to stop, with /readyz failing, so that the load balancers take it out first")
+	opts.shutdownTimeout = flag.Duration("shutdown-timeout", 15*time.Second, "how long the requests in flight get to end once the service stops accepting connections")
+	flag.Parse()
+	return opts
+}
 
+func (opts *options) attachmentLimits() AttachmentLimits {
+	return AttachmentLimits{
+		MaxSize:      *opts.attachmentsSize,
+		AllowedTypes: strings.Split(*opts.attachmentTypes, ","),
+	}
+}
+
+func (opts *options) connInfo() string {
 	port := strconv.Itoa(*opts.portNumber)
 
 	var sslmode string
@@ -84,11 +509,17 @@ func initOptions(log *log.Logger) string {
 	return dbinfo
 }
 
-func initLogger(fileName string) *log.Logger {
+func initLogger(fileName string, opts *options) *logging.Logger {
+	format, err := logging.ParseFormat(*opts.logFormat)
+	if err != nil {
+		panic(err)
+	}
+	level, err := logging.ParseLevel(*opts.logLevel)
+	if err != nil {
+		panic(err)
+	}
 	if fileName != "" {
-		log.Println("Create log file")
-
-		filePath, err:= getLogFilePath()
+		filePath, err := getLogFilePath()
 		if err != nil {
 			panic(err)
 		}
@@ -97,10 +528,10 @@ func initLogger(fileName string) *log.Logger {
 		if err != nil {
 			panic(err)
 		}
-		
-		return log.New(logFile, "", log.Ldate|log.Ltime|log.Lshortfile)
+
+		return logging.New(logFile, format, level)
 	}
-	return log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	return logging.New(os.Stdout, format, level)
 }
 
 func getLogFilePath() (string, error) {
@@ -108,21 +539,5 @@ func getLogFilePath() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return projectPath + string(filepath.Separator)+logFileName, nil
-}
-
-func waitTerminateSignal(log *log.Logger, store *PostgresPostStore) {
-	// After setting everything up!
-	// Wait for a SIGINT (perhaps triggered by user with CTRL-C)
-	// Run cleanup when signal is received
-	signalChan := make(chan os.Signal, 1)
-	cleanupDone := make(chan struct{})
-	signal.Notify(signalChan, os.Interrupt)
-	go func() {
-		<-signalChan
-		fmt.Println("Received an interrupt, stopping services...")
-		store.Disconnect()
-		close(cleanupDone)
-	}()
-	<-cleanupDone
+	return projectPath + string(filepath.Separator) + logFileName, nil
 }
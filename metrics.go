@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dsphub/go-simple-crud-sample/metrics"
+)
+
+// routeSegments are the path segments of the routes of the server, any other
+// segment is an ID.
+var routeSegments = map[string]bool{
+	"posts": true, "new": true, "popular": true, "events": true, "changes": true,
+	"attachments": true, "reactions": true, "thumb": true,
+	"webhooks": true, "deliveries": true, "admin": true, "audit": true,
+	"auth": true, "register": true, "login": true, "logout": true, "verify": true,
+	"password-reset": true, "confirm": true,
+}
+
+const maxRouteSegments = 4
+
+// metricsRoute returns the route of a request, its path with the IDs
+// replaced by {id}, e.g. /posts/{id}/reactions. The paths that can't be a
+// route are reported as "other", so that the clients can't make up series.
+func metricsRoute(r *http.Request) string {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) > maxRouteSegments || !routeSegments[segments[0]] {
+		return "other"
+	}
+	for i, segment := range segments {
+		if !routeSegments[segment] {
+			segments[i] = "{id}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// withMetrics serves the requests with next, counting them by route in
+// registry. The metrics are not served along, see metricsHandler.
+func withMetrics(registry *metrics.Registry, next http.Handler) http.Handler {
+	return metrics.NewHTTPMetrics(registry).Instrument(next, metricsRoute)
+}
+
+// metricsHandler serves the metrics of registry at /metrics. It has no
+// authentication: serve it on an address only the scrapers can reach.
+func metricsHandler(registry *metrics.Registry) http.Handler {
+	router := http.NewServeMux()
+	router.Handle("/metrics", registry)
+	return router
+}
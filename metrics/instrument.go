@@ -0,0 +1,197 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+// HTTPMetrics counts the requests served by a handler and their latency.
+type HTTPMetrics struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+func NewHTTPMetrics(r *Registry) *HTTPMetrics {
+	m := &HTTPMetrics{
+		requests: NewCounterVec("http_requests_total", "Number of HTTP requests served.", "method", "route", "status"),
+		duration: NewHistogramVec("http_request_duration_seconds", "Latency of the HTTP requests.", DefaultBuckets, "method", "route", "status"),
+	}
+	r.Register(m.requests)
+	r.Register(m.duration)
+	return m
+}
+
+// Instrument returns next recording its requests by the route returned for
+// them. route must not return more than a few values, e.g. /posts/{id}
+// rather than the path, every value is a series of its own.
+func (m *HTTPMetrics) Instrument(next http.Handler, route func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		status := strconv.Itoa(sw.status)
+		method := r.Method
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		default:
+			method = "other"
+		}
+		path := route(r)
+		m.requests.Inc(method, path, status)
+		m.duration.Observe(time.Since(start).Seconds(), method, path, status)
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// StoreMetrics records the latency and the errors of the PostStore methods.
+type StoreMetrics struct {
+	duration *HistogramVec
+	errors   *CounterVec
+}
+
+func NewStoreMetrics(r *Registry) *StoreMetrics {
+	m := &StoreMetrics{
+		duration: NewHistogramVec("store_operation_duration_seconds", "Latency of the post store operations.", DefaultBuckets, "operation"),
+		errors:   NewCounterVec("store_operation_errors_total", "Number of failed post store operations, missing posts aside.", "operation"),
+	}
+	r.Register(m.duration)
+	r.Register(m.errors)
+	return m
+}
+
+// Instrument returns store recording its operations. The returned store
+// can be scoped like store: it implements TenantScoper if store does, and
+// RequestScoper, SpanScoper and AuditScoper.
+func (m *StoreMetrics) Instrument(store PostStore) PostStore {
+	s := &instrumentedStore{PostStore: store, m: m}
+	if _, ok := store.(TenantScoper); ok {
+		return &tenantInstrumentedStore{s}
+	}
+	return s
+}
+
+func (m *StoreMetrics) observe(operation string, start time.Time, err error) {
+	m.duration.Observe(time.Since(start).Seconds(), operation)
+	if err != nil && err != ErrorPostDoesNotExist {
+		m.errors.Inc(operation)
+	}
+}
+
+type instrumentedStore struct {
+	PostStore
+	m *StoreMetrics
+}
+
+func (s *instrumentedStore) Connect() error {
+	start := time.Now()
+	err := s.PostStore.Connect()
+	s.m.observe("Connect", start, err)
+	return err
+}
+
+func (s *instrumentedStore) Disconnect() error {
+	start := time.Now()
+	err := s.PostStore.Disconnect()
+	s.m.observe("Disconnect", start, err)
+	return err
+}
+
+func (s *instrumentedStore) GetAllPosts() ([]Post, error) {
+	start := time.Now()
+	posts, err := s.PostStore.GetAllPosts()
+	s.m.observe("GetAllPosts", start, err)
+	return posts, err
+}
+
+func (s *instrumentedStore) GetPostByID(id int) (Post, error) {
+	start := time.Now()
+	post, err := s.PostStore.GetPostByID(id)
+	s.m.observe("GetPostByID", start, err)
+	return post, err
+}
+
+func (s *instrumentedStore) CreatePost(post Post) (Post, error) {
+	start := time.Now()
+	post, err := s.PostStore.CreatePost(post)
+	s.m.observe("CreatePost", start, err)
+	return post, err
+}
+
+func (s *instrumentedStore) UpdatePost(post Post) error {
+	start := time.Now()
+	err := s.PostStore.UpdatePost(post)
+	s.m.observe("UpdatePost", start, err)
+	return err
+}
+
+func (s *instrumentedStore) DeletePost(id int) error {
+	start := time.Now()
+	err := s.PostStore.DeletePost(id)
+	s.m.observe("DeletePost", start, err)
+	return err
+}
+
+// ForRequest scopes the store to the request if it can, see RequestScoper.
+func (s *instrumentedStore) ForRequest(requestID string) RequestScoper {
+	scoper, ok := s.PostStore.(RequestScoper)
+	if !ok {
+		return s
+	}
+	return s.m.Instrument(scoper.ForRequest(requestID).(PostStore)).(RequestScoper)
+}
+
+// ForSpan scopes the store to the trace span if it can, see SpanScoper.
+func (s *instrumentedStore) ForSpan(span TraceSpan) SpanScoper {
+	scoper, ok := s.PostStore.(SpanScoper)
+	if !ok {
+		return s
+	}
+	return s.m.Instrument(scoper.ForSpan(span).(PostStore)).(SpanScoper)
+}
+
+// ForAudit audits the changes of the store if it can, see AuditScoper.
+func (s *instrumentedStore) ForAudit(entry AuditEntry, chain bool) AuditScoper {
+	scoper, ok := s.PostStore.(AuditScoper)
+	if !ok {
+		return s
+	}
+	return s.m.Instrument(scoper.ForAudit(entry, chain).(PostStore)).(AuditScoper)
+}
+
+type tenantInstrumentedStore struct {
+	*instrumentedStore
+}
+
+func (s *tenantInstrumentedStore) ForTenant(tenant string) TenantScoper {
+	scoped := s.PostStore.(TenantScoper).ForTenant(tenant).(PostStore)
+	return s.m.Instrument(scoped).(TenantScoper)
+}
@@ -0,0 +1,262 @@
+// Package metrics keeps counters, gauges and histograms and exposes them in
+// the Prometheus text format, see
+// https://prometheus.io/docs/instrumenting/exposition_formats/.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds in seconds of the latency histograms.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector writes the samples of one or more metric families.
+type Collector interface {
+	Collect(w io.Writer)
+}
+
+// Registry serves the metrics of its collectors in registration order.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Collect writes the samples of every collector, a registry can be registered
+// with another one.
+func (r *Registry) Collect(w io.Writer) {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	b := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.Collect(b)
+	}
+	b.Flush()
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	r.Collect(w)
+}
+
+// vec holds the series of a metric family by label values.
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	// counts are the cumulative bucket counts of a histogram.
+	counts []uint64
+}
+
+func newVec(name, help, kind string, labels []string) vec {
+	return vec{name: name, help: help, kind: kind, labels: labels, series: make(map[string]*series)}
+}
+
+// get returns the series of values, creating it; v.mu must be held.
+func (v *vec) get(values []string, buckets int) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("%s: got %d label values, want %d", v.name, len(values), len(v.labels)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...), counts: make([]uint64, buckets)}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted returns copies of the series ordered by their label values, so that
+// the output is stable; v.mu must be held.
+func (v *vec) sorted() []series {
+	all := make([]series, 0, len(v.series))
+	for _, s := range v.series {
+		c := *s
+		c.counts = append([]uint64(nil), s.counts...)
+		all = append(all, c)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].values, "\xff") < strings.Join(all[j].values, "\xff")
+	})
+	return all
+}
+
+func (v *vec) header(w io.Writer) {
+	writeHeader(w, v.name, v.help, v.kind)
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, kind)
+}
+
+// CounterVec counts events by label values.
+type CounterVec struct {
+	vec
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{newVec(name, help, "counter", labels)}
+}
+
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *CounterVec) Add(delta float64, values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(values, 0).value += delta
+}
+
+func (c *CounterVec) Collect(w io.Writer) {
+	c.mu.Lock()
+	all := c.sorted()
+	c.mu.Unlock()
+
+	c.header(w)
+	for _, s := range all {
+		writeSample(w, c.name, c.labels, s.values, "", "", s.value)
+	}
+}
+
+// HistogramVec counts observations in buckets by label values.
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{vec: newVec(name, help, "histogram", labels), buckets: buckets}
+}
+
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(values, len(h.buckets)+1)
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.counts[len(h.buckets)]++
+	s.value += value
+}
+
+func (h *HistogramVec) Collect(w io.Writer) {
+	h.mu.Lock()
+	all := h.sorted()
+	h.mu.Unlock()
+
+	h.header(w)
+	for _, s := range all {
+		for i, bound := range h.buckets {
+			writeSample(w, h.name+"_bucket", h.labels, s.values, "le", formatValue(bound), float64(s.counts[i]))
+		}
+		count := float64(s.counts[len(h.buckets)])
+		writeSample(w, h.name+"_bucket", h.labels, s.values, "le", "+Inf", count)
+		writeSample(w, h.name+"_sum", h.labels, s.values, "", "", s.value)
+		writeSample(w, h.name+"_count", h.labels, s.values, "", "", count)
+	}
+}
+
+// Func is a metric whose only sample is read when the metrics are collected,
+// e.g. the size of a pool.
+type Func struct {
+	name string
+	help string
+	kind string
+	fn   func() float64
+}
+
+func NewGaugeFunc(name, help string, fn func() float64) *Func {
+	return &Func{name: name, help: help, kind: "gauge", fn: fn}
+}
+
+// NewCounterFunc is for the counters kept by someone else, fn must never
+// decrease.
+func NewCounterFunc(name, help string, fn func() float64) *Func {
+	return &Func{name: name, help: help, kind: "counter", fn: fn}
+}
+
+func (f *Func) Collect(w io.Writer) {
+	writeHeader(w, f.name, f.help, f.kind)
+	writeSample(w, f.name, nil, nil, "", "", f.fn())
+}
+
+func writeSample(w io.Writer, name string, labels, values []string, extraLabel, extraValue string, value float64) {
+	io.WriteString(w, name)
+	if len(labels) > 0 || extraLabel != "" {
+		io.WriteString(w, "{")
+		for i, label := range labels {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabel(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				io.WriteString(w, ",")
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraLabel, extraValue)
+		}
+		io.WriteString(w, "}")
+	}
+	io.WriteString(w, " "+formatValue(value)+"\n")
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func collect(c Collector) string {
+	var b bytes.Buffer
+	c.Collect(&b)
+	return b.String()
+}
+
+func TestCounterVec(t *testing.T) {
+	c := NewCounterVec("requests_total", "Number of requests.", "route")
+	c.Inc("/posts")
+	c.Add(2, `a"b\c`+"\n")
+	c.Inc("/posts")
+
+	assert.Equal(t, `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{route="/posts"} 2
+requests_total{route="a\"b\\c\n"} 2
+`, collect(c))
+}
+
+func TestHistogramVec(t *testing.T) {
+	h := NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+	h.Observe(0.05, "get")
+	h.Observe(0.5, "get")
+	h.Observe(5, "get")
+
+	assert.Equal(t, `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="get",le="0.1"} 1
+latency_seconds_bucket{op="get",le="1"} 2
+latency_seconds_bucket{op="get",le="+Inf"} 3
+latency_seconds_sum{op="get"} 5.55
+latency_seconds_count{op="get"} 3
+`, collect(h))
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register(NewGaugeFunc("queue_length", "Length of the queue.", func() float64 { return 3 }))
+	RegisterRuntime(r)
+	RegisterDBStats(r, func() sql.DBStats { return sql.DBStats{OpenConnections: 2} })
+	response := httptest.NewRecorder()
+
+	r.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, contentType, response.Header().Get("Content-Type"))
+	body := response.Body.String()
+	assert.True(t, strings.HasPrefix(body, "# HELP queue_length Length of the queue.\n# TYPE queue_length gauge\nqueue_length 3\n"), body)
+	assert.Contains(t, body, "\ngo_goroutines ")
+	assert.Contains(t, body, "\nsql_open_connections 2\n")
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	r := NewRegistry()
+	m := NewHTTPMetrics(r)
+	handler := m.Instrument(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/posts/2" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte("ok"))
+	}), func(r *http.Request) string { return "/posts/{id}" })
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/posts/1", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/posts/2", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/posts/1", nil))
+
+	out := collect(r)
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/posts/{id}",status="200"} 1`)
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/posts/{id}",status="404"} 1`)
+	assert.Contains(t, out, `http_requests_total{method="other",route="/posts/{id}",status="200"} 1`)
+	assert.Contains(t, out, `http_request_duration_seconds_count{method="GET",route="/posts/{id}",status="404"} 1`)
+}
+
+type failingStore struct {
+	*StubPostStore
+}
+
+func (s failingStore) CreatePost(post Post) (Post, error) {
+	return post, errors.New("connection refused")
+}
+
+func TestStoreMetrics(t *testing.T) {
+	t.Run("record the operations and their errors", func(t *testing.T) {
+		r := NewRegistry()
+		store := NewStoreMetrics(r).Instrument(failingStore{&StubPostStore{Posts: map[int]Post{}}})
+
+		store.GetPostByID(1)
+		store.CreatePost(Post{Title: "title"})
+
+		out := collect(r)
+		assert.Contains(t, out, `store_operation_duration_seconds_count{operation="GetPostByID"} 1`)
+		assert.Contains(t, out, `store_operation_errors_total{operation="CreatePost"} 1`)
+		assert.NotContains(t, out, `store_operation_errors_total{operation="GetPostByID"}`, "a missing post is no error")
+	})
+
+	t.Run("scope like the store", func(t *testing.T) {
+		r := NewRegistry()
+		stub := &StubPostStore{Posts: map[int]Post{1: Post{ID: 1, TenantID: "acme"}}}
+		store := NewStoreMetrics(r).Instrument(stub)
+
+		scoped := store.(TenantScoper).ForTenant("other").(PostStore)
+		_, err := scoped.GetPostByID(1)
+
+		assert.Equal(t, ErrorPostDoesNotExist, err)
+		assert.Contains(t, collect(r), `store_operation_duration_seconds_count{operation="GetPostByID"} 1`)
+	})
+
+	t.Run("not scope a store that can't be scoped", func(t *testing.T) {
+		store := NewStoreMetrics(NewRegistry()).Instrument(unscopedStore{&StubPostStore{}})
+
+		_, ok := store.(TenantScoper)
+
+		assert.False(t, ok)
+	})
+}
+
+type unscopedStore struct {
+	PostStore
+}
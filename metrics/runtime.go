@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"database/sql"
+	"io"
+	"runtime"
+	"time"
+)
+
+// RegisterRuntime registers the go_ metrics of the process: goroutines,
+// memory and garbage collection.
+func RegisterRuntime(r *Registry) {
+	r.Register(runtimeCollector{})
+}
+
+type runtimeCollector struct{}
+
+func (runtimeCollector) Collect(w io.Writer) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	samples := []Func{
+		{"go_goroutines", "Number of goroutines that currently exist.", "gauge", func() float64 { return float64(runtime.NumGoroutine()) }},
+		{"go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", "gauge", func() float64 { return float64(m.Alloc) }},
+		{"go_memstats_sys_bytes", "Number of bytes obtained from system.", "gauge", func() float64 { return float64(m.Sys) }},
+		{"go_memstats_heap_objects", "Number of allocated objects.", "gauge", func() float64 { return float64(m.HeapObjects) }},
+		{"go_memstats_mallocs_total", "Total number of mallocs.", "counter", func() float64 { return float64(m.Mallocs) }},
+		{"go_gc_cycles_total", "Number of completed GC cycles.", "counter", func() float64 { return float64(m.NumGC) }},
+		{"go_gc_pause_seconds_total", "Total time the GC stopped the world.", "counter", func() float64 { return float64(m.PauseTotalNs) / float64(time.Second) }},
+	}
+	for _, s := range samples {
+		s.Collect(w)
+	}
+}
+
+// RegisterDBStats registers the sql_ metrics of a connection pool.
+func RegisterDBStats(r *Registry, stats func() sql.DBStats) {
+	r.Register(dbStatsCollector{stats})
+}
+
+type dbStatsCollector struct {
+	stats func() sql.DBStats
+}
+
+func (c dbStatsCollector) Collect(w io.Writer) {
+	s := c.stats()
+	samples := []Func{
+		{"sql_max_open_connections", "Maximum number of open connections to the database.", "gauge", func() float64 { return float64(s.MaxOpenConnections) }},
+		{"sql_open_connections", "Number of established connections, in use and idle.", "gauge", func() float64 { return float64(s.OpenConnections) }},
+		{"sql_in_use_connections", "Number of connections currently in use.", "gauge", func() float64 { return float64(s.InUse) }},
+		{"sql_idle_connections", "Number of idle connections.", "gauge", func() float64 { return float64(s.Idle) }},
+		{"sql_wait_count_total", "Number of connections waited for.", "counter", func() float64 { return float64(s.WaitCount) }},
+		{"sql_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", "counter", func() float64 { return s.WaitDuration.Seconds() }},
+		{"sql_max_idle_closed_total", "Number of connections closed due to SetMaxIdleConns.", "counter", func() float64 { return float64(s.MaxIdleClosed) }},
+		{"sql_max_lifetime_closed_total", "Number of connections closed due to SetConnMaxLifetime.", "counter", func() float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, s := range samples {
+		s.Collect(w)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dsphub/go-simple-crud-sample/metrics"
+)
+
+func TestMetricsRoute(t *testing.T) {
+	cases := map[string]string{
+		"/posts/":                      "/posts",
+		"/posts/1":                     "/posts/{id}",
+		"/posts/new":                   "/posts/new",
+		"/posts/1/reactions":           "/posts/{id}/reactions",
+		"/attachments/ab12/thumb/64":   "/attachments/{id}/thumb/{id}",
+		"/webhooks/3/deliveries":       "/webhooks/{id}/deliveries",
+		"/auth/password-reset/confirm": "/auth/password-reset/confirm",
+		"/wp-login.php":                "other",
+		"/posts/1/2/3/4/5":             "other",
+	}
+	for path, want := range cases {
+		if got := metricsRoute(httptest.NewRequest(http.MethodGet, path, nil)); got != want {
+			t.Errorf("got route %q for %s, want %q", got, path, want)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	store := metrics.NewStoreMetrics(registry).Instrument(NewInMemoryPostStore())
+	handler := withMetrics(registry, NewPostServer(std, store))
+
+	handler.ServeHTTP(httptest.NewRecorder(), newGetPostByIDRequest(1))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assertStatus(t, response.Code, http.StatusNotFound)
+
+	response = httptest.NewRecorder()
+	metricsHandler(registry).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assertStatus(t, response.Code, http.StatusOK)
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/posts/{id}",status="200"} 1`,
+		`store_operation_duration_seconds_count{operation="GetPostByID"} 1`,
+	} {
+		if !strings.Contains(response.Body.String(), want) {
+			t.Errorf("got metrics\n%s\nwant %s", response.Body, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	ScopePostsRead  = "posts:read"
+	ScopePostsWrite = "posts:write"
+	// ScopeAdmin grants the webhooks and the /admin/ endpoints.
+	ScopeAdmin = "admin"
+)
+
+// Scopes is the set of scopes an API key may be granted.
+var Scopes = []string{ScopePostsRead, ScopePostsWrite, ScopeAdmin}
+
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyPrefix tells the API keys apart from other secrets, e.g. for
+// secret scanners.
+const apiKeyPrefix = "crud_"
+
+// APIKey grants access to the API for one tenant. The key itself is only
+// known when it is created: the store keeps its hash.
+type APIKey struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Prefix is the beginning of the key, to recognize it in listings.
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"-"`
+	Scopes    []string   `json:"scopes"`
+	TenantID  string     `json:"tenant_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewAPIKey returns a random key and its hash.
+func NewAPIKey() (key, hash string, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + hex.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hex SHA-256 of key. API keys are random and long,
+// a fast hash is enough to make a leaked table useless.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Valid reports whether the key is neither revoked nor expired at now.
+func (k APIKey) Valid(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	return HasScope(k.Scopes, scope)
+}
+
+// HasScope reports whether scopes grant scope.
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type Attachment struct {
+	ID          int       `json:"id"`
+	PostID      int       `json:"post_id"`
+	Hash        string    `json:"hash"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records a change of a post made through the API: who made it,
+// when, from where and within which request. Action is the type of the
+// matching event, e.g. EventPostUpdated, or AuditPostRestored.
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	Action    string    `json:"action"`
+	PostID    int       `json:"post_id"`
+	Actor     string    `json:"actor"`
+	IP        string    `json:"ip"`
+	RequestID string    `json:"request_id"`
+	At        time.Time `json:"at"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	// PrevHash and Hash chain the entries when the chain is enabled, see
+	// ChainHash. They are empty otherwise.
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// AuditPostRestored is the action of the updates that bring an archived
+// post back.
+const AuditPostRestored = "post.restored"
+
+// UpdateAuditAction returns the action of the update of a post from before
+// to after: AuditPostRestored if it leaves the archive, EventPostUpdated
+// otherwise.
+func UpdateAuditAction(before, after Post) string {
+	if before.Status == StatusArchived && after.Status != StatusArchived {
+		return AuditPostRestored
+	}
+	return EventPostUpdated
+}
+
+// AuditFilter selects audit entries; zero fields match every entry.
+// Entries are listed by ID, from AfterID on, Limit at a time.
+type AuditFilter struct {
+	PostID  int
+	Actor   string
+	Action  string
+	Since   time.Time
+	Until   time.Time
+	AfterID int64
+	Limit   int
+}
+
+// ChainHash returns the hex SHA-256 of the entry and PrevHash. The ID is
+// left out, as it is assigned on insertion, and At is hashed at microsecond
+// precision, the precision it is stored with.
+func (e AuditEntry) ChainHash() string {
+	fields, _ := json.Marshal([]interface{}{
+		e.PrevHash, e.Action, e.PostID, e.Actor, e.IP, e.RequestID,
+		e.At.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano), e.TenantID,
+	})
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
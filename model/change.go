@@ -0,0 +1,23 @@
+package model
+
+const (
+	ChangeUpsert = "upsert"
+	ChangeDelete = "delete"
+)
+
+// Change is an entry of the changes feed. Upserts carry the post as it is
+// now; deletions are tombstones and only carry the post ID.
+type Change struct {
+	Seq    int64  `json:"seq"`
+	Type   string `json:"type"`
+	PostID int    `json:"post_id"`
+	Post   *Post  `json:"post,omitempty"`
+}
+
+// ChangeFeed is a page of the changes feed. Checkpoint is the seq to ask for
+// the next page with.
+type ChangeFeed struct {
+	Changes    []Change `json:"changes"`
+	Checkpoint int64    `json:"checkpoint"`
+	HasMore    bool     `json:"has_more"`
+}
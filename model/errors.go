@@ -1,9 +1,29 @@
 package model
 
 const (
-	ErrorPostsAreNotFound = PostError("could not find posts")
-	ErrorPostDoesNotExist = PostError("could not find the post by id")
-	ErrorPostIsNotCreated = PostError("could not create the post")
+	ErrorPostsAreNotFound  = PostError("could not find posts")
+	ErrorPostDoesNotExist  = PostError("could not find the post by id")
+	ErrorPostIsNotCreated  = PostError("could not create the post")
+	ErrorPostStatusInvalid = PostError("invalid post status")
+
+	ErrorAttachmentDoesNotExist = PostError("could not find the attachment")
+
+	ErrorReactionInvalid = PostError("invalid reaction")
+
+	ErrorEventDoesNotExist = PostError("could not find the event")
+
+	ErrorWebhookDoesNotExist = PostError("could not find the webhook")
+	ErrorWebhookInvalid      = PostError("invalid webhook")
+
+	ErrorTenantMissing = PostError("tenant is missing")
+	ErrorTenantInvalid = PostError("invalid tenant")
+
+	ErrorAPIKeyDoesNotExist = PostError("could not find the API key")
+	ErrorAPIKeyInvalid      = PostError("invalid API key")
+
+	ErrorUserDoesNotExist    = PostError("could not find the user")
+	ErrorUserExists          = PostError("the email is already registered")
+	ErrorSessionDoesNotExist = PostError("could not find the session")
 )
 
 type PostError string
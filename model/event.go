@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	EventPostCreated = "post.created"
+	EventPostUpdated = "post.updated"
+	EventPostDeleted = "post.deleted"
+)
+
+// Event describes a change of a post. Before is empty for created posts
+// and After is empty for deleted ones. Seq orders the events of a tenant by
+// commit, the stream resumes from it.
+type Event struct {
+	ID        int64     `json:"id"`
+	Seq       int64     `json:"seq"`
+	Type      string    `json:"type"`
+	PostID    int       `json:"post_id"`
+	Before    *Post     `json:"before"`
+	After     *Post     `json:"after"`
+	CreatedAt time.Time `json:"created_at"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	// Traceparent is the trace context of the change, if it was traced.
+	Traceparent string `json:"-"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// IdempotencyRecord is the outcome of the first request made with an
+// Idempotency-Key. Status is 0 while that request is in progress.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	Status      int
+	Body        []byte
+	ExpiresAt   time.Time
+}
@@ -1,7 +1,34 @@
 package model
 
+import "time"
+
+type PostStatus string
+
+const (
+	StatusDraft     PostStatus = "draft"
+	StatusScheduled PostStatus = "scheduled"
+	StatusPublished PostStatus = "published"
+	StatusArchived  PostStatus = "archived"
+)
+
+func (s PostStatus) Valid() bool {
+	switch s {
+	case StatusDraft, StatusScheduled, StatusPublished, StatusArchived:
+		return true
+	}
+	return false
+}
+
 type Post struct {
-	ID      int    `json:"id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	ID        int        `json:"id"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	Status    PostStatus `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// Reactions counts the reactions by emoji.
+	Reactions map[string]int `json:"reactions,omitempty"`
+	Views     int64          `json:"views"`
+	TenantID  string         `json:"tenant_id,omitempty"`
+	// AuthorID identifies who created the post, see requestActor.
+	AuthorID string `json:"author_id,omitempty"`
 }
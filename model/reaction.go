@@ -0,0 +1,13 @@
+package model
+
+// Reactions is the fixed set of emoji a post can be reacted to with.
+var Reactions = []string{"👍", "👎", "❤️", "🎉", "😄", "😮", "😢"}
+
+func ValidReaction(emoji string) bool {
+	for _, r := range Reactions {
+		if r == emoji {
+			return true
+		}
+	}
+	return false
+}
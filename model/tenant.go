@@ -0,0 +1,24 @@
+package model
+
+// DefaultTenant owns the posts created without a tenant, e.g. by
+// deployments that are not multi-tenant.
+const DefaultTenant = "default"
+
+// maxTenantLength is the size of the tenant_id columns.
+const maxTenantLength = 64
+
+// ValidTenant accepts the tenant IDs made of 1 to 64 ASCII letters, digits,
+// dashes and underscores.
+func ValidTenant(tenant string) bool {
+	if tenant == "" || len(tenant) > maxTenantLength {
+		return false
+	}
+	for _, c := range tenant {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 128
+	maxEmailLength    = 254
+)
+
+// User is a local account. Email is unique and kept in lower case.
+type User struct {
+	ID           int        `json:"id"`
+	Email        string     `json:"email"`
+	PasswordHash string     `json:"-"`
+	Roles        []string   `json:"roles"`
+	TenantID     string     `json:"tenant_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	VerifiedAt   *time.Time `json:"verified_at,omitempty"`
+	// FailedLogins counts the failed logins since the last successful one
+	// or lockout.
+	FailedLogins int        `json:"-"`
+	LockedUntil  *time.Time `json:"-"`
+}
+
+// Locked reports whether logins are refused at now after too many failures.
+func (u User) Locked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
+
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidEmail only checks the shape of the address: whether it is
+// deliverable is up to the mail server.
+func ValidEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	return at > 0 && at < len(email)-1 && len(email) <= maxEmailLength && !strings.ContainsAny(email, " \t\r\n")
+}
+
+func ValidPassword(password string) bool {
+	n := utf8.RuneCountInString(password)
+	return n >= MinPasswordLength && n <= MaxPasswordLength
+}
+
+// Session is a login of a user. The store keeps the hash of the token only,
+// the token is in the cookie of the client.
+type Session struct {
+	Hash      string
+	UserID    int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// NewSessionToken returns a random token and its hash.
+func NewSessionToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b)
+	return token, HashAPIKey(token), nil
+}
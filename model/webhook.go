@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+type Webhook struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// Secret signs the deliveries. It is only returned when the webhook is
+	// created.
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	TenantID   string    `json:"tenant_id,omitempty"`
+}
+
+// Subscribes reports whether the webhook wants events of eventType.
+func (w Webhook) Subscribes(eventType string) bool {
+	for _, t := range w.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Delivery is the delivery of one event to one webhook, retried until it
+// succeeds or runs out of attempts.
+type Delivery struct {
+	ID            int64             `json:"id"`
+	WebhookID     int               `json:"webhook_id"`
+	EventID       int64             `json:"event_id"`
+	EventType     string            `json:"event_type"`
+	Status        DeliveryStatus    `json:"status"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at,omitempty"`
+	Attempts      []DeliveryAttempt `json:"attempts"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+type DeliveryAttempt struct {
+	Attempt    int           `json:"attempt"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration_ns"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
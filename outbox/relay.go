@@ -0,0 +1,123 @@
+package outbox
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/health"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+const defaultBatchSize = 100
+
+// Sink receives post change events. Delivery is at least once, so a sink
+// may see an event again, e.g. when another sink of the same batch failed;
+// Event.ID identifies duplicates.
+type Sink interface {
+	Send(events []Event) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(events []Event) error
+
+func (f SinkFunc) Send(events []Event) error {
+	return f(events)
+}
+
+// Relay periodically moves pending events from the outbox to the sinks.
+type Relay struct {
+	log       *log.Logger
+	store     OutboxStore
+	sinks     []Sink
+	interval  time.Duration
+	batchSize int
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	heartbeat health.Heartbeat
+}
+
+func NewRelay(log *log.Logger, store OutboxStore, interval time.Duration, sinks ...Sink) *Relay {
+	return &Relay{
+		log:       log,
+		store:     store,
+		sinks:     sinks,
+		interval:  interval,
+		batchSize: defaultBatchSize,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs the relay loop in a background goroutine.
+func (r *Relay) Start() {
+	go r.run()
+}
+
+// Stop signals the loop to exit and waits until the current pass is over.
+func (r *Relay) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+// Heartbeat beats after every pass of the loop, failed ones included.
+func (r *Relay) Heartbeat() *health.Heartbeat {
+	return &r.heartbeat
+}
+
+func (r *Relay) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Relay(); err != nil {
+			r.log.Printf("outbox: %v", err)
+		}
+		r.heartbeat.Beat()
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Relay delivers the pending events batch by batch until the outbox is
+// drained, and returns how many were delivered. A batch that fails for any
+// sink stays pending and is retried on the next pass.
+func (r *Relay) Relay() (int, error) {
+	total := 0
+	for {
+		n, err := r.store.DeliverEvents(r.batchSize, r.send)
+		total += n
+		if err != nil || n < r.batchSize {
+			return total, err
+		}
+	}
+}
+
+func (r *Relay) send(events []Event) error {
+	for _, sink := range r.sinks {
+		if err := sink.Send(events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogSink writes every event to a logger.
+type LogSink struct {
+	Log *log.Logger
+}
+
+func (s LogSink) Send(events []Event) error {
+	for _, e := range events {
+		s.Log.Printf("event %d: %s post %d", e.ID, e.Type, e.PostID)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+var discard = log.New(ioutil.Discard, "", 0)
+
+func TestRelayDeliversEventsInOrder(t *testing.T) {
+	store := &StubPostStore{Posts: map[int]Post{}}
+	post, _ := store.CreatePost(Post{Title: "title", Content: "text", Status: StatusDraft})
+	post.Status = StatusPublished
+	store.UpdatePost(post)
+	store.DeletePost(post.ID)
+	var got []string
+	sink := SinkFunc(func(events []Event) error {
+		for _, e := range events {
+			got = append(got, e.Type)
+		}
+		return nil
+	})
+	relay := NewRelay(discard, store, time.Minute, sink)
+	relay.batchSize = 2
+
+	n, err := relay.Relay()
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, n)
+	}
+	assert.Equal(t, []string{EventPostCreated, EventPostUpdated, EventPostDeleted}, got)
+}
+
+func TestRelayRetriesFailedBatches(t *testing.T) {
+	store := &StubPostStore{Posts: map[int]Post{}}
+	store.CreatePost(Post{Title: "title", Content: "text", Status: StatusDraft})
+	fail := true
+	var delivered []int64
+	sink := SinkFunc(func(events []Event) error {
+		if fail {
+			return errors.New("sink is down")
+		}
+		for _, e := range events {
+			delivered = append(delivered, e.ID)
+		}
+		return nil
+	})
+	relay := NewRelay(discard, store, time.Minute, LogSink{discard}, sink)
+
+	_, err := relay.Relay()
+	assert.Error(t, err)
+	fail = false
+	n, err := relay.Relay()
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, n)
+	}
+	assert.Equal(t, []int64{1}, delivered)
+}
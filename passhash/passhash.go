@@ -0,0 +1,81 @@
+// Package passhash hashes the passwords of the users with argon2id, see
+// RFC 9106. Hashes are in the PHC string format, e.g.
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+//
+// so that they name their algorithm, its version and the parameters they
+// were made with: the parameters can be raised without voiding them.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	scheme  = "argon2id"
+	saltLen = 16
+	keyLen  = 32
+	// maxMemory bounds the memory a stored hash can make Check use, in KiB.
+	maxMemory = 1024 * 1024
+)
+
+// Params are the argon2id cost parameters. Memory is in KiB.
+type Params struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+}
+
+// DefaultParams are the parameters of the passwords hashed from now on,
+// the second recommended option of RFC 9106 but for its memory size.
+var DefaultParams = Params{Memory: 64 * 1024, Time: 3, Threads: 2}
+
+// Hash returns the argon2id hash of password with a random salt and the
+// DefaultParams.
+func Hash(password string) (string, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	params := DefaultParams
+	key := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, keyLen)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", scheme, argon2.Version,
+		params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Check reports whether password matches hash, in constant time. Hashes of
+// other algorithms or versions never match.
+func Check(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != scheme {
+		return false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var params Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return false
+	}
+	if params.Memory == 0 || params.Memory > maxMemory || params.Time == 0 || params.Threads == 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	got := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
@@ -0,0 +1,43 @@
+package passhash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash(t *testing.T) {
+	DefaultParams = Params{Memory: 64, Time: 1, Threads: 1}
+	hash, err := Hash("correct horse")
+
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+		assert.True(t, Check(hash, "correct horse"))
+		assert.False(t, Check(hash, "battery staple"))
+	}
+
+	other, _ := Hash("correct horse")
+	assert.NotEqual(t, hash, other, "hashes are salted")
+
+	t.Run("check the hashes made with other parameters", func(t *testing.T) {
+		DefaultParams = Params{Memory: 128, Time: 2, Threads: 1}
+
+		assert.True(t, Check(hash, "correct horse"))
+	})
+}
+
+func TestCheckRejectsInvalidHashes(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"pbkdf2-sha256$600000$c2FsdA$a2V5",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=4194304,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$",
+		"$argon2id$v=19$m=64,t=1,p=1$!$a2V5",
+	} {
+		assert.False(t, Check(hash, ""), hash)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/url"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// newPostFromForm builds a post from the create form. Without an explicit
+// status a post is published right away, or scheduled when publish_at lies
+// in the future.
+func newPostFromForm(form url.Values, now time.Time) (Post, error) {
+	post := Post{Title: form.Get("title"), Content: form.Get("text")}
+	return applyPostStatus(post, form, now)
+}
+
+// applyPostForm overwrites the fields of post present in the update form.
+func applyPostForm(post Post, form url.Values, now time.Time) (Post, error) {
+	if _, ok := form["title"]; ok {
+		post.Title = form.Get("title")
+	}
+	if _, ok := form["text"]; ok {
+		post.Content = form.Get("text")
+	}
+	return applyPostStatus(post, form, now)
+}
+
+func applyPostStatus(post Post, form url.Values, now time.Time) (Post, error) {
+	if value := form.Get("publish_at"); value != "" {
+		publishAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return post, ErrorPostStatusInvalid
+		}
+		post.PublishAt = &publishAt
+	}
+	if value := form.Get("status"); value != "" {
+		post.Status = PostStatus(value)
+	}
+	if post.Status == "" {
+		post.Status = StatusPublished
+		if post.PublishAt != nil && post.PublishAt.After(now) {
+			post.Status = StatusScheduled
+		}
+	}
+
+	switch {
+	case !post.Status.Valid():
+		return post, ErrorPostStatusInvalid
+	case post.Status == StatusScheduled && post.PublishAt == nil:
+		return post, ErrorPostStatusInvalid
+	case post.Status == StatusPublished && post.PublishAt == nil:
+		post.PublishAt = &now
+	}
+	return post, nil
+}
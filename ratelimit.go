@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/ratelimit"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// WithRateLimits limits the requests of every client, identified by its
+// verified credential or else its IP, by the rule matching the route.
+func WithRateLimits(limiter ratelimit.Limiter, rules ratelimit.Rules) ServerOption {
+	return func(p *PostServer) {
+		p.limiter = limiter
+		p.rateLimits = rules
+	}
+}
+
+// credentialAttempts is how many times the limit of a route a client IP may
+// present credentials at before they are verified: generous enough for the
+// clients sharing an IP, but bounding the lookups of made up credentials.
+const credentialAttempts = 10
+
+// rateLimited sets the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers and answers 429 with Retry-After to the clients
+// out of tokens. It runs after authenticated, so that clients are only
+// counted by the credentials that were verified, see rateLimitClient.
+func (p *PostServer) rateLimited(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := p.rateLimits.Match(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limit, class := rule.Limit(r.Method)
+		if p.takeToken(w, r, p.limiter, rateLimitClient(r)+" "+class+" "+rule.Prefix, limit, true) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// allowCredentialAttempt counts, by client IP, the requests whose credential
+// is about to be verified, so that made up credentials can neither escape
+// the limits nor cost unbounded lookups.
+func (p *PostServer) allowCredentialAttempt(w http.ResponseWriter, r *http.Request) bool {
+	if p.limiter == nil {
+		return true
+	}
+	rule, ok := p.rateLimits.Match(r.URL.Path)
+	if !ok {
+		return true
+	}
+	limit, class := rule.Limit(r.Method)
+	limit = ratelimit.Limit{Rate: limit.Rate * credentialAttempts, Burst: limit.Burst * credentialAttempts}
+	return p.takeToken(w, r, p.limiter, "ip:"+clientIP(r)+" credential "+class+" "+rule.Prefix, limit, false)
+}
+
+// takeToken takes a token from the bucket of key in limiter, answering 429 with
+// Retry-After and returning false if there is none left. Requests are let
+// through when the limiter fails: a broken limiter must not take the service
+// down.
+func (p *PostServer) takeToken(w http.ResponseWriter, r *http.Request, limiter ratelimit.Limiter, key string, limit ratelimit.Limit, headers bool) bool {
+	result, err := limiter.Take(key, limit)
+	if err != nil {
+		p.requestLog(r).Error("can't rate limit", "err", err)
+		return true
+	}
+
+	if headers {
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", ceilSeconds(result.Reset))
+	}
+	if !result.Allowed {
+		w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// rateLimitClient identifies the client a request is counted for: the API
+// key, the token subject or the account it was authenticated with, else its
+// IP. Unverified headers are ignored, a client could otherwise get a fresh
+// bucket with every request.
+func rateLimitClient(r *http.Request) string {
+	if key, ok := requestAPIKey(r); ok {
+		return "key:" + strconv.Itoa(key.ID)
+	}
+	if claims, ok := requestClaims(r); ok {
+		return "sub:" + claims.Subject
+	}
+	if user, ok := requestAccount(r); ok {
+		return accountID(user)
+	}
+	return "ip:" + clientIP(r)
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
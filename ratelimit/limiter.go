@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+// Limit is a token bucket: it holds up to Burst tokens and is refilled with
+// Rate tokens per second. Every request takes a token.
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Result tells whether a request was allowed and what is left of its
+// bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token, for denied requests.
+	RetryAfter time.Duration
+}
+
+// Limiter takes a token from the bucket identified by key.
+type Limiter interface {
+	Take(key string, limit Limit) (Result, error)
+}
+
+// newResult describes a bucket left with tokens after a take.
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	r := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(0, s) * float64(time.Second))
+}
+
+// refill returns the tokens of a bucket that held tokens elapsed ago.
+func refill(limit Limit, tokens float64, elapsed time.Duration) float64 {
+	return math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.Rate)
+}
+
+// MemoryLimiter keeps the buckets in memory, for a single instance.
+type MemoryLimiter struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPurge time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// purgeInterval is how often the buckets that are full again, i.e. those
+// of idle clients, are dropped.
+const purgeInterval = time.Minute
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+func (m *MemoryLimiter) Take(key string, limit Limit) (Result, error) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastPurge) >= purgeInterval {
+		m.purge(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = refill(limit, b.tokens, now.Sub(b.updated))
+	b.updated = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	result := newResult(limit, b.tokens, allowed)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+func (m *MemoryLimiter) purge(now time.Time) {
+	for key, b := range m.buckets {
+		if !b.full.After(now) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastPurge = now
+}
+
+// StoreLimiter keeps the buckets in a store shared by several instances.
+type StoreLimiter struct {
+	store RateLimitStore
+	now   func() time.Time
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+func NewStoreLimiter(store RateLimitStore) *StoreLimiter {
+	return &StoreLimiter{store: store, now: time.Now}
+}
+
+// Take also drops the buckets that are full again, once in a while.
+func (s *StoreLimiter) Take(key string, limit Limit) (Result, error) {
+	if s.shouldPurge() {
+		if _, err := s.store.PurgeRateLimitBuckets(); err != nil {
+			return Result{}, err
+		}
+	}
+	tokens, allowed, err := s.store.TakeRateLimitToken(key, limit.Rate, limit.Burst)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(limit, tokens, allowed), nil
+}
+
+func (s *StoreLimiter) shouldPurge() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastPurge) < purgeInterval {
+		return false
+	}
+	s.lastPurge = now
+	return true
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func TestMemoryLimiterRefillsBuckets(t *testing.T) {
+	c := &clock{time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := NewMemoryLimiter()
+	limiter.now = c.Now
+	limit := Limit{Rate: 1, Burst: 2}
+
+	first, _ := limiter.Take("client", limit)
+	second, _ := limiter.Take("client", limit)
+	denied, _ := limiter.Take("client", limit)
+
+	assert.True(t, first.Allowed)
+	assert.Equal(t, 1, first.Remaining)
+	assert.True(t, second.Allowed)
+	assert.Equal(t, 2*time.Second, second.Reset)
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, time.Second, denied.RetryAfter)
+
+	other, _ := limiter.Take("other", limit)
+	assert.True(t, other.Allowed, "buckets are per key")
+
+	c.now = c.now.Add(time.Second)
+	again, _ := limiter.Take("client", limit)
+	assert.True(t, again.Allowed, "a token is refilled every second")
+}
+
+func TestMemoryLimiterPurgesFullBuckets(t *testing.T) {
+	c := &clock{time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := NewMemoryLimiter()
+	limiter.now = c.Now
+	limiter.Take("idle", Limit{Rate: 1, Burst: 2})
+
+	c.now = c.now.Add(purgeInterval)
+	limiter.Take("busy", Limit{Rate: 1, Burst: 2})
+
+	assert.Len(t, limiter.buckets, 1)
+	assert.Contains(t, limiter.buckets, "busy")
+}
+
+type fakeRateLimitStore struct {
+	tokens  float64
+	allowed bool
+	err     error
+	purges  int
+}
+
+func (s *fakeRateLimitStore) TakeRateLimitToken(key string, rate float64, burst int) (float64, bool, error) {
+	return s.tokens, s.allowed, s.err
+}
+
+func (s *fakeRateLimitStore) PurgeRateLimitBuckets() (int, error) {
+	s.purges++
+	return 0, nil
+}
+
+func TestStoreLimiter(t *testing.T) {
+	c := &clock{time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)}
+	store := &fakeRateLimitStore{tokens: 0.5}
+	limiter := NewStoreLimiter(store)
+	limiter.now = c.Now
+
+	result, err := limiter.Take("client", Limit{Rate: 2, Burst: 4})
+	limiter.Take("client", Limit{Rate: 2, Burst: 4})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, Result{Limit: 4, Reset: 1750 * time.Millisecond, RetryAfter: 250 * time.Millisecond}, result)
+	}
+	assert.Equal(t, 1, store.purges, "buckets are purged once per interval")
+
+	store.err = errors.New("database is down")
+	_, err = limiter.Take("client", Limit{Rate: 2, Burst: 4})
+	assert.Error(t, err)
+}
+
+func TestRules(t *testing.T) {
+	rules := Rules{
+		{Prefix: "/", Read: Limit{Rate: 10, Burst: 10}, Write: Limit{Rate: 1, Burst: 1}},
+		{Prefix: "/attachments/", Read: Limit{Rate: 50, Burst: 100}, Write: Limit{Rate: 1, Burst: 5}},
+	}
+
+	rule, ok := rules.Match("/attachments/abc")
+	assert.True(t, ok)
+	assert.Equal(t, "/attachments/", rule.Prefix, "the longest prefix wins")
+	limit, class := rule.Limit("HEAD")
+	assert.Equal(t, Limit{Rate: 50, Burst: 100}, limit)
+	assert.Equal(t, "read", class)
+
+	rule, _ = rules.Match("/posts/1")
+	limit, class = rule.Limit("PUT")
+	assert.Equal(t, Limit{Rate: 1, Burst: 1}, limit)
+	assert.Equal(t, "write", class)
+
+	_, ok = Rules{{Prefix: "/posts/"}}.Match("/webhooks/")
+	assert.False(t, ok)
+}
+
+func TestLoadRules(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "ratelimit")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "limits.json")
+
+	ioutil.WriteFile(path, []byte(`[{"prefix": "/", "read": {"rate": 5, "burst": 10}, "write": {"rate": 0.5, "burst": 2}}]`), 0644)
+	rules, err := LoadRules(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Rules{{Prefix: "/", Read: Limit{Rate: 5, Burst: 10}, Write: Limit{Rate: 0.5, Burst: 2}}}, rules)
+	}
+
+	ioutil.WriteFile(path, []byte(`[{"prefix": "/", "read": {"rate": 5, "burst": 0}, "write": {"rate": 1, "burst": 1}}]`), 0644)
+	_, err = LoadRules(path)
+	assert.Error(t, err)
+}
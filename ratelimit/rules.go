@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Rule sets the limits of the routes under Prefix. Read limits the GET,
+// HEAD and OPTIONS requests, Write limits the others; each has its own
+// bucket.
+type Rule struct {
+	Prefix string `json:"prefix"`
+	Read   Limit  `json:"read"`
+	Write  Limit  `json:"write"`
+}
+
+// Limit returns the limit of requests made with method.
+func (r Rule) Limit(method string) (Limit, string) {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return r.Read, "read"
+	}
+	return r.Write, "write"
+}
+
+type Rules []Rule
+
+// DefaultRules limit every route alike.
+var DefaultRules = Rules{
+	{Prefix: "/", Read: Limit{Rate: 20, Burst: 40}, Write: Limit{Rate: 2, Burst: 10}},
+}
+
+// Match returns the rule with the longest prefix of path.
+func (rules Rules) Match(path string) (Rule, bool) {
+	var match Rule
+	found := false
+	for _, rule := range rules {
+		if strings.HasPrefix(path, rule.Prefix) && (!found || len(rule.Prefix) > len(match.Prefix)) {
+			match = rule
+			found = true
+		}
+	}
+	return match, found
+}
+
+func (rules Rules) Validate() error {
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.Prefix, "/") {
+			return errors.Errorf("invalid prefix %q", rule.Prefix)
+		}
+		for _, limit := range []Limit{rule.Read, rule.Write} {
+			if limit.Rate <= 0 || limit.Burst < 1 {
+				return errors.Errorf("%s: rate must be positive and burst at least 1", rule.Prefix)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadRules reads the rules from a JSON file, e.g.
+//
+//	[{"prefix": "/", "read": {"rate": 20, "burst": 40}, "write": {"rate": 2, "burst": 10}},
+//	 {"prefix": "/attachments/", "read": {"rate": 50, "burst": 100}, "write": {"rate": 1, "burst": 5}}]
+func LoadRules(path string) (Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules Rules
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return nil, errors.Wrapf(err, "can't read rate limits from %s", path)
+	}
+	return rules, errors.Wrapf(rules.Validate(), "invalid rate limits in %s", path)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/dsphub/go-simple-crud-sample/ratelimit"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+type failingLimiter struct{}
+
+func (failingLimiter) Take(key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("database is down")
+}
+
+func TestRateLimits(t *testing.T) {
+	store := NewInMemoryPostStore()
+	keys := &StubAPIKeyStore{}
+	key := keys.AddKey(DefaultTenant, ScopePostsRead, ScopePostsWrite)
+	rules := ratelimit.Rules{{Prefix: "/posts/", Read: ratelimit.Limit{Rate: 1, Burst: 2}, Write: ratelimit.Limit{Rate: 1, Burst: 1}}}
+	server := NewPostServer(std, store, WithRateLimits(ratelimit.NewMemoryLimiter(), rules), WithAPIKeys(keys))
+
+	get := func(remoteAddr, apiKey string) *httptest.ResponseRecorder {
+		request := newGetAllPostsRequest()
+		request.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			request.Header.Set(apiKeyHeader, apiKey)
+		}
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("limit reads per client", func(t *testing.T) {
+		first := get("10.0.0.1:1000", "")
+		assertStatus(t, first.Code, http.StatusOK)
+		if first.Header().Get("RateLimit-Limit") != "2" || first.Header().Get("RateLimit-Remaining") != "1" {
+			t.Errorf("got headers %v", first.Header())
+		}
+		get("10.0.0.1:1001", "")
+
+		denied := get("10.0.0.1:1002", "")
+
+		assertStatus(t, denied.Code, http.StatusTooManyRequests)
+		if denied.Header().Get("Retry-After") != "1" {
+			t.Errorf("got headers %v", denied.Header())
+		}
+		assertStatus(t, get("10.0.0.2:1000", "").Code, http.StatusOK)
+	})
+
+	t.Run("count API keys apart from their IP", func(t *testing.T) {
+		assertStatus(t, get("10.0.0.1:1003", key).Code, http.StatusOK)
+	})
+
+	t.Run("count unknown API keys by their IP", func(t *testing.T) {
+		for i := 0; i < 2*credentialAttempts; i++ {
+			get("10.0.0.3:1000", "made-up-"+strconv.Itoa(i))
+		}
+
+		denied := get("10.0.0.3:1000", "made-up")
+
+		assertStatus(t, denied.Code, http.StatusTooManyRequests)
+	})
+
+	t.Run("writes have their own budget", func(t *testing.T) {
+		create := func() int {
+			request := newCreatePostRequest("title", "text")
+			request.RemoteAddr = "10.0.0.1:1004"
+			request.Header.Set(apiKeyHeader, key)
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+			return response.Code
+		}
+
+		assertStatus(t, create(), http.StatusCreated)
+		assertStatus(t, create(), http.StatusTooManyRequests)
+	})
+
+	t.Run("routes without a rule are not limited", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/unknown", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		if response.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("got headers %v", response.Header())
+		}
+	})
+}
+
+func TestRateLimitsLetRequestsThroughOnLimiterFailure(t *testing.T) {
+	server := NewPostServer(std, NewInMemoryPostStore(), WithRateLimits(failingLimiter{}, ratelimit.DefaultRules))
+	response := httptest.NewRecorder()
+
+	server.ServeHTTP(response, newGetAllPostsRequest())
+
+	assertStatus(t, response.Code, http.StatusOK)
+}
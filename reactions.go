@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+const userHeader = "X-User-ID"
+
+// WithReactions enables POST and DELETE on /posts/{id}/reactions.
+func WithReactions(reactions ReactionStore) ServerOption {
+	return func(p *PostServer) {
+		p.reactions = reactions
+	}
+}
+
+// requestUser identifies the user a request is made on behalf of: the
+// principal of its credential, see requestActor. Servers without
+// authentication trust the user of the X-User-ID header instead, the others
+// ignore it.
+func (p *PostServer) requestUser(r *http.Request) string {
+	if actor := requestActor(r); actor != "" || p.authenticates() {
+		return actor
+	}
+	return r.Header.Get(userHeader)
+}
+
+func (p *PostServer) postReactionsHandler(w http.ResponseWriter, r *http.Request, postID int) {
+	user := p.requestUser(r)
+	if user == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var counts map[string]int
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		r.ParseForm()
+		counts, err = p.reactions.React(postID, user, r.Form.Get("emoji"))
+	case http.MethodDelete:
+		counts, err = p.reactions.Unreact(postID, user)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch err {
+	case nil:
+		if counts == nil {
+			counts = map[string]int{}
+		}
+		setResponseContentTypeAsJSON(w)
+		json.NewEncoder(w).Encode(counts)
+	case ErrorReactionInvalid:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	case ErrorPostDoesNotExist:
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		p.log.Error("can't change reaction", "post", postID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
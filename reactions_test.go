@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+func TestReactions(t *testing.T) {
+	store := NewInMemoryPostStore()
+	server := NewPostServer(std, store, WithReactions(store))
+
+	t.Run("one reaction per user", func(t *testing.T) {
+		server.ServeHTTP(httptest.NewRecorder(), newReactionRequest(http.MethodPost, 1, "alice", "👍"))
+		server.ServeHTTP(httptest.NewRecorder(), newReactionRequest(http.MethodPost, 1, "bob", "👍"))
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newReactionRequest(http.MethodPost, 1, "alice", "🎉"))
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertReactionCounts(t, map[string]int{"👍": 1, "🎉": 1}, getReactionCountsFromResponse(t, response))
+	})
+
+	t.Run("counts are embedded in the post", func(t *testing.T) {
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newGetPostByIDRequest(1))
+
+		got := getSinglePostFromResponse(t, response.Body)
+		assertReactionCounts(t, map[string]int{"👍": 1, "🎉": 1}, got.Reactions)
+	})
+
+	t.Run("remove the reaction", func(t *testing.T) {
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newReactionRequest(http.MethodDelete, 1, "alice", ""))
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertReactionCounts(t, map[string]int{"👍": 1}, getReactionCountsFromResponse(t, response))
+	})
+
+	t.Run("return 422 on unknown emoji", func(t *testing.T) {
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newReactionRequest(http.MethodPost, 1, "alice", "🦄"))
+
+		assertStatus(t, response.Code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("return 401 on anonymous reaction", func(t *testing.T) {
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newReactionRequest(http.MethodPost, 1, "", "👍"))
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("return 404 on missing post", func(t *testing.T) {
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newReactionRequest(http.MethodPost, 2, "alice", "👍"))
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+}
+
+func TestReactionsByCredential(t *testing.T) {
+	store := NewInMemoryPostStore()
+	keys := &StubAPIKeyStore{}
+	key := keys.AddKey(DefaultTenant, ScopePostsRead, ScopePostsWrite)
+	server := NewPostServer(std, store, WithAPIKeys(keys), WithReactions(store))
+
+	t.Run("react as the principal of the API key", func(t *testing.T) {
+		request := newReactionRequest(http.MethodPost, 1, "", "👍")
+		request.Header.Set(apiKeyHeader, key)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if got := store.Reactions[1]["apikey:1"]; got != "👍" {
+			t.Errorf("got reactions %v, want one by apikey:1", store.Reactions[1])
+		}
+	})
+
+	t.Run("ignore the user header", func(t *testing.T) {
+		request := newReactionRequest(http.MethodDelete, 1, "mallory", "")
+		request.Header.Set(apiKeyHeader, key)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertReactionCounts(t, map[string]int{}, getReactionCountsFromResponse(t, response))
+	})
+}
+
+func newReactionRequest(method string, postID int, user, emoji string) *http.Request {
+	data := url.Values{"emoji": {emoji}}
+	request, _ := http.NewRequest(method, fmt.Sprintf("/posts/%d/reactions?%s", postID, data.Encode()), nil)
+	if user != "" {
+		request.Header.Set(userHeader, user)
+	}
+	return request
+}
+
+func getReactionCountsFromResponse(t *testing.T, response *httptest.ResponseRecorder) (counts map[string]int) {
+	t.Helper()
+	if err := json.NewDecoder(response.Body).Decode(&counts); err != nil {
+		t.Fatalf("Unable to parse response from server into reaction counts, '%v'", err)
+	}
+	return
+}
+
+func assertReactionCounts(t *testing.T, want, got map[string]int) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got reactions %v want %v", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/logging"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+	"github.com/dsphub/go-simple-crud-sample/trace"
+)
+
+const (
+	requestIDHeader                = "X-Request-ID"
+	requestIDContextKey contextKey = "request-id"
+	maxRequestIDLength             = 64
+)
+
+// requestID returns the ID the request is served with, see logged.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// validRequestID accepts the IDs that are safe to log and to hand to the
+// database unquoted, e.g. UUIDs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestLog returns the logger of the request, which adds its ID to every
+// record.
+func (p *PostServer) requestLog(r *http.Request) *logging.Logger {
+	if log := logging.FromContext(r.Context()); log != nil {
+		return log
+	}
+	return p.log
+}
+
+// logged serves every request with an ID, the one of its X-Request-ID header
+// if valid or else a new one, which is echoed in the response. Once the
+// request is served, it logs the access.
+func (p *PostServer) logged(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		log := p.log.With("request_id", id)
+		if span := trace.FromContext(r.Context()); span != nil {
+			log = log.With("trace_id", span.Context().TraceID.String())
+		}
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(logging.NewContext(ctx, log)))
+
+		level := logging.Info
+		if sw.status >= http.StatusInternalServerError {
+			level = logging.Error
+		}
+		log.Log(level, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.Status(),
+			"bytes", sw.bytes,
+			"latency_ms", float64(time.Since(start))/float64(time.Millisecond),
+			"client", clientIP(r))
+	})
+}
+
+// forRequest returns a copy of the server logging with the ID of the request
+// and whose stores tag their transactions with it, when they can. The post
+// store audits the changes of the request, if audited, and records its
+// operations in the span of the request, if traced.
+func (p *PostServer) forRequest(r *http.Request) *PostServer {
+	id := requestID(r)
+	if id == "" {
+		return p
+	}
+	scoped := p.withStores(func(store interface{}) interface{} {
+		if s, ok := store.(RequestScoper); ok {
+			return s.ForRequest(id)
+		}
+		return store
+	})
+	scoped.log = p.requestLog(r)
+	if p.audit != nil {
+		scoped.store = scoped.store.(AuditScoper).ForAudit(requestAuditEntry(r), p.auditChain).(PostStore)
+	}
+	scoped.store = trace.InstrumentStore(r.Context(), scoped.store)
+	return scoped
+}
+
+// statusWriter records the status and the size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Flush lets the event stream through.
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
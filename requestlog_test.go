@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dsphub/go-simple-crud-sample/logging"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+type failingPostStore struct {
+	*StubPostStore
+}
+
+func (s failingPostStore) GetAllPosts() ([]Post, error) {
+	return nil, errors.New("connection refused")
+}
+
+func logRecords(t *testing.T, b *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("can't parse log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestRequestLog(t *testing.T) {
+	t.Run("log the access with the request ID", func(t *testing.T) {
+		var b bytes.Buffer
+		server := NewPostServer(logging.New(&b, logging.JSON, logging.Info), NewInMemoryPostStore())
+		request := newGetPostByIDRequest(1)
+		request.Header.Set(requestIDHeader, "req-1")
+		request.RemoteAddr = "10.0.0.1:4321"
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if got := response.Header().Get(requestIDHeader); got != "req-1" {
+			t.Errorf("got request ID %q, want req-1", got)
+		}
+		records := logRecords(t, &b)
+		if len(records) != 1 {
+			t.Fatalf("got records %v, want the access", records)
+		}
+		access := records[0]
+		want := map[string]interface{}{
+			"level":      "info",
+			"msg":        "request",
+			"request_id": "req-1",
+			"method":     http.MethodGet,
+			"path":       "/posts/1",
+			"status":     float64(http.StatusOK),
+			"bytes":      float64(response.Body.Len()),
+			"client":     "10.0.0.1",
+		}
+		for key, value := range want {
+			if access[key] != value {
+				t.Errorf("got %s %v, want %v", key, access[key], value)
+			}
+		}
+		if _, ok := access["latency_ms"].(float64); !ok {
+			t.Errorf("got access %v, want the latency", access)
+		}
+	})
+
+	t.Run("replace an invalid request ID", func(t *testing.T) {
+		server := NewPostServer(logging.Discard, NewInMemoryPostStore())
+		request := newGetPostByIDRequest(1)
+		request.Header.Set(requestIDHeader, "req 1\nlevel=error")
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		got := response.Header().Get(requestIDHeader)
+		if !validRequestID(got) || got == request.Header.Get(requestIDHeader) {
+			t.Errorf("got request ID %q, want a new one", got)
+		}
+	})
+
+	t.Run("log the errors of a request with its ID", func(t *testing.T) {
+		var b bytes.Buffer
+		server := NewPostServer(logging.New(&b, logging.JSON, logging.Warn), failingPostStore{NewInMemoryPostStore()})
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newGetAllPostsRequest())
+
+		records := logRecords(t, &b)
+		if len(records) != 1 {
+			t.Fatalf("got records %v, want the error", records)
+		}
+		if records[0]["err"] != "connection refused" || records[0]["request_id"] != response.Header().Get(requestIDHeader) {
+			t.Errorf("got record %v", records[0])
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dsphub/go-simple-crud-sample/authz"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// rolesClaim is the claim of the roles a token grants.
+const rolesClaim = "roles"
+
+// scopeRoles are the roles granted by the scopes of an API key.
+var scopeRoles = map[string]authz.Role{
+	ScopePostsRead:  authz.Reader,
+	ScopePostsWrite: authz.Author,
+	ScopeAdmin:      authz.Admin,
+}
+
+// WithPolicy authorizes every operation on posts with policy, see
+// authz.Policy. Callers get the roles of their bearer token, API key or
+// user, on top of the roles of anonymous callers.
+func WithPolicy(policy authz.Policy) ServerOption {
+	return func(p *PostServer) {
+		p.policy = &policy
+	}
+}
+
+// requestSubject returns the caller of the request and its roles.
+func requestSubject(r *http.Request, policy *authz.Policy) authz.Subject {
+	subject := authz.Subject{Roles: append([]authz.Role{}, policy.Anonymous...)}
+	if key, ok := requestAPIKey(r); ok {
+		subject.ID = requestActor(r)
+		for _, scope := range key.Scopes {
+			if role, ok := scopeRoles[scope]; ok {
+				subject.Roles = append(subject.Roles, role)
+			}
+		}
+	} else if claims, ok := requestClaims(r); ok {
+		subject.ID = requestActor(r)
+		for _, role := range claims.Strings(rolesClaim) {
+			subject.Roles = append(subject.Roles, authz.Role(role))
+		}
+	} else if user, ok := requestAccount(r); ok {
+		subject.ID = requestActor(r)
+		for _, role := range user.Roles {
+			subject.Roles = append(subject.Roles, authz.Role(role))
+		}
+	}
+	return subject
+}
+
+// postsOperation returns the operation a request to /posts/ performs, and
+// the ID of its post if any.
+func postsOperation(r *http.Request) (authz.Operation, int) {
+	path := strings.SplitN(r.URL.Path[len("/posts/"):], "/", 2)
+	id, _ := strconv.Atoi(path[0])
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+	switch {
+	case len(path) == 2 && path[1] == "reactions":
+		return authz.ReactToPost, id
+	case len(path) == 2 && !read:
+		return authz.AttachToPost, id
+	case read:
+		return authz.ReadPosts, id
+	case r.Method == http.MethodPost:
+		return authz.CreatePost, 0
+	case r.Method == http.MethodDelete:
+		return authz.DeletePost, id
+	}
+	return authz.UpdatePost, id
+}
+
+// mayEdit returns whether the caller may update post: per the policy if
+// set, else if its credential grants the posts:write scope. Anonymous
+// callers never may, even when writes need no credential.
+func (p *PostServer) mayEdit(r *http.Request, post Post) bool {
+	if p.policy == nil {
+		return HasScope(requestScopes(r), ScopePostsWrite)
+	}
+	subject := requestSubject(r, p.policy)
+	if subject.ID == "" {
+		return false
+	}
+	switch p.policy.Decide(subject, authz.UpdatePost) {
+	case authz.Allow:
+		return true
+	case authz.AllowOwner:
+		return post.AuthorID == subject.ID
+	}
+	return false
+}
+
+// mayRead returns whether the caller may read post: published posts are
+// read by anyone, the others only by the callers who may edit them.
+func (p *PostServer) mayRead(r *http.Request, post Post) bool {
+	return post.Status == StatusPublished || p.mayEdit(r, post)
+}
+
+// authorized answers 403, or 404 if the post doesn't exist, unless the
+// caller may perform op on the post.
+func (p *PostServer) authorized(w http.ResponseWriter, r *http.Request, op authz.Operation, postID int) bool {
+	if p.policy == nil {
+		return true
+	}
+	subject := requestSubject(r, p.policy)
+	switch p.policy.Decide(subject, op) {
+	case authz.Allow:
+		return true
+	case authz.AllowOwner:
+		post, err := p.store.GetPostByID(postID)
+		switch {
+		case err == ErrorPostDoesNotExist:
+			w.WriteHeader(http.StatusNotFound)
+			return false
+		case err != nil:
+			p.log.Error("can't authorize", "operation", op, "post", postID, "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return false
+		case post.AuthorID == subject.ID:
+			return true
+		}
+	}
+	w.WriteHeader(http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dsphub/go-simple-crud-sample/authz"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+func TestPolicy(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store, WithJWT(newTestVerifier()), WithPolicy(authz.DefaultPolicy))
+	store.CreatePost(Post{Title: "by alice", Content: "text", Status: StatusPublished, AuthorID: tokenActor("alice")})
+	store.CreatePost(Post{Title: "by bob", Content: "text", Status: StatusPublished, AuthorID: tokenActor("bob")})
+
+	serve := func(request *http.Request, subject string, roles ...string) int {
+		if subject != "" {
+			token := newToken(subject, ScopePostsRead+" "+ScopePostsWrite, map[string]interface{}{rolesClaim: roles})
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	t.Run("anonymous clients can read", func(t *testing.T) {
+		assertStatus(t, serve(newGetAllPostsRequest(), ""), http.StatusOK)
+	})
+
+	t.Run("readers can't write", func(t *testing.T) {
+		assertStatus(t, serve(newCreatePostRequest("title", "text"), "rita", "reader"), http.StatusForbidden)
+		assertStatus(t, serve(newUpdatePostRequest(1, "title", "text"), "rita", "reader"), http.StatusForbidden)
+	})
+
+	t.Run("authors edit their own posts only", func(t *testing.T) {
+		assertStatus(t, serve(newCreatePostRequest("title", "text"), "alice", "author"), http.StatusCreated)
+		assertStatus(t, serve(newUpdatePostRequest(1, "title", "text"), "alice", "author"), http.StatusOK)
+		assertStatus(t, serve(newUpdatePostRequest(2, "title", "text"), "alice", "author"), http.StatusForbidden)
+		assertStatus(t, serve(newDeletePostRequest(2), "alice", "author"), http.StatusForbidden)
+		assertStatus(t, serve(newDeletePostRequest(9), "alice", "author"), http.StatusNotFound)
+		assertStatus(t, serve(newDeletePostRequest(3), "alice", "author"), http.StatusNoContent)
+	})
+
+	t.Run("editors edit every post", func(t *testing.T) {
+		assertStatus(t, serve(newUpdatePostRequest(2, "title", "text"), "eve", "editor"), http.StatusOK)
+		assertStatus(t, serve(newDeletePostRequest(2), "eve", "editor"), http.StatusNoContent)
+	})
+	t.Run("only those who may edit a draft read it", func(t *testing.T) {
+		draft, _ := store.CreatePost(Post{Title: "draft", Content: "text", Status: StatusDraft, AuthorID: tokenActor("bob")})
+
+		assertStatus(t, serve(newGetPostByIDRequest(draft.ID), ""), http.StatusNotFound)
+		assertStatus(t, serve(newGetPostByIDRequest(draft.ID), "alice", "author"), http.StatusNotFound)
+		assertStatus(t, serve(newGetPostByIDRequest(draft.ID), "bob", "author"), http.StatusOK)
+		assertStatus(t, serve(newGetPostByIDRequest(draft.ID), "eve", "editor"), http.StatusOK)
+	})
+}
+
+func TestPolicyRolesOfAPIKeys(t *testing.T) {
+	keys := &StubAPIKeyStore{}
+	writer := keys.AddKey(DefaultTenant, ScopePostsRead, ScopePostsWrite)
+	store := NewInMemoryPostStore()
+	server := NewPostServer(std, store, WithAPIKeys(keys), WithPolicy(authz.DefaultPolicy))
+
+	serve := func(request *http.Request) int {
+		request.Header.Set(apiKeyHeader, writer)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	assertStatus(t, serve(newCreatePostRequest("title", "text")), http.StatusCreated)
+	assertStatus(t, serve(newUpdatePostRequest(1, "title", "text")), http.StatusForbidden)
+}
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/health"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+const defaultBatchSize = 100
+
+// Scheduler periodically publishes scheduled posts whose publish time has
+// come. Several instances may run against the same database: the store is
+// responsible for claiming every due post only once.
+type Scheduler struct {
+	log       *log.Logger
+	publisher PostPublisher
+	interval  time.Duration
+	batchSize int
+	now       func() time.Time
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	heartbeat health.Heartbeat
+}
+
+func New(log *log.Logger, publisher PostPublisher, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		log:       log,
+		publisher: publisher,
+		interval:  interval,
+		batchSize: defaultBatchSize,
+		now:       time.Now,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs the scheduler loop in a background goroutine.
+func (s *Scheduler) Start() {
+	go s.run()
+}
+
+// Stop signals the loop to exit and waits until the current pass is over.
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// Heartbeat beats after every pass of the loop, failed ones included.
+func (s *Scheduler) Heartbeat() *health.Heartbeat {
+	return &s.heartbeat
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.PublishDue(); err != nil {
+			s.log.Printf("scheduler: %v", err)
+		}
+		s.heartbeat.Beat()
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishDue publishes every post due by now and returns how many were
+// published. Posts are claimed in batches until a batch comes back short.
+func (s *Scheduler) PublishDue() (int, error) {
+	total := 0
+	for {
+		posts, err := s.publisher.PublishDuePosts(s.now(), s.batchSize)
+		if err != nil {
+			return total, err
+		}
+		for _, post := range posts {
+			s.log.Printf("scheduler: published post %d", post.ID)
+		}
+		total += len(posts)
+		if len(posts) < s.batchSize {
+			return total, nil
+		}
+	}
+}
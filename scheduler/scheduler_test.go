@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+var discard = log.New(ioutil.Discard, "", 0)
+
+func TestPublishDue(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	store := &StubPostStore{
+		Counter: 3,
+		Posts: map[int]Post{
+			1: {ID: 1, Title: "due", Content: "text", Status: StatusScheduled, PublishAt: &past},
+			2: {ID: 2, Title: "later", Content: "text", Status: StatusScheduled, PublishAt: &future},
+			3: {ID: 3, Title: "draft", Content: "text", Status: StatusDraft},
+		},
+	}
+	s := New(discard, store, time.Minute)
+	s.now = func() time.Time { return now }
+	s.batchSize = 1
+
+	n, err := s.PublishDue()
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, n)
+	}
+	assert.Equal(t, StatusPublished, store.Posts[1].Status)
+	assert.Equal(t, StatusScheduled, store.Posts[2].Status)
+	assert.Equal(t, StatusDraft, store.Posts[3].Status)
+}
+
+func TestStartAndStop(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	store := &StubPostStore{
+		Counter: 1,
+		Posts: map[int]Post{
+			1: {ID: 1, Title: "due", Content: "text", Status: StatusScheduled, PublishAt: &past},
+		},
+	}
+	s := New(discard, store, time.Hour)
+
+	s.Start()
+	s.Stop()
+
+	assert.Equal(t, StatusPublished, store.Posts[1].Status)
+	assert.False(t, s.Heartbeat().Last().IsZero(), "the pass beats")
+}
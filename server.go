@@ -2,12 +2,26 @@ package main
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dsphub/go-simple-crud-sample/authz"
+	"github.com/dsphub/go-simple-crud-sample/blob"
+	"github.com/dsphub/go-simple-crud-sample/broadcast"
+	"github.com/dsphub/go-simple-crud-sample/headers"
+	"github.com/dsphub/go-simple-crud-sample/jwt"
+	"github.com/dsphub/go-simple-crud-sample/logging"
+	"github.com/dsphub/go-simple-crud-sample/mail"
 	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/dsphub/go-simple-crud-sample/ratelimit"
 	. "github.com/dsphub/go-simple-crud-sample/store"
+	"github.com/dsphub/go-simple-crud-sample/thumbnail"
+	"github.com/dsphub/go-simple-crud-sample/trace"
+	"github.com/dsphub/go-simple-crud-sample/usertoken"
+	"github.com/dsphub/go-simple-crud-sample/views"
 )
 
 const jsonContentType = "application/json"
@@ -15,39 +29,149 @@ const jsonContentType = "application/json"
 type PostServer struct {
 	store PostStore
 	http.Handler
-	log *log.Logger
+	log *logging.Logger
+
+	attachments        AttachmentStore
+	blobs              *blob.Store
+	attachmentLimits   AttachmentLimits
+	thumbnails         *thumbnail.Generator
+	reactions          ReactionStore
+	views              *views.Counter
+	viewStore          ViewStore
+	broadcaster        *broadcast.Broadcaster
+	eventLog           EventLog
+	webhooks           WebhookStore
+	checkWebhookTarget func(rawURL string) error
+	changes            ChangeStore
+	audit              AuditLog
+	auditChain         bool
+	idempotency        IdempotencyStore
+	idempotencyTTL     time.Duration
+	limiter            ratelimit.Limiter
+	loginLimiter       ratelimit.Limiter
+	rateLimits         ratelimit.Rules
+	apiKeys            APIKeyStore
+	jwt                *jwt.Verifier
+	policy             *authz.Policy
+	users              UserStore
+	sessions           SessionStore
+	sessionTTL         time.Duration
+	mailer             mail.Mailer
+	userTokens         *usertoken.Signer
+	baseURL            string
+	cors               *headers.CORS
+	securityRules      headers.SecurityRules
+	tracer             *trace.Tracer
+
+	resolveTenant TenantResolver
+	// tenant is the tenant the stores are scoped to, see forTenant.
+	tenant string
 }
 
-func NewPostServer(log *log.Logger, store PostStore) *PostServer {
+// ServerOption enables optional features of the PostServer.
+type ServerOption func(p *PostServer)
+
+func NewPostServer(log *logging.Logger, store PostStore, options ...ServerOption) *PostServer {
 	p := new(PostServer)
 	p.log = log
 	p.store = store
+	for _, option := range options {
+		option(p)
+	}
+	if p.resolveTenant != nil {
+		p.checkTenantScopers()
+	}
 
 	router := http.NewServeMux()
-	router.Handle("/posts/", http.HandlerFunc(p.postsHandler))
+	router.Handle("/posts/", p.scoped((*PostServer).postsHandler))
+	if p.attachments != nil {
+		router.Handle("/attachments/", p.scoped((*PostServer).attachmentsHandler))
+	}
+	if p.webhooks != nil && p.authenticates() {
+		router.Handle("/webhooks/", p.adminOnly(p.scoped((*PostServer).webhooksHandler)))
+	} else if p.webhooks != nil {
+		p.log.Warn("webhooks are not served without authentication")
+	}
+	if p.audit != nil && p.authenticates() {
+		router.Handle("/admin/audit", p.adminOnly(p.scoped((*PostServer).getAuditEntries)))
+	} else if p.audit != nil {
+		p.log.Warn("the audit log is not served without authentication")
+	}
+
+	if p.users != nil {
+		router.Handle("/auth/register", p.scoped((*PostServer).register))
+		router.Handle("/auth/login", p.scoped((*PostServer).login))
+		router.Handle("/auth/logout", p.scoped((*PostServer).logout))
+	}
+	if p.users != nil && p.mailer != nil {
+		router.HandleFunc("/auth/verify", p.verifyEmail)
+		router.Handle("/auth/password-reset", p.scoped((*PostServer).requestPasswordReset))
+		router.HandleFunc("/auth/password-reset/confirm", p.resetPassword)
+	}
 
 	p.Handler = router
+	if p.limiter != nil {
+		p.Handler = p.rateLimited(p.Handler)
+	}
+	if p.authenticates() {
+		p.Handler = p.authenticated(p.Handler)
+	}
+	if p.cors != nil {
+		p.Handler = p.corsHandled(p.Handler)
+	}
+	if p.securityRules != nil {
+		p.Handler = p.securityHeaders(p.Handler)
+	}
+	p.Handler = p.logged(p.Handler)
+	if p.tracer != nil {
+		p.Handler = p.traced(p.Handler)
+	}
 	return p
 }
 
 func (p *PostServer) postsHandler(w http.ResponseWriter, r *http.Request) {
+	if op, id := postsOperation(r); !p.authorized(w, r, op, id) {
+		return
+	}
 	postID := r.URL.Path[len("/posts/"):]
+	if i := strings.Index(postID, "/"); i >= 0 {
+		id, err := strconv.Atoi(postID[:i])
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		p.postResourceHandler(w, r, id, postID[i+1:])
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
 		if postID == "" {
 			p.getAllPosts(w)
+		} else if postID == "popular" && p.views != nil {
+			p.getPopularPosts(w, r)
+		} else if postID == "events" && p.broadcaster != nil {
+			p.streamEvents(w, r)
+		} else if postID == "changes" && p.changes != nil {
+			p.getChanges(w, r)
 		} else {
 			id, err := strconv.Atoi(postID)
 			if err != nil {
 				w.WriteHeader(http.StatusUnprocessableEntity)
 				return
 			}
-			p.getPostByID(w, id)
+			p.getPostByID(w, r, id)
 		}
 	case http.MethodPost:
 		if postID == "new" {
 			r.ParseForm()
-			p.CreatePost(w, r.Form["title"][0], r.Form["text"][0]) //FIXIT title, text
+			p.idempotent(w, r, func(w http.ResponseWriter) {
+				post, err := newPostFromForm(r.Form, time.Now().UTC())
+				if err != nil {
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					return
+				}
+				p.CreatePost(w, r, post)
+			})
 		} else {
 			w.WriteHeader(http.StatusUnprocessableEntity)
 		}
@@ -61,7 +185,7 @@ func (p *PostServer) postsHandler(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusUnprocessableEntity)
 				return
 			}
-			p.UpdatePost(w, id, r.Form["title"][0], r.Form["text"][0]) //FIXIT title, text
+			p.UpdatePost(w, r, id, r.Form)
 		}
 	case http.MethodDelete:
 		if postID == "" {
@@ -72,14 +196,28 @@ func (p *PostServer) postsHandler(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusUnprocessableEntity)
 				return
 			}
-			p.DeletePost(w, id)
+			p.DeletePost(w, r, id)
 		}
 	}
 }
 
+// postResourceHandler serves the resources nested under a post,
+// i.e. /posts/{id}/{resource}.
+func (p *PostServer) postResourceHandler(w http.ResponseWriter, r *http.Request, id int, resource string) {
+	switch {
+	case resource == "attachments" && p.attachments != nil:
+		p.postAttachmentsHandler(w, r, id)
+	case resource == "reactions" && p.reactions != nil:
+		p.postReactionsHandler(w, r, id)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
 func (p *PostServer) getAllPosts(w http.ResponseWriter) {
 	posts, err := p.store.GetAllPosts()
 	if err != nil {
+		p.log.Error("can't get posts", "err", err)
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
@@ -91,38 +229,76 @@ func setResponseContentTypeAsJSON(w http.ResponseWriter) {
 	w.Header().Set("content-type", jsonContentType)
 }
 
-func (p *PostServer) getPostByID(w http.ResponseWriter, id int) {
+// getPostByID serves a post. Posts that are not published are only served
+// to the callers who may edit them, like GetAllPosts they don't exist for
+// the others.
+func (p *PostServer) getPostByID(w http.ResponseWriter, r *http.Request, id int) {
 	post, err := p.store.GetPostByID(id)
+	if err == nil && !p.mayRead(r, post) {
+		err = ErrorPostDoesNotExist
+	}
 	switch err {
 	case ErrorPostDoesNotExist:
 		w.WriteHeader(http.StatusNotFound)
 	case nil:
+		if p.views != nil {
+			p.views.Record(id)
+		}
 		setResponseContentTypeAsJSON(w)
 		json.NewEncoder(w).Encode(post)
 	default:
+		p.log.Error("can't get post", "post", id, "err", err)
 		w.WriteHeader(http.StatusNotFound)
 	}
 }
 
-func (p *PostServer) CreatePost(w http.ResponseWriter, title, text string) {
-	err := p.store.CreatePost(title, text)
-	if err != nil {
+func (p *PostServer) CreatePost(w http.ResponseWriter, r *http.Request, post Post) {
+	post.AuthorID = requestActor(r)
+	post, err := p.store.CreatePost(post)
+	if err == ErrorPostIsNotCreated {
+		p.log.Error("can't create post", "err", err)
 		w.WriteHeader(http.StatusNotFound) //FIXIT status
+		return
 	}
+	if err != nil {
+		p.log.Error("can't create post", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	setResponseContentTypeAsJSON(w)
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(post)
 }
 
-func (p *PostServer) UpdatePost(w http.ResponseWriter, id int, title, text string) {
-	err := p.store.UpdatePost(id, title, text)
+func (p *PostServer) UpdatePost(w http.ResponseWriter, r *http.Request, id int, form url.Values) {
+	post, err := p.store.GetPostByID(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	post, err = applyPostForm(post, form, time.Now().UTC())
 	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	switch err := p.store.UpdatePost(post); err {
+	case nil:
+	case ErrorPostDoesNotExist:
 		w.WriteHeader(http.StatusNotFound)
+	default:
+		p.log.Error("can't update post", "post", id, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
-func (p *PostServer) DeletePost(w http.ResponseWriter, id int) {
-	err := p.store.DeletePost(id)
-	if err != nil {
+func (p *PostServer) DeletePost(w http.ResponseWriter, r *http.Request, id int) {
+	switch err := p.store.DeletePost(id); err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case ErrorPostDoesNotExist:
 		w.WriteHeader(http.StatusNotFound)
+	default:
+		p.log.Error("can't delete post", "post", id, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
 	}
-	w.WriteHeader(http.StatusNoContent)
 }
@@ -1,33 +1,33 @@
 package main
 
 import (
-	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
+	"github.com/dsphub/go-simple-crud-sample/logging"
 	. "github.com/dsphub/go-simple-crud-sample/model"
 	. "github.com/dsphub/go-simple-crud-sample/testdata"
 )
 
 func NewInMemoryPostStore() *StubPostStore {
 	return &StubPostStore{
-		0,
-		map[int]Post{
-			1: Post{1, "title", "text"},
+		Counter: 1,
+		Posts: map[int]Post{
+			1: Post{ID: 1, Title: "title", Content: "text", Status: StatusPublished},
 		},
 	}
 }
 
 func EmptyInMemoryPostStore() *StubPostStore {
 	return &StubPostStore{
-		0,
-		map[int]Post{},
+		Counter: 0,
+		Posts:   map[int]Post{},
 	}
 }
 
-var std = log.New(os.Stderr, "", log.LstdFlags)
+var std = logging.New(os.Stderr, logging.Text, logging.Warn)
 
 func TestCreatingPostsAndRetrievingThem(t *testing.T) {
 	store := EmptyInMemoryPostStore()
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"reflect"
 	"testing"
+	"time"
 
 	. "github.com/dsphub/go-simple-crud-sample/model"
 	. "github.com/dsphub/go-simple-crud-sample/testdata"
@@ -18,10 +19,10 @@ func TestGetPosts(t *testing.T) {
 	t.Run("return all posts", func(t *testing.T) {
 		const postID = 1
 		const actualPostCount = 1
-		want := []Post{Post{postID, "title", "text"}}
+		want := []Post{Post{ID: postID, Title: "title", Content: "text", Status: StatusPublished}}
 		store := StubPostStore{
-			actualPostCount,
-			map[int]Post{
+			Counter: actualPostCount,
+			Posts: map[int]Post{
 				postID: want[0],
 			},
 		}
@@ -40,7 +41,7 @@ func TestGetPosts(t *testing.T) {
 		want := []Post{}
 		request := newGetAllPostsRequest()
 		response := httptest.NewRecorder()
-		store := StubPostStore{0, map[int]Post{}}
+		store := StubPostStore{Counter: 0, Posts: map[int]Post{}}
 		server := NewPostServer(std, &store)
 
 		server.ServeHTTP(response, request)
@@ -56,9 +57,9 @@ func TestGetPosts(t *testing.T) {
 		request := newGetPostByIDRequest(2)
 		response := httptest.NewRecorder()
 		store := StubPostStore{
-			actualPostCount,
-			map[int]Post{
-				failedID: Post{failedID, "title", "text"},
+			Counter: actualPostCount,
+			Posts: map[int]Post{
+				failedID: Post{ID: failedID, Title: "title", Content: "text", Status: StatusPublished},
 			},
 		}
 		server := NewPostServer(std, &store)
@@ -93,12 +94,12 @@ func TestGetPostByID(t *testing.T) {
 	const actualPostCount = 1
 
 	t.Run("return post by id", func(t *testing.T) {
-		want := Post{postID, "title", "text"}
+		want := Post{ID: postID, Title: "title", Content: "text", Status: StatusPublished}
 		request := newGetPostByIDRequest(postID)
 		response := httptest.NewRecorder()
 		store := StubPostStore{
-			actualPostCount,
-			map[int]Post{
+			Counter: actualPostCount,
+			Posts: map[int]Post{
 				postID: want,
 			},
 		}
@@ -122,6 +123,36 @@ func TestGetPostByID(t *testing.T) {
 
 		assertStatus(t, response.Code, http.StatusNotFound)
 	})
+
+	for _, status := range []PostStatus{StatusDraft, StatusScheduled, StatusArchived} {
+		t.Run("return 404 on "+string(status)+" post to anonymous callers", func(t *testing.T) {
+			store := StubPostStore{Counter: 1, Posts: map[int]Post{postID: {ID: postID, Title: "title", Content: "text", Status: status}}}
+			server := NewPostServer(std, &store)
+			response := httptest.NewRecorder()
+
+			server.ServeHTTP(response, newGetPostByIDRequest(postID))
+
+			assertStatus(t, response.Code, http.StatusNotFound)
+		})
+	}
+
+	t.Run("return draft to callers who may edit it", func(t *testing.T) {
+		keys := &StubAPIKeyStore{}
+		reader := keys.AddKey(DefaultTenant, ScopePostsRead)
+		writer := keys.AddKey(DefaultTenant, ScopePostsRead, ScopePostsWrite)
+		store := StubPostStore{Counter: 1, Posts: map[int]Post{postID: {ID: postID, Title: "title", Content: "text", Status: StatusDraft}}}
+		server := NewPostServer(std, &store, WithAPIKeys(keys))
+
+		for key, want := range map[string]int{reader: http.StatusNotFound, writer: http.StatusOK} {
+			request := newGetPostByIDRequest(postID)
+			request.Header.Set(apiKeyHeader, key)
+			response := httptest.NewRecorder()
+
+			server.ServeHTTP(response, request)
+
+			assertStatus(t, response.Code, want)
+		}
+	})
 }
 
 func newGetPostByIDRequest(id int) *http.Request {
@@ -134,8 +165,8 @@ func TestCreatePost(t *testing.T) {
 		const actualPostCount = 0
 		const expectedPostCount = 1
 		store := StubPostStore{
-			actualPostCount,
-			map[int]Post{},
+			Counter: actualPostCount,
+			Posts:   map[int]Post{},
 		}
 		server := NewPostServer(std, &store)
 		request := newCreatePostRequest("title", "text")
@@ -169,9 +200,9 @@ func TestUpdatePost(t *testing.T) {
 	const actualPostCount = 1
 	const expectedPostCount = 1
 	store := StubPostStore{
-		1,
-		map[int]Post{
-			postID: Post{postID, "title", "text"},
+		Counter: 1,
+		Posts: map[int]Post{
+			postID: Post{ID: postID, Title: "title", Content: "text", Status: StatusPublished},
 		},
 	}
 	server := NewPostServer(std, &store)
@@ -216,9 +247,9 @@ func TestDeletePost(t *testing.T) {
 		const actualPostCount = 1
 		const expectedPostCount = 0
 		store := StubPostStore{
-			actualPostCount,
-			map[int]Post{
-				postID: Post{postID, "title", "text"},
+			Counter: actualPostCount,
+			Posts: map[int]Post{
+				postID: Post{ID: postID, Title: "title", Content: "text", Status: StatusPublished},
 			},
 		}
 		server := NewPostServer(std, &store)
@@ -237,9 +268,9 @@ func TestDeletePost(t *testing.T) {
 		const actualPostCount = 1
 		const expectedPostCount = 1
 		store := StubPostStore{
-			actualPostCount,
-			map[int]Post{
-				postID: Post{postID, "title", "text"},
+			Counter: actualPostCount,
+			Posts: map[int]Post{
+				postID: Post{ID: postID, Title: "title", Content: "text", Status: StatusPublished},
 			},
 		}
 		server := NewPostServer(std, &store)
@@ -285,7 +316,7 @@ func assertPosts(t *testing.T, want, got []Post) {
 
 func assertPost(t *testing.T, want, got Post) {
 	t.Helper()
-	if got != want {
+	if !reflect.DeepEqual(got, want) {
 		t.Errorf("got %v want %v", got, want)
 	}
 }
@@ -297,3 +328,67 @@ func assertContentType(t *testing.T, response *httptest.ResponseRecorder) {
 		t.Errorf("response did not have content-type of %s, got %v", want, response.Result().Header)
 	}
 }
+
+func TestPostStatusWorkflow(t *testing.T) {
+	t.Run("drafts are not listed", func(t *testing.T) {
+		store := EmptyInMemoryPostStore()
+		server := NewPostServer(std, store)
+		request := newCreatePostFormRequest(url.Values{"title": {"title"}, "text": {"text"}, "status": {"draft"}})
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+		assertStatus(t, response.Code, http.StatusCreated)
+
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, newGetAllPostsRequest())
+		assertPosts(t, []Post{}, getPostsFromResponse(t, response.Body))
+	})
+
+	t.Run("future publish_at schedules the post", func(t *testing.T) {
+		store := EmptyInMemoryPostStore()
+		server := NewPostServer(std, store)
+		publishAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		request := newCreatePostFormRequest(url.Values{"title": {"title"}, "text": {"text"}, "publish_at": {publishAt}})
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		got := getSinglePostFromResponse(t, response.Body)
+		assertStatus(t, response.Code, http.StatusCreated)
+		if got.Status != StatusScheduled {
+			t.Errorf("got status %q, want %q", got.Status, StatusScheduled)
+		}
+	})
+
+	t.Run("return 422 on scheduled post without publish_at", func(t *testing.T) {
+		store := EmptyInMemoryPostStore()
+		server := NewPostServer(std, store)
+		request := newCreatePostFormRequest(url.Values{"title": {"title"}, "text": {"text"}, "status": {"scheduled"}})
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnprocessableEntity)
+		assertPostCount(t, 0, len(store.Posts))
+	})
+
+	t.Run("archive keeps title and text", func(t *testing.T) {
+		store := NewInMemoryPostStore()
+		server := NewPostServer(std, store)
+		request, _ := http.NewRequest(http.MethodPut, "/posts/1?status=archived", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		got := store.Posts[1]
+		assertStatus(t, response.Code, http.StatusOK)
+		if got.Status != StatusArchived || got.Title != "title" || got.Content != "text" {
+			t.Errorf("unexpected archived post %v", got)
+		}
+	})
+}
+
+func newCreatePostFormRequest(data url.Values) *http.Request {
+	request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/posts/new?%s", data.Encode()), nil)
+	return request
+}
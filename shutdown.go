@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/health"
+	"github.com/dsphub/go-simple-crud-sample/logging"
+	"github.com/pkg/errors"
+)
+
+// serve serves srv on l until a signal is received, then drains it: the
+// readiness probe fails at once, so that no new traffic is sent, the server
+// stops accepting connections after drainDelay, and the requests in flight
+// get until timeout to end before their connections are closed.
+func serve(log *logging.Logger, srv *http.Server, l net.Listener, checker *health.Checker, signals <-chan os.Signal, drainDelay, timeout time.Duration) error {
+	failed := make(chan error, 1)
+	go func() { failed <- srv.Serve(l) }()
+
+	select {
+	case err := <-failed:
+		return errors.Wrap(err, "can't serve")
+	case sig := <-signals:
+		log.Info("stop service", "signal", sig.String())
+	}
+	checker.Drain()
+	time.Sleep(drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+		return errors.Wrap(err, "can't drain requests")
+	}
+	return nil
+}
+
+// shutdownStep stops a part of the service, see stopAll.
+type shutdownStep struct {
+	name string
+	stop func() error
+}
+
+// stopping returns the stop function of a step that can't fail.
+func stopping(stop func()) func() error {
+	return func() error {
+		stop()
+		return nil
+	}
+}
+
+// stopAll runs the steps in order, whether some fail or not, and returns
+// whether they all succeeded.
+func stopAll(log *logging.Logger, steps []shutdownStep) bool {
+	ok := true
+	for _, step := range steps {
+		if err := step.stop(); err != nil {
+			log.Error("can't stop", "component", step.name, "err", err)
+			ok = false
+			continue
+		}
+		log.Debug("stopped", "component", step.name)
+	}
+	return ok
+}
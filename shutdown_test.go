@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/health"
+	"github.com/dsphub/go-simple-crud-sample/logging"
+)
+
+func TestServeDrainsOnSignal(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})}
+	checker := health.NewChecker(time.Second)
+	signals := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(logging.Discard, srv, l, checker, signals, 0, time.Minute)
+	}()
+
+	responses := make(chan int, 1)
+	go func() {
+		response, err := http.Get("http://" + l.Addr().String())
+		if err != nil {
+			responses <- 0
+			return
+		}
+		response.Body.Close()
+		responses <- response.StatusCode
+	}()
+	<-started
+	signals <- syscall.SIGTERM
+
+	waitFor(t, func() bool { return checker.Ready(context.Background()).Status == health.StatusFail })
+	waitFor(t, func() bool {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	})
+	close(release)
+	if status := <-responses; status != http.StatusNoContent {
+		t.Errorf("got status %d for the request in flight, want 204", status)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("got error %v, want the server drained", err)
+	}
+}
+
+func TestServeCutsOffRequestsPastTheTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+	signals := make(chan os.Signal, 1)
+	go http.Get("http://" + l.Addr().String())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(logging.Discard, srv, l, health.NewChecker(time.Second), signals, 0, 10*time.Millisecond)
+	}()
+	<-started
+
+	signals <- os.Interrupt
+
+	if err := <-served; err == nil {
+		t.Error("got no error, want the drain timed out")
+	}
+}
+
+func TestStopAllRunsEveryStepInOrder(t *testing.T) {
+	var stopped []string
+	step := func(name string, err error) shutdownStep {
+		return shutdownStep{name, func() error {
+			stopped = append(stopped, name)
+			return err
+		}}
+	}
+
+	ok := stopAll(logging.Discard, []shutdownStep{
+		step("workers", nil),
+		step("views", errors.New("connection refused")),
+		step("store", nil),
+	})
+
+	if ok {
+		t.Error("got success, want the failed step reported")
+	}
+	if got := len(stopped); got != 3 || stopped[0] != "workers" || stopped[2] != "store" {
+		t.Errorf("got steps %v run, want workers, views and store", stopped)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,13 @@
+-- Keys are looked up by hash before the tenant of a request is known,
+-- hence the table has no row-level security.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id serial PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	prefix VARCHAR(16) NOT NULL,
+	hash CHAR(64) NOT NULL UNIQUE,
+	scopes VARCHAR(32)[] NOT NULL,
+	tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ
+);
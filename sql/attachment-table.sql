@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS attachments (
+	id serial PRIMARY KEY,
+	post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+	hash CHAR(64) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	content_type VARCHAR(100) NOT NULL,
+	size BIGINT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS attachments_post_idx ON attachments (post_id);
+CREATE INDEX IF NOT EXISTS attachments_hash_idx ON attachments (hash);
@@ -0,0 +1,42 @@
+-- The audit log is append-only: the triggers below reject any change of the
+-- recorded entries. When the hash chain is enabled, every entry also holds
+-- the hash of the previous chained one, see AuditEntry.ChainHash, so that
+-- rows removed or altered by someone able to drop the triggers are detected
+-- by the audit-verify command.
+CREATE TABLE IF NOT EXISTS audit_log (
+	id bigserial PRIMARY KEY,
+	action VARCHAR(32) NOT NULL,
+	post_id INTEGER NOT NULL,
+	actor VARCHAR(255) NOT NULL DEFAULT '',
+	ip VARCHAR(64) NOT NULL DEFAULT '',
+	request_id VARCHAR(128) NOT NULL DEFAULT '',
+	at TIMESTAMPTZ NOT NULL,
+	tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+	prev_hash VARCHAR(64) NOT NULL DEFAULT '',
+	hash VARCHAR(64) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_post_idx ON audit_log (post_id, id);
+CREATE INDEX IF NOT EXISTS audit_log_tenant_idx ON audit_log (tenant_id, id);
+CREATE INDEX IF NOT EXISTS audit_log_at_idx ON audit_log (at);
+
+CREATE OR REPLACE FUNCTION reject_audit_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+	FOR EACH ROW EXECUTE PROCEDURE reject_audit_change();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+	FOR EACH STATEMENT EXECUTE PROCEDURE reject_audit_change();
+
+-- tenant_visible is defined by tenant.sql.
+ALTER TABLE audit_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE audit_log FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON audit_log;
+CREATE POLICY tenant_isolation ON audit_log
+	USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
@@ -0,0 +1,28 @@
+-- Keys are chosen by the clients, hence unique per tenant and actor only,
+-- the principal who made the request. Rows with a status of 0 are
+-- reservations of requests in progress. tenant_visible is defined by
+-- tenant.sql.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+	actor VARCHAR(255) NOT NULL DEFAULT '',
+	key VARCHAR(255) NOT NULL,
+	fingerprint VARCHAR(64) NOT NULL,
+	status INTEGER NOT NULL DEFAULT 0,
+	body BYTEA,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (tenant_id, actor, key)
+);
+
+-- The keys of the tables created before they were kept apart by actor.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS actor VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey,
+	ADD PRIMARY KEY (tenant_id, actor, key);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_idx ON idempotency_keys (expires_at);
+
+ALTER TABLE idempotency_keys ENABLE ROW LEVEL SECURITY;
+ALTER TABLE idempotency_keys FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON idempotency_keys;
+CREATE POLICY tenant_isolation ON idempotency_keys
+	USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
@@ -0,0 +1,10 @@
+CREATE OR REPLACE FUNCTION notify_post_event() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('post_events', NEW.id::text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS outbox_notify ON outbox;
+CREATE TRIGGER outbox_notify AFTER INSERT ON outbox
+	FOR EACH ROW EXECUTE PROCEDURE notify_post_event();
@@ -0,0 +1,21 @@
+-- seq orders the events of a tenant by commit, unlike the serial id handed
+-- out at insert: it is taken from the change sequence under the lock of the
+-- tenant, see sql/post-changes.sql. The event stream resumes from it. Run
+-- after tenant.sql.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS seq BIGINT;
+UPDATE outbox SET seq = numbered.seq
+	FROM (SELECT id, nextval('post_change_seq') AS seq FROM (SELECT id FROM outbox WHERE seq IS NULL ORDER BY id) AS pending) AS numbered
+	WHERE outbox.id = numbered.id;
+ALTER TABLE outbox ALTER COLUMN seq SET NOT NULL;
+CREATE INDEX IF NOT EXISTS outbox_tenant_seq_idx ON outbox (tenant_id, seq);
+
+CREATE OR REPLACE FUNCTION track_outbox_seq() RETURNS trigger AS $$
+BEGIN
+	NEW.seq := next_post_change_seq(NEW.tenant_id);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS outbox_seq ON outbox;
+CREATE TRIGGER outbox_seq BEFORE INSERT ON outbox
+	FOR EACH ROW EXECUTE PROCEDURE track_outbox_seq();
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS outbox (
+	id bigserial PRIMARY KEY,
+	type VARCHAR(32) NOT NULL,
+	post_id INTEGER NOT NULL,
+	before JSONB,
+	after JSONB,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (id) WHERE delivered_at IS NULL;
@@ -0,0 +1,5 @@
+-- author_id identifies who created a post: the subject of the bearer token,
+-- the API key ("apikey:<id>") or the X-User-ID header of the request.
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS author_id VARCHAR(255) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS posts_author_idx ON posts (tenant_id, author_id);
@@ -0,0 +1,49 @@
+CREATE SEQUENCE IF NOT EXISTS post_change_seq;
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT nextval('post_change_seq');
+CREATE INDEX IF NOT EXISTS posts_change_seq_idx ON posts (change_seq);
+
+CREATE TABLE IF NOT EXISTS post_tombstones (
+	post_id INTEGER PRIMARY KEY,
+	change_seq BIGINT NOT NULL,
+	deleted_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS post_tombstones_change_seq_idx ON post_tombstones (change_seq);
+
+-- Writers take the sequence under a transaction-level advisory lock of
+-- their tenant, so the sequence numbers of a tenant become visible in order:
+-- a reader of the tenant never sees seq n+1 committed while seq n may still
+-- commit. Tenants don't wait for each other, their feeds are read apart.
+-- Counter updates (reactions, views) don't move the sequence and don't take
+-- the lock. The tenant_id columns are added by tenant.sql.
+CREATE OR REPLACE FUNCTION next_post_change_seq(tenant VARCHAR) RETURNS BIGINT AS $$
+BEGIN
+	PERFORM pg_advisory_xact_lock(hashtext('post_change_seq:' || tenant));
+	RETURN nextval('post_change_seq');
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS next_post_change_seq();
+
+CREATE OR REPLACE FUNCTION track_post_change() RETURNS trigger AS $$
+BEGIN
+	NEW.change_seq := next_post_change_seq(NEW.tenant_id);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION track_post_deletion() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO post_tombstones (post_id, change_seq, tenant_id) VALUES (OLD.id, next_post_change_seq(OLD.tenant_id), OLD.tenant_id)
+		ON CONFLICT (post_id) DO UPDATE SET change_seq = EXCLUDED.change_seq, deleted_at = EXCLUDED.deleted_at;
+	RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_change ON posts;
+CREATE TRIGGER posts_change BEFORE INSERT OR UPDATE OF title, content, status, publish_at ON posts
+	FOR EACH ROW EXECUTE PROCEDURE track_post_change();
+
+DROP TRIGGER IF EXISTS posts_deletion ON posts;
+CREATE TRIGGER posts_deletion AFTER DELETE ON posts
+	FOR EACH ROW EXECUTE PROCEDURE track_post_deletion();
@@ -1,5 +1,14 @@
 CREATE TABLE IF NOT EXISTS posts (
 	id serial PRIMARY KEY,
 	title VARCHAR(100) NOT NULL,
-	content TEXT NOT NULL
+	content TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'published'
+		CHECK (status IN ('draft', 'scheduled', 'published', 'archived')),
+	publish_at TIMESTAMPTZ
 );
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'published'
+	CHECK (status IN ('draft', 'scheduled', 'published', 'archived'));
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS posts_scheduled_idx ON posts (publish_at) WHERE status = 'scheduled';
@@ -0,0 +1,30 @@
+-- Token buckets shared by the server instances, see ratelimit.StoreLimiter.
+-- full_at is when the bucket would be full again: from then on the row is
+-- the same as no row and may be purged.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+	key VARCHAR(255) PRIMARY KEY,
+	tokens DOUBLE PRECISION NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	full_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_full_idx ON rate_limit_buckets (full_at);
+
+-- The bucket row is locked from the refill to the update, so concurrent
+-- requests each see the tokens left by the previous one.
+CREATE OR REPLACE FUNCTION take_rate_limit_token(bucket_key TEXT, rate FLOAT8, burst FLOAT8,
+	OUT left_tokens FLOAT8, OUT taken BOOLEAN) AS $$
+BEGIN
+	INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at) VALUES (bucket_key, burst, now(), now())
+		ON CONFLICT (key) DO NOTHING;
+	SELECT LEAST(burst, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * rate) INTO left_tokens
+		FROM rate_limit_buckets b WHERE b.key = bucket_key FOR UPDATE;
+	taken := left_tokens >= 1;
+	IF taken THEN
+		left_tokens := left_tokens - 1;
+	END IF;
+	UPDATE rate_limit_buckets SET tokens = left_tokens, updated_at = now(),
+		full_at = now() + make_interval(secs => (burst - left_tokens) / rate)
+		WHERE key = bucket_key;
+END;
+$$ LANGUAGE plpgsql;
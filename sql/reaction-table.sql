@@ -0,0 +1,9 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS reaction_counts JSONB NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS reactions (
+	post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+	user_id VARCHAR(100) NOT NULL,
+	emoji VARCHAR(16) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (post_id, user_id)
+);
@@ -0,0 +1,62 @@
+-- Every tenant sees its own posts, events and webhooks only, and the
+-- attachments, reactions and views of its posts. The store scopes its queries
+-- by tenant_id; the policies below are a second line of defense. Tenant-scoped transactions set app.tenant_id and can neither read
+-- nor write the rows of other tenants. Connections that don't set it, i.e.
+-- the background workers serving all tenants, are not restricted.
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS posts_tenant_idx ON posts (tenant_id, id);
+
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE post_tombstones ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+CREATE OR REPLACE FUNCTION tenant_visible(tenant VARCHAR) RETURNS BOOLEAN AS $$
+	SELECT COALESCE(current_setting('app.tenant_id', true), '') IN ('', tenant);
+$$ LANGUAGE sql STABLE;
+
+ALTER TABLE posts ENABLE ROW LEVEL SECURITY;
+ALTER TABLE posts FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON posts;
+CREATE POLICY tenant_isolation ON posts
+	USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
+
+ALTER TABLE outbox ENABLE ROW LEVEL SECURITY;
+ALTER TABLE outbox FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON outbox;
+CREATE POLICY tenant_isolation ON outbox
+	USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
+
+ALTER TABLE webhooks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhooks FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON webhooks;
+CREATE POLICY tenant_isolation ON webhooks
+	USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
+
+ALTER TABLE post_tombstones ENABLE ROW LEVEL SECURITY;
+ALTER TABLE post_tombstones FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON post_tombstones;
+CREATE POLICY tenant_isolation ON post_tombstones
+	USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
+
+-- The rows of the posts are visible with their post only.
+ALTER TABLE attachments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE attachments FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON attachments;
+CREATE POLICY tenant_isolation ON attachments
+	USING (EXISTS (SELECT 1 FROM posts WHERE posts.id = post_id))
+	WITH CHECK (EXISTS (SELECT 1 FROM posts WHERE posts.id = post_id));
+
+ALTER TABLE reactions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE reactions FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON reactions;
+CREATE POLICY tenant_isolation ON reactions
+	USING (EXISTS (SELECT 1 FROM posts WHERE posts.id = post_id))
+	WITH CHECK (EXISTS (SELECT 1 FROM posts WHERE posts.id = post_id));
+
+ALTER TABLE post_views ENABLE ROW LEVEL SECURITY;
+ALTER TABLE post_views FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON post_views;
+CREATE POLICY tenant_isolation ON post_views
+	USING (EXISTS (SELECT 1 FROM posts WHERE posts.id = post_id))
+	WITH CHECK (EXISTS (SELECT 1 FROM posts WHERE posts.id = post_id));
@@ -0,0 +1,4 @@
+-- traceparent is the W3C trace context of the request that changed a post,
+-- carried from the event to its webhook deliveries; empty if not traced.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS traceparent VARCHAR(55) NOT NULL DEFAULT '';
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS traceparent VARCHAR(55) NOT NULL DEFAULT '';
@@ -0,0 +1,24 @@
+-- Users log in before the tenant of a request is known, hence the tables
+-- have no row-level security. Emails are unique per tenant.
+CREATE TABLE IF NOT EXISTS users (
+	id serial PRIMARY KEY,
+	email VARCHAR(254) NOT NULL,
+	password_hash VARCHAR(255) NOT NULL,
+	roles VARCHAR(32)[] NOT NULL DEFAULT '{}',
+	tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	failed_logins INTEGER NOT NULL DEFAULT 0,
+	locked_until TIMESTAMPTZ
+);
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_tenant_email_idx ON users (tenant_id, email);
+
+-- id is the SHA-256 of the session token.
+CREATE TABLE IF NOT EXISTS sessions (
+	id CHAR(64) PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_expires_idx ON sessions (expires_at);
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (user_id);
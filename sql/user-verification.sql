@@ -0,0 +1,2 @@
+-- verified_at is set once the user followed the link mailed on registration.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verified_at TIMESTAMPTZ;
@@ -0,0 +1,10 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS views BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS post_views (
+	post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+	bucket TIMESTAMPTZ NOT NULL,
+	views BIGINT NOT NULL,
+	PRIMARY KEY (post_id, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS post_views_bucket_idx ON post_views (bucket);
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+	id serial PRIMARY KEY,
+	url TEXT NOT NULL,
+	secret VARCHAR(128) NOT NULL,
+	event_types TEXT[] NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT true,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id bigserial PRIMARY KEY,
+	webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+	event_id BIGINT NOT NULL,
+	event_type VARCHAR(32) NOT NULL,
+	payload JSONB NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ DEFAULT now(),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS webhook_attempts (
+	delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+	attempt INTEGER NOT NULL,
+	status_code INTEGER,
+	error TEXT,
+	duration BIGINT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (delivery_id, attempt)
+);
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// APIKeyStore keeps the API keys, by hash. It is not scoped by tenant: the
+// key tells the tenant.
+type APIKeyStore interface {
+	CreateAPIKey(key APIKey) (APIKey, error)
+	GetAPIKeyByHash(hash string) (APIKey, error)
+	GetAPIKeys() ([]APIKey, error)
+	RevokeAPIKey(id int) error
+}
+
+const apiKeyColumns = "id, name, prefix, hash, scopes, tenant_id, created_at, expires_at, revoked_at"
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Hash, pq.Array(&k.Scopes), &k.TenantID, &k.CreatedAt, &k.ExpiresAt, &k.RevokedAt)
+	return k, err
+}
+
+func (p *PostgresPostStore) CreateAPIKey(k APIKey) (APIKey, error) {
+	k.TenantID = p.tenantOf(k.TenantID)
+	q := `INSERT INTO api_keys(name, prefix, hash, scopes, tenant_id, expires_at) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at;`
+	err := p.db.QueryRow(q, k.Name, k.Prefix, k.Hash, pq.Array(k.Scopes), k.TenantID, k.ExpiresAt).Scan(&k.ID, &k.CreatedAt)
+	return k, errors.Wrap(err, "can't create API key")
+}
+
+func (p *PostgresPostStore) GetAPIKeyByHash(hash string) (APIKey, error) {
+	k, err := scanAPIKey(p.db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE hash = $1;", hash))
+	if err == sql.ErrNoRows {
+		return k, ErrorAPIKeyDoesNotExist
+	}
+	return k, errors.Wrap(err, "can't get API key")
+}
+
+func (p *PostgresPostStore) GetAPIKeys() ([]APIKey, error) {
+	rows, err := p.db.Query("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY id;")
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get API keys")
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't scan API key")
+		}
+		keys = append(keys, k)
+	}
+	return keys, errors.Wrap(rows.Err(), "can't read API keys")
+}
+
+// RevokeAPIKey keeps the key, so that it is still listed, and rejects it
+// from now on. Revoking a key twice keeps the first revocation time.
+func (p *PostgresPostStore) RevokeAPIKey(id int) error {
+	result, err := p.db.Exec("UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1;", id)
+	if err != nil {
+		return errors.Wrapf(err, "can't revoke API key %d", id)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "can't revoke API key %d", id)
+	}
+	if n == 0 {
+		return ErrorAPIKeyDoesNotExist
+	}
+	return nil
+}
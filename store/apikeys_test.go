@@ -0,0 +1,67 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var apiKeyRowColumns = []string{"id", "name", "prefix", "hash", "scopes", "tenant_id", "created_at", "expires_at", "revoked_at"}
+
+func TestShouldCreateAPIKey(t *testing.T) {
+	createdAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("INSERT INTO api_keys(.+) RETURNING id, created_at").
+		WithArgs("ci", "crud_abcdefg", "hash", "{\"posts:read\",\"posts:write\"}", DefaultTenant, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, createdAt))
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.CreateAPIKey(APIKey{Name: "ci", Prefix: "crud_abcdefg", Hash: "hash", Scopes: []string{ScopePostsRead, ScopePostsWrite}})
+
+	if assert.NoError(t, err, "Error was not expected while creating API key") {
+		assert.Equal(t, 2, got.ID)
+		assert.Equal(t, createdAt, got.CreatedAt)
+		assert.Equal(t, DefaultTenant, got.TenantID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed create behaviour")
+}
+
+func TestShouldGetAPIKeyByHash(t *testing.T) {
+	createdAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	want := APIKey{ID: 2, Name: "ci", Prefix: "crud_abcdefg", Hash: "hash", Scopes: []string{ScopePostsRead}, TenantID: "acme", CreatedAt: createdAt}
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM api_keys WHERE hash = ").
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows(apiKeyRowColumns).AddRow(2, "ci", "crud_abcdefg", "hash", "{posts:read}", "acme", createdAt, nil, nil))
+	mock.ExpectQuery("SELECT (.+) FROM api_keys WHERE hash = ").
+		WithArgs("unknown").
+		WillReturnRows(sqlmock.NewRows(apiKeyRowColumns))
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.GetAPIKeyByHash("hash")
+	_, missing := store.GetAPIKeyByHash("unknown")
+
+	if assert.NoError(t, err, "Error was not expected while getting API key") {
+		assert.Equal(t, want, got, "Unexpected API key")
+	}
+	assert.Equal(t, ErrorAPIKeyDoesNotExist, missing)
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed get behaviour")
+}
+
+func TestShouldRevokeAPIKey(t *testing.T) {
+	db, mock, _ := dbMock(t)
+	defer db.Close()
+	mock.ExpectExec("UPDATE api_keys SET revoked_at").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE api_keys SET revoked_at").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	store := NewTestPostgresPostStore(db)
+
+	assert.NoError(t, store.RevokeAPIKey(2), "Error was not expected while revoking API key")
+	assert.Equal(t, ErrorAPIKeyDoesNotExist, store.RevokeAPIKey(3))
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed revoke behaviour")
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// AttachmentStore keeps the metadata of files attached to posts. The
+// content itself lives in a blob store keyed by Attachment.Hash.
+type AttachmentStore interface {
+	CreateAttachment(attachment Attachment) (Attachment, error)
+	GetAttachments(postID int) ([]Attachment, error)
+	// GetAttachmentsByHash returns the attachments of the content hash, one
+	// by post it is attached to, oldest first.
+	GetAttachmentsByHash(hash string) ([]Attachment, error)
+}
+
+const attachmentColumns = "id, post_id, hash, name, content_type, size, created_at"
+
+func scanAttachment(row rowScanner) (Attachment, error) {
+	var a Attachment
+	err := row.Scan(&a.ID, &a.PostID, &a.Hash, &a.Name, &a.ContentType, &a.Size, &a.CreatedAt)
+	return a, err
+}
+
+// Attachments belong to the tenant of their post; the queries of a scoped
+// store only reach the attachments of its posts.
+const attachmentOfTenant = "($%d = '' OR post_id IN (SELECT id FROM posts WHERE tenant_id = $%[1]d))"
+
+func (p *PostgresPostStore) CreateAttachment(a Attachment) (Attachment, error) {
+	err := p.read(func(db querier) error {
+		q := `INSERT INTO attachments(post_id, hash, name, content_type, size)
+		SELECT id, $2, $3, $4, $5::bigint FROM posts WHERE id = $1 AND ($6 = '' OR tenant_id = $6)
+		RETURNING id, created_at;`
+		err := db.QueryRow(q, a.PostID, a.Hash, a.Name, a.ContentType, a.Size, p.tenant).Scan(&a.ID, &a.CreatedAt)
+		if err == sql.ErrNoRows {
+			return ErrorPostDoesNotExist
+		}
+		return errors.Wrapf(err, "can't attach file to post %d", a.PostID)
+	})
+	return a, err
+}
+
+func (p *PostgresPostStore) GetAttachments(postID int) ([]Attachment, error) {
+	q := "SELECT " + attachmentColumns + " FROM attachments WHERE post_id = $1 AND " +
+		fmt.Sprintf(attachmentOfTenant, 2) + " ORDER BY id;"
+	attachments, err := p.queryAttachments(q, postID)
+	return attachments, errors.Wrapf(err, "can't get attachments of post %d", postID)
+}
+
+func (p *PostgresPostStore) GetAttachmentsByHash(hash string) ([]Attachment, error) {
+	q := "SELECT " + attachmentColumns + " FROM attachments WHERE hash = $1 AND " +
+		fmt.Sprintf(attachmentOfTenant, 2) + " ORDER BY id;"
+	attachments, err := p.queryAttachments(q, hash)
+	if err == nil && len(attachments) == 0 {
+		return nil, ErrorAttachmentDoesNotExist
+	}
+	return attachments, errors.Wrapf(err, "can't get attachments %s", hash)
+}
+
+// queryAttachments runs a query of attachments taking arg and the tenant.
+func (p *PostgresPostStore) queryAttachments(q string, arg interface{}) ([]Attachment, error) {
+	attachments := []Attachment{}
+	err := p.read(func(db querier) error {
+		rows, err := db.Query(q, arg, p.tenant)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			a, err := scanAttachment(rows)
+			if err != nil {
+				return errors.Wrap(err, "can't scan attachment")
+			}
+			attachments = append(attachments, a)
+		}
+		return errors.Wrap(rows.Err(), "can't read attachments")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
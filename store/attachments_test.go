@@ -0,0 +1,68 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const testHash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+var attachmentRowColumns = []string{"id", "post_id", "hash", "name", "content_type", "size", "created_at"}
+
+func TestShouldCreateAttachment(t *testing.T) {
+	createdAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	want := Attachment{ID: 7, PostID: 1, Hash: testHash, Name: "hello.txt", ContentType: "text/plain", Size: 5, CreatedAt: createdAt}
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("INSERT INTO attachments(.+) RETURNING id, created_at").
+		WithArgs(want.PostID, want.Hash, want.Name, want.ContentType, want.Size, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(want.ID, createdAt))
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.CreateAttachment(Attachment{PostID: 1, Hash: testHash, Name: "hello.txt", ContentType: "text/plain", Size: 5})
+
+	if assert.NoError(t, err, "Error was not expected while creating attachment") {
+		assert.Equal(t, want, got, "Unexpected attachment")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed create behaviour")
+}
+
+func TestShouldGetAttachments(t *testing.T) {
+	createdAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	want := []Attachment{
+		Attachment{ID: 7, PostID: 1, Hash: testHash, Name: "hello.txt", ContentType: "text/plain", Size: 5, CreatedAt: createdAt},
+	}
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	rows := sqlmock.NewRows(attachmentRowColumns).
+		AddRow(7, 1, testHash, "hello.txt", "text/plain", 5, createdAt)
+	mock.ExpectQuery("SELECT (.+) FROM attachments WHERE post_id = (.+)").
+		WithArgs(1, "").
+		WillReturnRows(rows)
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.GetAttachments(1)
+
+	if assert.NoError(t, err, "Error was not expected while getting attachments") {
+		assert.Equal(t, want, got, "Unexpected attachments")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed read behaviour")
+}
+
+func TestShouldReturnMissingAttachment(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM attachments WHERE hash = (.+)").
+		WithArgs(testHash, "").
+		WillReturnRows(sqlmock.NewRows(attachmentRowColumns))
+
+	store := NewTestPostgresPostStore(db)
+	_, err = store.GetAttachmentsByHash(testHash)
+
+	assert.Equal(t, ErrorAttachmentDoesNotExist, err, "Unexpected error for missing attachment")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed read behaviour")
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// AuditLog records the changes of posts made through the API.
+type AuditLog interface {
+	// AppendAudit records entry and returns it with its ID. With chain set,
+	// the entry is linked to the latest chained entry of its tenant.
+	AppendAudit(entry AuditEntry, chain bool) (AuditEntry, error)
+	// GetAuditEntries returns the entries matching filter, oldest first.
+	GetAuditEntries(filter AuditFilter) ([]AuditEntry, error)
+}
+
+// AuditScoper is implemented by stores able to audit the changes of posts
+// in the transactions that make them.
+type AuditScoper interface {
+	// ForAudit returns a view of the store recording an entry like entry,
+	// with the action and the post filled in, for every post it creates,
+	// updates or deletes. A change fails if its entry can't be recorded. The
+	// view implements the same interfaces as the store.
+	ForAudit(entry AuditEntry, chain bool) AuditScoper
+}
+
+// ForAudit appends the entries of the view in the transactions of the
+// changes, so that there is no change without its entry.
+func (p *PostgresPostStore) ForAudit(entry AuditEntry, chain bool) AuditScoper {
+	scoped := *p
+	scoped.audit = &entry
+	scoped.auditChain = chain
+	return &scoped
+}
+
+// auditChange records the audit entry of a scoped store for action on the
+// post, see ForAudit.
+func (p *PostgresPostStore) auditChange(tx *sql.Tx, action string, postID int) error {
+	if p.audit == nil {
+		return nil
+	}
+	entry := *p.audit
+	entry.Action = action
+	entry.PostID = postID
+	entry.At = time.Now()
+	_, err := p.appendAudit(tx, entry, p.auditChain)
+	return err
+}
+
+const auditColumns = "id, action, post_id, actor, ip, request_id, at, tenant_id, prev_hash, hash"
+
+// AppendAudit chains the entries of a tenant under an advisory lock, so
+// concurrent appends can't both link to the same entry. Each tenant has its
+// own chain: a scoped store doesn't see the entries of other tenants.
+func (p *PostgresPostStore) AppendAudit(entry AuditEntry, chain bool) (AuditEntry, error) {
+	err := p.inTx(func(tx *sql.Tx) error {
+		var err error
+		entry, err = p.appendAudit(tx, entry, chain)
+		return err
+	})
+	return entry, err
+}
+
+func (p *PostgresPostStore) appendAudit(tx *sql.Tx, entry AuditEntry, chain bool) (AuditEntry, error) {
+	entry.TenantID = p.tenantOf(entry.TenantID)
+	entry.At = entry.At.UTC().Truncate(time.Microsecond)
+	if chain {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('audit_log:' || $1::text));", entry.TenantID); err != nil {
+			return entry, errors.Wrap(err, "can't lock audit chain")
+		}
+		q := "SELECT hash FROM audit_log WHERE tenant_id = $1 AND hash <> '' ORDER BY id DESC LIMIT 1;"
+		err := tx.QueryRow(q, entry.TenantID).Scan(&entry.PrevHash)
+		if err != nil && err != sql.ErrNoRows {
+			return entry, errors.Wrap(err, "can't get audit chain head")
+		}
+		entry.Hash = entry.ChainHash()
+	}
+	q := `INSERT INTO audit_log(action, post_id, actor, ip, request_id, at, tenant_id, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id;`
+	err := tx.QueryRow(q, entry.Action, entry.PostID, entry.Actor, entry.IP, entry.RequestID,
+		entry.At, entry.TenantID, entry.PrevHash, entry.Hash).Scan(&entry.ID)
+	return entry, errors.Wrapf(err, "can't record %s audit entry", entry.Action)
+}
+
+func (p *PostgresPostStore) GetAuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	q := `SELECT ` + auditColumns + ` FROM audit_log
+		WHERE id > $1 AND ($2 = 0 OR post_id = $2) AND ($3 = '' OR actor = $3) AND ($4 = '' OR action = $4)
+			AND ($5::timestamptz IS NULL OR at >= $5) AND ($6::timestamptz IS NULL OR at < $6)
+			AND ($7 = '' OR tenant_id = $7)
+		ORDER BY id
+		LIMIT $8;`
+	var entries []AuditEntry
+	err := p.read(func(db querier) error {
+		rows, err := db.Query(q, filter.AfterID, filter.PostID, filter.Actor, filter.Action,
+			nullTime(filter.Since), nullTime(filter.Until), p.tenant, filter.Limit)
+		if err != nil {
+			return errors.Wrap(err, "can't get audit entries")
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var e AuditEntry
+			err := rows.Scan(&e.ID, &e.Action, &e.PostID, &e.Actor, &e.IP, &e.RequestID, &e.At, &e.TenantID, &e.PrevHash, &e.Hash)
+			if err != nil {
+				return errors.Wrap(err, "can't scan audit entry")
+			}
+			entries = append(entries, e)
+		}
+		return errors.Wrap(rows.Err(), "can't read audit entries")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// nullTime passes the zero time as NULL.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
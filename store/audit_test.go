@@ -0,0 +1,118 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldAppendChainedAuditEntry(t *testing.T) {
+	at := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	entry := AuditEntry{Action: EventPostUpdated, PostID: 1, Actor: "alice", IP: "10.0.0.1", RequestID: "req-1", At: at}
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT hash FROM audit_log").
+		WithArgs(DefaultTenant).
+		WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("previous"))
+	mock.ExpectQuery("INSERT INTO audit_log(.+) RETURNING id").
+		WithArgs(EventPostUpdated, 1, "alice", "10.0.0.1", "req-1", at, DefaultTenant, "previous", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.AppendAudit(entry, true)
+
+	if assert.NoError(t, err, "Error was not expected while appending audit entry") {
+		assert.Equal(t, int64(9), got.ID)
+		assert.Equal(t, "previous", got.PrevHash)
+		assert.Equal(t, got.ChainHash(), got.Hash, "Unexpected hash")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed append behaviour")
+}
+
+func TestShouldAppendUnchainedAuditEntry(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO audit_log(.+) RETURNING id").
+		WithArgs(EventPostDeleted, 2, "", "", "", sqlmock.AnyArg(), DefaultTenant, "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.AppendAudit(AuditEntry{Action: EventPostDeleted, PostID: 2, At: time.Now()}, false)
+
+	if assert.NoError(t, err, "Error was not expected while appending audit entry") {
+		assert.Equal(t, int64(10), got.ID)
+		assert.Empty(t, got.Hash)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed append behaviour")
+}
+
+func TestShouldAuditRestoreInTransactionOfChange(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	expectLockPost(mock, 1).
+		WillReturnRows(sqlmock.NewRows(postRowColumns).AddRow(1, "title", "text", StatusArchived, nil, []byte(`{}`), 0, DefaultTenant, ""))
+	mock.ExpectQuery("UPDATE (.+) SET (.+) WHERE (.+) RETURNING").
+		WillReturnRows(sqlmock.NewRows(postRowColumns).AddRow(1, "title", "text", StatusDraft, nil, []byte(`{}`), 0, DefaultTenant, ""))
+	expectEvent(mock, EventPostUpdated, 1)
+	mock.ExpectQuery("INSERT INTO audit_log(.+) RETURNING id").
+		WithArgs(AuditPostRestored, 1, "alice", "10.0.0.1", "req-1", sqlmock.AnyArg(), DefaultTenant, "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectCommit()
+
+	entry := AuditEntry{Actor: "alice", IP: "10.0.0.1", RequestID: "req-1"}
+	store := NewTestPostgresPostStore(db).ForAudit(entry, false).(*PostgresPostStore)
+	err = store.UpdatePost(Post{ID: 1, Title: "title", Content: "text", Status: StatusDraft})
+
+	assert.NoError(t, err, "Error was not expected while updating post")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed audited update behaviour")
+}
+
+func TestShouldNotDeletePostWithoutAuditEntry(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	expectLockPost(mock, 1).
+		WillReturnRows(sqlmock.NewRows(postRowColumns).AddRow(1, "title", "text", StatusPublished, nil, []byte(`{}`), 0, DefaultTenant, ""))
+	mock.ExpectExec("DELETE FROM (.+) WHERE").WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, EventPostDeleted, 1)
+	mock.ExpectQuery("INSERT INTO audit_log(.+) RETURNING id").WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	store := NewTestPostgresPostStore(db).ForAudit(AuditEntry{Actor: "alice"}, false).(*PostgresPostStore)
+	err = store.DeletePost(1)
+
+	assert.Error(t, err, "The post should not be deleted without its audit entry")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed audited delete behaviour")
+}
+
+func TestShouldGetAuditEntries(t *testing.T) {
+	at := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	since := at.Add(-time.Hour)
+	want := []AuditEntry{
+		{ID: 4, Action: EventPostCreated, PostID: 1, Actor: "alice", IP: "10.0.0.1", RequestID: "req-1", At: at, TenantID: DefaultTenant},
+	}
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM audit_log").
+		WithArgs(int64(3), 1, "alice", "", since, nil, "", 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "action", "post_id", "actor", "ip", "request_id", "at", "tenant_id", "prev_hash", "hash"}).
+			AddRow(4, EventPostCreated, 1, "alice", "10.0.0.1", "req-1", at, DefaultTenant, "", ""))
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.GetAuditEntries(AuditFilter{PostID: 1, Actor: "alice", Since: since, AfterID: 3, Limit: 50})
+
+	if assert.NoError(t, err, "Error was not expected while getting audit entries") {
+		assert.Equal(t, want, got, "Unexpected audit entries")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed audit behaviour")
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// ChangeStore is implemented by stores keeping a change sequence of posts,
+// see the changes feed.
+type ChangeStore interface {
+	// GetChangesSince returns up to limit changes with a seq above seq,
+	// oldest first. A post appears once, with its latest change. Posts that
+	// are not published are reported as deleted.
+	GetChangesSince(seq int64, limit int) ([]Change, error)
+}
+
+// prefixScanner scans the leading columns of a row into prefix and the
+// others into the destinations it is given.
+type prefixScanner struct {
+	rowScanner
+	prefix []interface{}
+}
+
+func (s prefixScanner) Scan(dest ...interface{}) error {
+	return s.rowScanner.Scan(append(s.prefix, dest...)...)
+}
+
+// GetChangesSince reads the change_seq column and the tombstones, both
+// maintained by triggers, see sql/post-changes.sql. The seqs are ordered by
+// commit within a tenant only: read the feed of one tenant at a time.
+func (p *PostgresPostStore) GetChangesSince(seq int64, limit int) ([]Change, error) {
+	q := `SELECT change_seq, false, ` + postColumns + ` FROM posts
+		WHERE change_seq > $1 AND ($3 = '' OR tenant_id = $3)
+		UNION ALL
+		SELECT change_seq, true, post_id, '', '', '', NULL, '{}'::jsonb, 0, tenant_id, '' FROM post_tombstones
+		WHERE change_seq > $1 AND ($3 = '' OR tenant_id = $3)
+			AND NOT EXISTS (SELECT 1 FROM posts WHERE posts.id = post_tombstones.post_id)
+		ORDER BY 1
+		LIMIT $2;`
+	var changes []Change
+	err := p.read(func(db querier) error {
+		rows, err := db.Query(q, seq, limit, p.tenant)
+		if err != nil {
+			return errors.Wrapf(err, "can't get changes since %d", seq)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var change Change
+			var deleted bool
+			post, err := scanPost(prefixScanner{rows, []interface{}{&change.Seq, &deleted}})
+			if err != nil {
+				return errors.Wrap(err, "can't scan change")
+			}
+			change.PostID = post.ID
+			if deleted || post.Status != StatusPublished {
+				change.Type = ChangeDelete
+			} else {
+				change.Type = ChangeUpsert
+				change.Post = &post
+			}
+			changes = append(changes, change)
+		}
+		return errors.Wrap(rows.Err(), "can't read changes")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldGetChangesSince(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	rows := sqlmock.NewRows(append([]string{"change_seq", "deleted"}, postRowColumns...)).
+		AddRow(11, false, 1, "title", "text", "published", nil, []byte(`{}`), 0, DefaultTenant, "").
+		AddRow(12, false, 2, "title", "text", "draft", nil, []byte(`{}`), 0, DefaultTenant, "").
+		AddRow(13, true, 3, "", "", "", nil, []byte(`{}`), 0, DefaultTenant, "")
+	mock.ExpectQuery("SELECT change_seq, false, (.+) FROM posts (.+) UNION ALL (.+) FROM post_tombstones").
+		WithArgs(10, 3, "").
+		WillReturnRows(rows)
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.GetChangesSince(10, 3)
+
+	if assert.NoError(t, err, "Error was not expected while getting changes") {
+		assert.Equal(t, []Change{
+			{Seq: 11, Type: ChangeUpsert, PostID: 1, Post: &Post{ID: 1, Title: "title", Content: "text", Status: StatusPublished, TenantID: DefaultTenant}},
+			{Seq: 12, Type: ChangeDelete, PostID: 2},
+			{Seq: 13, Type: ChangeDelete, PostID: 3},
+		}, got, "Unexpected changes")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed changes behaviour")
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// IdempotencyStore remembers the responses to requests made with an
+// Idempotency-Key, so that retries are answered without being served again.
+// Keys are chosen by the clients: they are kept apart by actor, the
+// principal who made the request.
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey claims the key of actor for a request until ttl
+	// elapses and returns true. If the key is already claimed, it returns its
+	// record and false instead.
+	ReserveIdempotencyKey(actor, key, fingerprint string, ttl time.Duration) (IdempotencyRecord, bool, error)
+	// CompleteIdempotencyKey stores the response to the request that claimed
+	// the key of actor.
+	CompleteIdempotencyKey(actor, key string, status int, body []byte) error
+	// ReleaseIdempotencyKey forgets the key of actor, e.g. when its request
+	// failed and may be retried.
+	ReleaseIdempotencyKey(actor, key string) error
+}
+
+const (
+	// idempotencyLease bounds the reservations of requests whose response was
+	// never stored, e.g. because the server crashed meanwhile.
+	idempotencyLease = time.Minute
+	// idempotencyPurgeSize is the number of expired keys deleted with each
+	// reservation, so that keys that are never retried don't pile up.
+	idempotencyPurgeSize = 10
+)
+
+// ReserveIdempotencyKey claims the key with a single upsert that only
+// replaces expired keys and stale reservations, so concurrent requests with
+// the same key can't both claim it.
+func (p *PostgresPostStore) ReserveIdempotencyKey(actor, key, fingerprint string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	record := IdempotencyRecord{Key: key, Fingerprint: fingerprint}
+	var reserved bool
+	err := p.inTx(func(tx *sql.Tx) error {
+		q := `DELETE FROM idempotency_keys WHERE ctid = ANY(ARRAY(
+			SELECT ctid FROM idempotency_keys WHERE expires_at <= now() LIMIT $1 FOR UPDATE SKIP LOCKED));`
+		if _, err := tx.Exec(q, idempotencyPurgeSize); err != nil {
+			return errors.Wrap(err, "can't purge idempotency keys")
+		}
+
+		q = `INSERT INTO idempotency_keys (tenant_id, actor, key, fingerprint, expires_at)
+			VALUES ($1, $2, $3, $4, now() + make_interval(secs => $5))
+			ON CONFLICT (tenant_id, actor, key) DO UPDATE SET
+				fingerprint = EXCLUDED.fingerprint,
+				status = 0,
+				body = NULL,
+				created_at = now(),
+				expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at <= now()
+				OR (idempotency_keys.status = 0 AND idempotency_keys.created_at <= now() - make_interval(secs => $6))
+			RETURNING expires_at;`
+		err := tx.QueryRow(q, p.tenantOf(""), actor, key, fingerprint, ttl.Seconds(), idempotencyLease.Seconds()).Scan(&record.ExpiresAt)
+		if err == nil {
+			reserved = true
+			return nil
+		}
+		if err != sql.ErrNoRows {
+			return errors.Wrapf(err, "can't reserve idempotency key %q", key)
+		}
+
+		q = "SELECT fingerprint, status, body, expires_at FROM idempotency_keys WHERE tenant_id = $1 AND actor = $2 AND key = $3;"
+		err = tx.QueryRow(q, p.tenantOf(""), actor, key).Scan(&record.Fingerprint, &record.Status, &record.Body, &record.ExpiresAt)
+		return errors.Wrapf(err, "can't get idempotency key %q", key)
+	})
+	return record, reserved, err
+}
+
+func (p *PostgresPostStore) CompleteIdempotencyKey(actor, key string, status int, body []byte) error {
+	return p.inTx(func(tx *sql.Tx) error {
+		q := "UPDATE idempotency_keys SET status = $4, body = $5 WHERE tenant_id = $1 AND actor = $2 AND key = $3;"
+		_, err := tx.Exec(q, p.tenantOf(""), actor, key, status, body)
+		return errors.Wrapf(err, "can't complete idempotency key %q", key)
+	})
+}
+
+func (p *PostgresPostStore) ReleaseIdempotencyKey(actor, key string) error {
+	return p.inTx(func(tx *sql.Tx) error {
+		q := "DELETE FROM idempotency_keys WHERE tenant_id = $1 AND actor = $2 AND key = $3 AND status = 0;"
+		_, err := tx.Exec(q, p.tenantOf(""), actor, key)
+		return errors.Wrapf(err, "can't release idempotency key %q", key)
+	})
+}
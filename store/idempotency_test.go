@@ -0,0 +1,71 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldReserveIdempotencyKey(t *testing.T) {
+	expiresAt := time.Date(2019, 10, 2, 12, 0, 0, 0, time.UTC)
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM idempotency_keys").WithArgs(idempotencyPurgeSize).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("INSERT INTO idempotency_keys (.+) ON CONFLICT (.+) RETURNING expires_at").
+		WithArgs(DefaultTenant, "apikey:1", "key-1", "fp", float64(86400), float64(60)).
+		WillReturnRows(sqlmock.NewRows([]string{"expires_at"}).AddRow(expiresAt))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	got, reserved, err := store.ReserveIdempotencyKey("apikey:1", "key-1", "fp", 24*time.Hour)
+
+	if assert.NoError(t, err, "Error was not expected while reserving idempotency key") {
+		assert.True(t, reserved)
+		assert.Equal(t, IdempotencyRecord{Key: "key-1", Fingerprint: "fp", ExpiresAt: expiresAt}, got)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed reserve behaviour")
+}
+
+func TestShouldReturnClaimedIdempotencyKey(t *testing.T) {
+	expiresAt := time.Date(2019, 10, 2, 12, 0, 0, 0, time.UTC)
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM idempotency_keys").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("INSERT INTO idempotency_keys").
+		WillReturnRows(sqlmock.NewRows([]string{"expires_at"}))
+	mock.ExpectQuery("SELECT (.+) FROM idempotency_keys").
+		WithArgs(DefaultTenant, "apikey:1", "key-1").
+		WillReturnRows(sqlmock.NewRows([]string{"fingerprint", "status", "body", "expires_at"}).
+			AddRow("other", 201, []byte(`{"id":1}`), expiresAt))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	got, reserved, err := store.ReserveIdempotencyKey("apikey:1", "key-1", "fp", time.Hour)
+
+	if assert.NoError(t, err, "Error was not expected while reserving idempotency key") {
+		assert.False(t, reserved)
+		assert.Equal(t, IdempotencyRecord{Key: "key-1", Fingerprint: "other", Status: 201, Body: []byte(`{"id":1}`), ExpiresAt: expiresAt}, got)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed reserve behaviour")
+}
+
+func TestShouldCompleteIdempotencyKey(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE idempotency_keys SET status").
+		WithArgs(DefaultTenant, "apikey:1", "key-1", 201, []byte("{}")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	err = store.CompleteIdempotencyKey("apikey:1", "key-1", 201, []byte("{}"))
+
+	assert.NoError(t, err, "Error was not expected while completing idempotency key")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed complete behaviour")
+}
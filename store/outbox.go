@@ -0,0 +1,179 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// OutboxStore hands out the post change events recorded along with every
+// change of a post.
+type OutboxStore interface {
+	// DeliverEvents passes up to limit pending events, oldest first, to
+	// deliver and marks them as delivered if deliver succeeds. It returns the
+	// number of delivered events.
+	DeliverEvents(limit int, deliver func(events []Event) error) (int, error)
+}
+
+// EventLog reads back recorded post change events, e.g. to let clients of
+// the change stream resume where they left off. They resume from the seq of
+// the last event they got: the events of a tenant commit in seq order, while
+// an event may commit after one with a greater id.
+type EventLog interface {
+	GetEvent(id int64) (Event, error)
+	// GetEventsSince returns up to limit events with a seq above seq, oldest
+	// first.
+	GetEventsSince(seq int64, limit int) ([]Event, error)
+}
+
+const eventColumns = "id, seq, type, post_id, before, after, created_at, tenant_id, traceparent"
+
+// insertEvent records an event for the tenant of the post, in the trace of
+// the store.
+func (p *PostgresPostStore) insertEvent(tx *sql.Tx, eventType string, postID int, before, after *Post) error {
+	beforeJSON, err := marshalPayload(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalPayload(after)
+	if err != nil {
+		return err
+	}
+	tenant := DefaultTenant
+	if after != nil && after.TenantID != "" {
+		tenant = after.TenantID
+	} else if before != nil && before.TenantID != "" {
+		tenant = before.TenantID
+	}
+	q := "INSERT INTO outbox(type, post_id, before, after, tenant_id, traceparent) VALUES ($1, $2, $3, $4, $5, $6);"
+	if _, err := tx.Exec(q, eventType, postID, beforeJSON, afterJSON, tenant, p.traceparent()); err != nil {
+		return errors.Wrapf(err, "can't record %s event", eventType)
+	}
+	return nil
+}
+
+func marshalPayload(post *Post) ([]byte, error) {
+	if post == nil {
+		return nil, nil
+	}
+	return json.Marshal(post)
+}
+
+// DeliverEvents locks the pending events with FOR UPDATE SKIP LOCKED for
+// the time of the delivery, so several relays can share the outbox. Events
+// are marked delivered only after deliver returned, hence a crash in between
+// delivers them again: delivery is at least once.
+func (p *PostgresPostStore) DeliverEvents(limit int, deliver func(events []Event) error) (int, error) {
+	var delivered int
+	err := p.inTx(func(tx *sql.Tx) error {
+		q := `SELECT ` + eventColumns + ` FROM outbox
+		WHERE delivered_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED;`
+		rows, err := tx.Query(q, limit)
+		if err != nil {
+			return errors.Wrap(err, "can't get pending events")
+		}
+		events, err := scanEvents(rows)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		if err := deliver(events); err != nil {
+			return err
+		}
+
+		ids := make([]int64, len(events))
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+		q = "UPDATE outbox SET delivered_at = now() WHERE id = ANY($1);"
+		if _, err := tx.Exec(q, pq.Array(ids)); err != nil {
+			return errors.Wrap(err, "can't mark events delivered")
+		}
+		delivered = len(events)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return delivered, nil
+}
+
+func (p *PostgresPostStore) GetEvent(id int64) (Event, error) {
+	var e Event
+	err := p.read(func(db querier) error {
+		q := "SELECT " + eventColumns + " FROM outbox WHERE id = $1 AND ($2 = '' OR tenant_id = $2);"
+		var err error
+		e, err = scanEvent(db.QueryRow(q, id, p.tenant))
+		if err == sql.ErrNoRows {
+			return ErrorEventDoesNotExist
+		}
+		return errors.Wrapf(err, "can't get event %d", id)
+	})
+	return e, err
+}
+
+// GetEventsSince reads the seq column maintained by a trigger, see
+// sql/outbox-seq.sql. Like the changes feed, it is ordered by commit within
+// a tenant only.
+func (p *PostgresPostStore) GetEventsSince(seq int64, limit int) ([]Event, error) {
+	var events []Event
+	err := p.read(func(db querier) error {
+		q := "SELECT " + eventColumns + " FROM outbox WHERE seq > $1 AND ($3 = '' OR tenant_id = $3) ORDER BY seq LIMIT $2;"
+		rows, err := db.Query(q, seq, limit, p.tenant)
+		if err != nil {
+			return errors.Wrapf(err, "can't get events since %d", seq)
+		}
+		events, err = scanEvents(rows)
+		return err
+	})
+	return events, err
+}
+
+func scanEvent(row rowScanner) (Event, error) {
+	var e Event
+	var before, after []byte
+	if err := row.Scan(&e.ID, &e.Seq, &e.Type, &e.PostID, &before, &after, &e.CreatedAt, &e.TenantID, &e.Traceparent); err != nil {
+		return e, err
+	}
+	var err error
+	if e.Before, err = unmarshalPayload(before); err != nil {
+		return e, err
+	}
+	e.After, err = unmarshalPayload(after)
+	return e, err
+}
+
+func scanEvents(rows *sql.Rows) ([]Event, error) {
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't scan event")
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "can't read events")
+	}
+	return events, nil
+}
+
+func unmarshalPayload(raw []byte) (*Post, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var post Post
+	if err := json.Unmarshal(raw, &post); err != nil {
+		return nil, errors.Wrap(err, "can't decode event payload")
+	}
+	return &post, nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var eventRowColumns = []string{"id", "seq", "type", "post_id", "before", "after", "created_at", "tenant_id", "traceparent"}
+
+func TestShouldDeliverEvents(t *testing.T) {
+	createdAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	want := []Event{
+		Event{ID: 5, Seq: 11, Type: EventPostCreated, PostID: 1, After: &Post{ID: 1, Title: "title", Content: "text", Status: StatusDraft}, CreatedAt: createdAt, TenantID: DefaultTenant},
+		Event{ID: 6, Seq: 12, Type: EventPostDeleted, PostID: 1, Before: &Post{ID: 1, Title: "title", Content: "text", Status: StatusDraft}, CreatedAt: createdAt, TenantID: DefaultTenant},
+	}
+	payload := []byte(`{"id":1,"title":"title","content":"text","status":"draft","views":0}`)
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM outbox WHERE delivered_at IS NULL (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(eventRowColumns).
+			AddRow(5, 11, EventPostCreated, 1, nil, payload, createdAt, DefaultTenant, "").
+			AddRow(6, 12, EventPostDeleted, 1, payload, nil, createdAt, DefaultTenant, ""))
+	mock.ExpectExec("UPDATE outbox SET delivered_at").
+		WithArgs("{5,6}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	var got []Event
+	n, err := store.DeliverEvents(10, func(events []Event) error {
+		got = events
+		return nil
+	})
+
+	if assert.NoError(t, err, "Error was not expected while delivering events") {
+		assert.Equal(t, 2, n)
+		assert.Equal(t, want, got, "Unexpected events")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed deliver behaviour")
+}
+
+func TestShouldKeepEventsPendingOnFailedDelivery(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM outbox").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(eventRowColumns).
+			AddRow(5, 11, EventPostDeleted, 1, []byte(`{"id":1}`), nil, time.Now(), DefaultTenant, ""))
+	mock.ExpectRollback()
+
+	store := NewTestPostgresPostStore(db)
+	n, err := store.DeliverEvents(10, func(events []Event) error {
+		return errors.New("sink is down")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed deliver behaviour")
+}
+
+func TestShouldGetEventsSince(t *testing.T) {
+	createdAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	want := []Event{
+		Event{ID: 8, Seq: 14, Type: EventPostDeleted, PostID: 2, Before: &Post{ID: 2}, CreatedAt: createdAt, TenantID: DefaultTenant},
+	}
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM outbox WHERE seq > (.+) ORDER BY seq").
+		WithArgs(7, 100, "").
+		WillReturnRows(sqlmock.NewRows(eventRowColumns).
+			AddRow(8, 14, EventPostDeleted, 2, []byte(`{"id":2}`), nil, createdAt, DefaultTenant, ""))
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.GetEventsSince(7, 100)
+
+	if assert.NoError(t, err, "Error was not expected while getting events") {
+		assert.Equal(t, want, got, "Unexpected events")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed read behaviour")
+}
@@ -2,8 +2,9 @@ package store
 
 import (
 	"database/sql"
+	"time"
+
 	"github.com/pkg/errors"
-	"log"
 
 	. "github.com/dsphub/go-simple-crud-sample/model"
 )
@@ -13,13 +14,33 @@ type PostStore interface {
 	Disconnect() error
 	GetAllPosts() ([]Post, error)
 	GetPostByID(id int) (Post, error)
-	CreatePost(title, text string) error
-	UpdatePost(id int, title, text string) error
+	CreatePost(post Post) (Post, error)
+	UpdatePost(post Post) error
 	DeletePost(id int) error
 }
 
+// PostPublisher is implemented by stores able to publish scheduled posts
+// whose publish time has come.
+type PostPublisher interface {
+	PublishDuePosts(now time.Time, limit int) ([]Post, error)
+}
+
+const postColumns = "id, title, content, status, publish_at, reaction_counts, views, tenant_id, author_id"
+const qualifiedPostColumns = "posts.id, posts.title, posts.content, posts.status, posts.publish_at, posts.reaction_counts, posts.views, posts.tenant_id, posts.author_id"
+
+// PostgresPostStore serves every tenant unless it is scoped to one, see
+// ForTenant. Scoped queries take the tenant as an argument and match every
+// row when it is empty:
+//
+//	($n = '' OR tenant_id = $n)
 type PostgresPostStore struct {
-	db *sql.DB
+	db        *sql.DB
+	tenant    string
+	requestID string
+	span      TraceSpan
+	// audit is the entry recorded with every change, see ForAudit.
+	audit      *AuditEntry
+	auditChain bool
 }
 
 func NewPostgresPostStore(connInfo string) (*PostgresPostStore, error) {
@@ -27,7 +48,7 @@ func NewPostgresPostStore(connInfo string) (*PostgresPostStore, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &PostgresPostStore{db}, nil
+	return &PostgresPostStore{db: db}, nil
 }
 
 func (p *PostgresPostStore) Connect() error {
@@ -38,61 +59,193 @@ func (p *PostgresPostStore) Disconnect() error {
 	return p.db.Close()
 }
 
-func (p *PostgresPostStore) GetAllPosts() ([]Post, error) {
-	rows, err := p.db.Query("SELECT * FROM posts;")
+// Stats returns the statistics of the connection pool.
+func (p *PostgresPostStore) Stats() sql.DBStats {
+	return p.db.Stats()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPost(row rowScanner) (Post, error) {
+	var post Post
+	var reactions []byte
+	err := row.Scan(&post.ID, &post.Title, &post.Content, &post.Status, &post.PublishAt, &reactions, &post.Views, &post.TenantID, &post.AuthorID)
 	if err != nil {
-		return nil, errors.Wrap(err, "can't get all posts")
+		return post, err
 	}
+	post.Reactions, err = decodeReactionCounts(reactions)
+	return post, err
+}
+
+func scanPosts(rows *sql.Rows) ([]Post, error) {
 	defer rows.Close()
 
 	var posts []Post
 	for rows.Next() {
-		var post Post
-		err := rows.Scan(&post.ID, &post.Title, &post.Content)
+		post, err := scanPost(rows)
 		if err != nil {
-			log.Fatal(err)
+			return nil, errors.Wrap(err, "can't scan post")
 		}
 		posts = append(posts, post)
 	}
 	if err := rows.Err(); err != nil {
-		log.Fatal(err)
+		return nil, errors.Wrap(err, "can't read posts")
 	}
 	return posts, nil
 }
 
+// GetAllPosts returns the public listing, i.e. published posts only.
+func (p *PostgresPostStore) GetAllPosts() ([]Post, error) {
+	var posts []Post
+	err := p.read(func(db querier) error {
+		q := "SELECT " + postColumns + " FROM posts WHERE status = $1 AND ($2 = '' OR tenant_id = $2) ORDER BY id;"
+		p.traceStatement(q)
+		rows, err := db.Query(q, StatusPublished, p.tenant)
+		if err != nil {
+			return errors.Wrap(err, "can't get all posts")
+		}
+		posts, err = scanPosts(rows)
+		return err
+	})
+	return posts, err
+}
+
 func (p *PostgresPostStore) GetPostByID(id int) (Post, error) {
 	var post Post
-	err := p.db.QueryRow("SELECT * FROM posts WHERE id = $1;", id).
-		Scan(&post.ID, &post.Title, &post.Content)
-	if err != nil {
-		log.Fatal(err)
-	}
+	err := p.read(func(db querier) error {
+		q := "SELECT " + postColumns + " FROM posts WHERE id = $1 AND ($2 = '' OR tenant_id = $2);"
+		p.traceStatement(q)
+		var err error
+		post, err = scanPost(db.QueryRow(q, id, p.tenant))
+		if err == sql.ErrNoRows {
+			return ErrorPostDoesNotExist
+		}
+		return errors.Wrapf(err, "can't get post %d", id)
+	})
 	return post, err
 }
 
-func (p *PostgresPostStore) CreatePost(title, content string) error {
-	q := "INSERT INTO posts(title, content) VALUES $1, $2);"
-	_, err := p.db.Exec(q, title, content)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return err
+func (p *PostgresPostStore) CreatePost(post Post) (Post, error) {
+	err := p.inTx(func(tx *sql.Tx) error {
+		q := "INSERT INTO posts(title, content, status, publish_at, tenant_id, author_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING " + postColumns + ";"
+		p.traceStatement(q)
+		created, err := scanPost(tx.QueryRow(q, post.Title, post.Content, post.Status, post.PublishAt, p.tenantOf(post.TenantID), post.AuthorID))
+		if err != nil {
+			return errors.Wrap(err, "can't create post")
+		}
+		post = created
+		if err := p.insertEvent(tx, EventPostCreated, post.ID, nil, &post); err != nil {
+			return err
+		}
+		return p.auditChange(tx, EventPostCreated, post.ID)
+	})
+	return post, err
 }
 
-func (p *PostgresPostStore) UpdatePost(id int, title, content string) error {
-	q := "UPDATE posts SET title = $2 content = $3 WHERE id = $1;"
-	_, err := p.db.Exec(q, id, title, content)
+func (p *PostgresPostStore) UpdatePost(post Post) error {
+	return p.inTx(func(tx *sql.Tx) error {
+		before, err := p.lockPost(tx, post.ID)
+		if err != nil {
+			return err
+		}
+		q := "UPDATE posts SET title = $2, content = $3, status = $4, publish_at = $5 WHERE id = $1 RETURNING " + postColumns + ";"
+		p.traceStatement(q)
+		after, err := scanPost(tx.QueryRow(q, post.ID, post.Title, post.Content, post.Status, post.PublishAt))
+		if err != nil {
+			return errors.Wrapf(err, "can't update post %d", post.ID)
+		}
+		if err := p.insertEvent(tx, EventPostUpdated, post.ID, &before, &after); err != nil {
+			return err
+		}
+		return p.auditChange(tx, UpdateAuditAction(before, after), post.ID)
+	})
+}
+
+func (p *PostgresPostStore) DeletePost(id int) error {
+	return p.inTx(func(tx *sql.Tx) error {
+		before, err := p.lockPost(tx, id)
+		if err != nil {
+			return err
+		}
+		q := "DELETE FROM posts WHERE id = $1;"
+		p.traceStatement(q)
+		if _, err := tx.Exec(q, id); err != nil {
+			return errors.Wrapf(err, "can't delete post %d", id)
+		}
+		if err := p.insertEvent(tx, EventPostDeleted, id, &before, nil); err != nil {
+			return err
+		}
+		return p.auditChange(tx, EventPostDeleted, id)
+	})
+}
+
+// PublishDuePosts flips up to limit scheduled posts whose publish time has
+// passed to published. Rows are claimed with FOR UPDATE SKIP LOCKED so that
+// several instances running the scheduler never publish the same post twice
+// and never block each other.
+func (p *PostgresPostStore) PublishDuePosts(now time.Time, limit int) ([]Post, error) {
+	var posts []Post
+	err := p.inTx(func(tx *sql.Tx) error {
+		q := `WITH due AS (
+			SELECT id FROM posts
+			WHERE status = $1 AND publish_at <= $2
+			ORDER BY publish_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE posts SET status = $4 FROM due WHERE posts.id = due.id
+		RETURNING ` + qualifiedPostColumns + ";"
+		rows, err := tx.Query(q, StatusScheduled, now, limit, StatusPublished)
+		if err != nil {
+			return errors.Wrap(err, "can't publish due posts")
+		}
+		posts, err = scanPosts(rows)
+		if err != nil {
+			return err
+		}
+		for i := range posts {
+			before := posts[i]
+			before.Status = StatusScheduled
+			if err := p.insertEvent(tx, EventPostUpdated, before.ID, &before, &posts[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return posts, err
+}
+
+// inTx runs fn in a transaction which is committed if fn succeeds and
+// rolled back otherwise. The transaction of a scoped store is bound to its
+// tenant.
+func (p *PostgresPostStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := p.db.Begin()
 	if err != nil {
-		log.Fatal(err)
+		return errors.Wrap(err, "can't begin transaction")
+	}
+	defer tx.Rollback()
+
+	if err := p.bindTenant(tx); err != nil {
+		return err
 	}
-	return err
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "can't commit transaction")
 }
 
-func (p *PostgresPostStore) DeletePost(id int) error {
-	q := "DELETE FROM posts WHERE id = $1;"
-	_, err := p.db.Exec(q, id)
+// lockPost locks a post of the tenant of the store, so that the following
+// statements of tx can address it by ID alone.
+func (p *PostgresPostStore) lockPost(tx *sql.Tx, id int) (Post, error) {
+	q := "SELECT " + postColumns + " FROM posts WHERE id = $1 AND ($2 = '' OR tenant_id = $2) FOR UPDATE;"
+	post, err := scanPost(tx.QueryRow(q, id, p.tenant))
+	if err == sql.ErrNoRows {
+		return post, ErrorPostDoesNotExist
+	}
 	if err != nil {
-		log.Fatal(err)
+		return post, errors.Wrapf(err, "can't lock post %d", id)
 	}
-	return err
+	return post, nil
 }
@@ -3,6 +3,7 @@ package store
 import (
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	. "github.com/dsphub/go-simple-crud-sample/model"
@@ -10,20 +11,25 @@ import (
 )
 
 func NewTestPostgresPostStore(db *sql.DB) *PostgresPostStore {
-	return &PostgresPostStore{db}
+	return &PostgresPostStore{db: db}
 }
 
+var postRowColumns = []string{"id", "title", "content", "status", "publish_at", "reaction_counts", "views", "tenant_id", "author_id"}
+
 func TestShouldGetAllPosts(t *testing.T) {
+	publishAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
 	want := []Post{
-		Post{ID: 1, Title: "title1", Content: "text1"},
-		Post{ID: 2, Title: "title2", Content: "text2"},
+		Post{ID: 1, Title: "title1", Content: "text1", Status: StatusPublished, PublishAt: &publishAt, Reactions: map[string]int{"👍": 2}, TenantID: DefaultTenant},
+		Post{ID: 2, Title: "title2", Content: "text2", Status: StatusPublished, TenantID: DefaultTenant},
 	}
 	db, mock, err := dbMock(t)
 	defer db.Close()
-	rows := sqlmock.NewRows([]string{"id", "title", "content"}).
-		AddRow(1, "title1", "text1").
-		AddRow(2, "title2", "text2")
-	mock.ExpectQuery("SELECT \\* FROM (.+)").WillReturnRows(rows)
+	rows := sqlmock.NewRows(postRowColumns).
+		AddRow(1, "title1", "text1", "published", publishAt, []byte(`{"👍": 2}`), 0, DefaultTenant, "").
+		AddRow(2, "title2", "text2", "published", nil, []byte(`{}`), 0, DefaultTenant, "")
+	mock.ExpectQuery("SELECT (.+) FROM posts WHERE status = (.+)").
+		WithArgs(StatusPublished, "").
+		WillReturnRows(rows)
 
 	store := NewTestPostgresPostStore(db)
 	got, err := store.GetAllPosts()
@@ -35,12 +41,12 @@ func TestShouldGetAllPosts(t *testing.T) {
 }
 
 func TestShouldGetPostByID(t *testing.T) {
-	want := Post{ID: 1, Title: "title1", Content: "text1"}
+	want := Post{ID: 1, Title: "title1", Content: "text1", Status: StatusDraft, TenantID: DefaultTenant}
 	db, mock, err := dbMock(t)
 	defer db.Close()
-	rows := sqlmock.NewRows([]string{"id", "title", "content"}).
-		AddRow(want.ID, want.Title, want.Content)
-	mock.ExpectQuery("SELECT \\* FROM (.+) WHERE").WillReturnRows(rows)
+	rows := sqlmock.NewRows(postRowColumns).
+		AddRow(want.ID, want.Title, want.Content, want.Status, nil, []byte(`{}`), 0, DefaultTenant, "")
+	mock.ExpectQuery("SELECT (.+) FROM posts WHERE id = (.+)").WillReturnRows(rows)
 
 	store := NewTestPostgresPostStore(db)
 	got, err := store.GetPostByID(1)
@@ -51,48 +57,152 @@ func TestShouldGetPostByID(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet(), "Failed read behaviour")
 }
 
+func TestShouldReturnMissingPost(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM posts WHERE id = (.+)").
+		WillReturnRows(sqlmock.NewRows(postRowColumns))
+
+	store := NewTestPostgresPostStore(db)
+	_, err = store.GetPostByID(1)
+
+	assert.Equal(t, ErrorPostDoesNotExist, err, "Unexpected error for missing post")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed read behaviour")
+}
+
+func TestShouldTagQueriesWithRequest(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT set_config\\('app.tenant_id'").WithArgs("acme").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SELECT set_config\\('application_name'").WithArgs("req-1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT (.+) FROM posts WHERE id = (.+)").
+		WithArgs(1, "acme").
+		WillReturnRows(sqlmock.NewRows(postRowColumns).AddRow(1, "title1", "text1", "draft", nil, []byte(`{}`), 0, "acme", ""))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db).ForTenant("acme").(*PostgresPostStore).ForRequest("req-1").(*PostgresPostStore)
+	_, err = store.GetPostByID(1)
+
+	assert.NoError(t, err, "Error was not expected while getting post")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed request scoped read behaviour")
+}
+
+func TestShouldNotBeginTransactionsForRequestAlone(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM posts WHERE id = (.+)").
+		WithArgs(1, "").
+		WillReturnRows(sqlmock.NewRows(postRowColumns).AddRow(1, "title1", "text1", "draft", nil, []byte(`{}`), 0, DefaultTenant, ""))
+
+	store := NewTestPostgresPostStore(db).ForRequest("req-1").(*PostgresPostStore)
+	_, err = store.GetPostByID(1)
+
+	assert.NoError(t, err, "Error was not expected while getting post")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed request scoped read behaviour")
+}
+
+type fakeSpan map[string]interface{}
+
+func (s fakeSpan) SetAttribute(key string, value interface{}) { s[key] = value }
+
+func (s fakeSpan) Traceparent() string {
+	return "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+}
+
+func TestShouldTraceQueries(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO (.+) VALUES (.+) RETURNING").
+		WithArgs("title", "text", StatusDraft, nil, DefaultTenant, "").
+		WillReturnRows(sqlmock.NewRows(postRowColumns).AddRow(1, "title", "text", StatusDraft, nil, []byte(`{}`), 0, DefaultTenant, ""))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs(EventPostCreated, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), DefaultTenant, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	span := fakeSpan{}
+	store := NewTestPostgresPostStore(db).ForSpan(span).(*PostgresPostStore)
+	_, err = store.CreatePost(Post{Title: "title", Content: "text", Status: StatusDraft})
+
+	assert.NoError(t, err, "Error was not expected while creating post")
+	assert.Equal(t, "postgresql", span["db.system"])
+	assert.Contains(t, span["db.statement"], "INSERT INTO posts")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed traced create behaviour")
+}
+
 func TestShouldCreatePost(t *testing.T) {
-	want := Post{1, "title", "new text"}
+	want := Post{ID: 1, Title: "title", Content: "new text", Status: StatusDraft, TenantID: DefaultTenant}
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("Unexpected error on stub database connection: %s", err)
 	}
 	defer db.Close()
-	mock.ExpectExec("INSERT INTO (.+) VALUES (.+)").
-		WithArgs(want.Title, want.Content).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO (.+) VALUES (.+) RETURNING").
+		WithArgs(want.Title, want.Content, want.Status, nil, DefaultTenant, "").
+		WillReturnRows(sqlmock.NewRows(postRowColumns).AddRow(want.ID, want.Title, want.Content, want.Status, nil, []byte(`{}`), 0, DefaultTenant, ""))
+	expectEvent(mock, EventPostCreated, want.ID)
+	mock.ExpectCommit()
 
 	store := NewTestPostgresPostStore(db)
 
-	err = store.CreatePost(want.Title, want.Content)
+	got, err := store.CreatePost(Post{Title: want.Title, Content: want.Content, Status: want.Status})
 
-	assert.NoError(t, err, "Error was not expected while creating post")
+	if assert.NoError(t, err, "Error was not expected while creating post") {
+		assert.Equal(t, want, got, "Unexpected post")
+	}
 	assert.NoError(t, mock.ExpectationsWereMet(), "Failed create behaviour")
 }
 
 func TestShouldUpdatePost(t *testing.T) {
-	want := Post{1, "new title", "new text"}
+	want := Post{ID: 1, Title: "new title", Content: "new text", Status: StatusArchived}
 	db, mock, err := dbMock(t)
 
 	defer db.Close()
-	mock.ExpectExec("UPDATE (.+) SET (.+) WHERE").
-		WithArgs(want.ID, want.Title, want.Content).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	expectLockPost(mock, want.ID).
+		WillReturnRows(sqlmock.NewRows(postRowColumns).AddRow(want.ID, "title", "text", StatusPublished, nil, []byte(`{}`), 0, DefaultTenant, ""))
+	mock.ExpectQuery("UPDATE (.+) SET (.+) WHERE (.+) RETURNING").
+		WithArgs(want.ID, want.Title, want.Content, want.Status, nil).
+		WillReturnRows(sqlmock.NewRows(postRowColumns).AddRow(want.ID, want.Title, want.Content, want.Status, nil, []byte(`{}`), 0, DefaultTenant, ""))
+	expectEvent(mock, EventPostUpdated, want.ID)
+	mock.ExpectCommit()
 
 	store := NewTestPostgresPostStore(db)
-	err = store.UpdatePost(want.ID, want.Title, want.Content)
+	err = store.UpdatePost(want)
 
 	assert.NoError(t, err, "Error was not expected while updating post")
 	assert.NoError(t, mock.ExpectationsWereMet(), "Failed update behaviour")
 }
 
+func TestShouldNotUpdateMissingPost(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	expectLockPost(mock, 1).WillReturnRows(sqlmock.NewRows(postRowColumns))
+	mock.ExpectRollback()
+
+	store := NewTestPostgresPostStore(db)
+	err = store.UpdatePost(Post{ID: 1, Title: "title", Content: "text", Status: StatusDraft})
+
+	assert.Equal(t, ErrorPostDoesNotExist, err, "Unexpected error for missing post")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed update behaviour")
+}
+
 func TestShouldDeletPost(t *testing.T) {
-	want := Post{1, "", ""}
+	want := Post{ID: 1}
 	db, mock, err := dbMock(t)
 	defer db.Close()
+	mock.ExpectBegin()
+	expectLockPost(mock, want.ID).
+		WillReturnRows(sqlmock.NewRows(postRowColumns).AddRow(want.ID, "title", "text", StatusPublished, nil, []byte(`{}`), 0, DefaultTenant, ""))
 	mock.ExpectExec("DELETE FROM (.+) WHERE").
 		WithArgs(want.ID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, EventPostDeleted, want.ID)
+	mock.ExpectCommit()
 
 	store := NewTestPostgresPostStore(db)
 	err = store.DeletePost(want.ID)
@@ -101,6 +211,41 @@ func TestShouldDeletPost(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet(), "Failed delete behaviour")
 }
 
+func TestShouldPublishDuePosts(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	want := []Post{
+		Post{ID: 3, Title: "title", Content: "text", Status: StatusPublished, PublishAt: &now, TenantID: DefaultTenant},
+	}
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	rows := sqlmock.NewRows(postRowColumns).
+		AddRow(3, "title", "text", "published", now, []byte(`{}`), 0, DefaultTenant, "")
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM posts (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(StatusScheduled, now, 10, StatusPublished).
+		WillReturnRows(rows)
+	expectEvent(mock, EventPostUpdated, 3)
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.PublishDuePosts(now, 10)
+
+	if assert.NoError(t, err, "Error was not expected while publishing posts") {
+		assert.Equal(t, want, got, "Unexpected posts")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed publish behaviour")
+}
+
+func expectLockPost(mock sqlmock.Sqlmock, id int) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery("SELECT (.+) FROM posts WHERE id = (.+) FOR UPDATE").WithArgs(id, "")
+}
+
+func expectEvent(mock sqlmock.Sqlmock, eventType string, postID int) {
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs(eventType, postID, sqlmock.AnyArg(), sqlmock.AnyArg(), DefaultTenant, "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
 func dbMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock, error) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"github.com/pkg/errors"
+)
+
+// RateLimitStore keeps token buckets shared by several server instances.
+type RateLimitStore interface {
+	// TakeRateLimitToken refills the bucket of key with rate tokens per
+	// second up to burst and takes a token if there is one. It returns the
+	// tokens left and whether one was taken.
+	TakeRateLimitToken(key string, rate float64, burst int) (float64, bool, error)
+	// PurgeRateLimitBuckets deletes the buckets that are full again.
+	PurgeRateLimitBuckets() (int, error)
+}
+
+// TakeRateLimitToken runs on the database clock, the clocks of the
+// instances may drift. See sql/rate-limit.sql.
+func (p *PostgresPostStore) TakeRateLimitToken(key string, rate float64, burst int) (float64, bool, error) {
+	var tokens float64
+	var allowed bool
+	q := "SELECT left_tokens, taken FROM take_rate_limit_token($1, $2, $3);"
+	if err := p.db.QueryRow(q, key, rate, burst).Scan(&tokens, &allowed); err != nil {
+		return 0, false, errors.Wrapf(err, "can't take rate limit token of %s", key)
+	}
+	return tokens, allowed, nil
+}
+
+func (p *PostgresPostStore) PurgeRateLimitBuckets() (int, error) {
+	result, err := p.db.Exec("DELETE FROM rate_limit_buckets WHERE full_at <= now();")
+	if err != nil {
+		return 0, errors.Wrap(err, "can't purge rate limit buckets")
+	}
+	n, err := result.RowsAffected()
+	return int(n), errors.Wrap(err, "can't purge rate limit buckets")
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldTakeRateLimitToken(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT left_tokens, taken FROM take_rate_limit_token").
+		WithArgs("ip:10.0.0.1 read /", 2.5, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"left_tokens", "taken"}).AddRow(3.5, true))
+
+	store := NewTestPostgresPostStore(db)
+	tokens, allowed, err := store.TakeRateLimitToken("ip:10.0.0.1 read /", 2.5, 10)
+
+	if assert.NoError(t, err, "Error was not expected while taking token") {
+		assert.True(t, allowed)
+		assert.Equal(t, 3.5, tokens)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed rate limit behaviour")
+}
+
+func TestShouldPurgeRateLimitBuckets(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectExec("DELETE FROM rate_limit_buckets WHERE full_at").WillReturnResult(sqlmock.NewResult(0, 4))
+
+	store := NewTestPostgresPostStore(db)
+	n, err := store.PurgeRateLimitBuckets()
+
+	if assert.NoError(t, err, "Error was not expected while purging buckets") {
+		assert.Equal(t, 4, n)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed purge behaviour")
+}
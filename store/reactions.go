@@ -0,0 +1,114 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// ReactionStore keeps one reaction per user and post. The counts per emoji
+// are maintained along with the post, so listing posts needs no join.
+type ReactionStore interface {
+	React(postID int, user, emoji string) (map[string]int, error)
+	Unreact(postID int, user string) (map[string]int, error)
+}
+
+// React sets the reaction of user to the post, replacing the previous one.
+func (p *PostgresPostStore) React(postID int, user, emoji string) (map[string]int, error) {
+	if !ValidReaction(emoji) {
+		return nil, ErrorReactionInvalid
+	}
+	return p.updateReaction(postID, user, emoji)
+}
+
+// Unreact removes the reaction of user to the post, if any.
+func (p *PostgresPostStore) Unreact(postID int, user string) (map[string]int, error) {
+	return p.updateReaction(postID, user, "")
+}
+
+// updateReaction replaces the reaction of user by emoji, or removes it when
+// emoji is empty, and adjusts the counters of the post accordingly. The post
+// row is locked first so that concurrent reactions to the same post can't
+// lose counter updates.
+func (p *PostgresPostStore) updateReaction(postID int, user, emoji string) (map[string]int, error) {
+	var counts map[string]int
+	err := p.inTx(func(tx *sql.Tx) error {
+		var raw []byte
+		q := "SELECT reaction_counts FROM posts WHERE id = $1 AND ($2 = '' OR tenant_id = $2) FOR UPDATE;"
+		err := tx.QueryRow(q, postID, p.tenant).Scan(&raw)
+		if err == sql.ErrNoRows {
+			return ErrorPostDoesNotExist
+		}
+		if err != nil {
+			return errors.Wrapf(err, "can't lock post %d", postID)
+		}
+		if counts, err = decodeReactionCounts(raw); err != nil {
+			return err
+		}
+
+		var previous string
+		err = tx.QueryRow("SELECT emoji FROM reactions WHERE post_id = $1 AND user_id = $2;", postID, user).Scan(&previous)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.Wrap(err, "can't get reaction")
+		}
+		if previous == emoji {
+			return nil
+		}
+		if emoji == "" {
+			_, err = tx.Exec("DELETE FROM reactions WHERE post_id = $1 AND user_id = $2;", postID, user)
+		} else {
+			q = `INSERT INTO reactions(post_id, user_id, emoji) VALUES ($1, $2, $3)
+			ON CONFLICT (post_id, user_id) DO UPDATE SET emoji = EXCLUDED.emoji, created_at = now();`
+			_, err = tx.Exec(q, postID, user, emoji)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "can't change reaction to post %d", postID)
+		}
+
+		counts = adjustReactionCounts(counts, previous, emoji)
+		raw, err = json.Marshal(counts)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE posts SET reaction_counts = $2 WHERE id = $1;", postID, raw); err != nil {
+			return errors.Wrapf(err, "can't update reaction counts of post %d", postID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func adjustReactionCounts(counts map[string]int, previous, emoji string) map[string]int {
+	if counts == nil {
+		counts = make(map[string]int)
+	}
+	if previous != "" {
+		counts[previous]--
+		if counts[previous] <= 0 {
+			delete(counts, previous)
+		}
+	}
+	if emoji != "" {
+		counts[emoji]++
+	}
+	return counts
+}
+
+func decodeReactionCounts(raw []byte) (map[string]int, error) {
+	var counts map[string]int
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &counts); err != nil {
+			return nil, errors.Wrap(err, "can't decode reaction counts")
+		}
+	}
+	if len(counts) == 0 {
+		return nil, nil
+	}
+	return counts, nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldReplaceReaction(t *testing.T) {
+	want := map[string]int{"👍": 1, "🎉": 1}
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT reaction_counts FROM posts WHERE id = (.+) FOR UPDATE").
+		WithArgs(1, "").
+		WillReturnRows(sqlmock.NewRows([]string{"reaction_counts"}).AddRow([]byte(`{"👍": 2}`)))
+	mock.ExpectQuery("SELECT emoji FROM reactions").
+		WithArgs(1, "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"emoji"}).AddRow("👍"))
+	mock.ExpectExec("INSERT INTO reactions(.+) ON CONFLICT").
+		WithArgs(1, "alice", "🎉").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE posts SET reaction_counts").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.React(1, "alice", "🎉")
+
+	if assert.NoError(t, err, "Error was not expected while reacting") {
+		assert.Equal(t, want, got, "Unexpected reaction counts")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed react behaviour")
+}
+
+func TestShouldRemoveReaction(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT reaction_counts FROM posts WHERE id = (.+) FOR UPDATE").
+		WithArgs(1, "").
+		WillReturnRows(sqlmock.NewRows([]string{"reaction_counts"}).AddRow([]byte(`{"👍": 1}`)))
+	mock.ExpectQuery("SELECT emoji FROM reactions").
+		WithArgs(1, "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"emoji"}).AddRow("👍"))
+	mock.ExpectExec("DELETE FROM reactions").
+		WithArgs(1, "alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE posts SET reaction_counts").
+		WithArgs(1, []byte(`{}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.Unreact(1, "alice")
+
+	if assert.NoError(t, err, "Error was not expected while removing reaction") {
+		assert.Empty(t, got, "Unexpected reaction counts")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed unreact behaviour")
+}
+
+func TestShouldRejectUnknownReaction(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+
+	store := NewTestPostgresPostStore(db)
+	_, err = store.React(1, "alice", "🦄")
+
+	assert.Equal(t, ErrorReactionInvalid, err)
+	assert.NoError(t, mock.ExpectationsWereMet(), "Unexpected queries")
+}
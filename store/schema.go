@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var ErrorSchemaOutdated = errors.New("schema outdated")
+
+const (
+	undefinedTable  = "42P01"
+	undefinedColumn = "42703"
+)
+
+// schemaMigrations select, for every script of sql/ the server can't run
+// without, a table or a column it adds. The scripts of optional features,
+// e.g. the accounts, are left out.
+var schemaMigrations = []struct {
+	script string
+	query  string
+}{
+	{"post-table.sql", "SELECT publish_at FROM posts"},
+	{"reaction-table.sql", "SELECT post_id FROM reactions"},
+	{"view-table.sql", "SELECT views FROM posts"},
+	{"attachment-table.sql", "SELECT hash FROM attachments"},
+	{"outbox-table.sql", "SELECT id FROM outbox"},
+	{"webhook-table.sql", "SELECT id FROM webhook_attempts"},
+	{"post-changes.sql", "SELECT change_seq FROM post_tombstones"},
+	{"tenant.sql", "SELECT tenant_id FROM post_tombstones"},
+	{"outbox-seq.sql", "SELECT seq FROM outbox"},
+	{"audit-log.sql", "SELECT id FROM audit_log"},
+	{"idempotency-table.sql", "SELECT actor FROM idempotency_keys"},
+	{"post-author.sql", "SELECT author_id FROM posts"},
+	{"trace-context.sql", "SELECT traceparent FROM webhook_deliveries"},
+}
+
+// Ping checks that the database can be reached, see Connect.
+func (p *PostgresPostStore) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
+// CheckSchema checks that the scripts of sql/ the server needs were run, it
+// returns ErrorSchemaOutdated naming the first one that was not.
+func (p *PostgresPostStore) CheckSchema(ctx context.Context) error {
+	for _, m := range schemaMigrations {
+		rows, err := p.db.QueryContext(ctx, m.query+" LIMIT 0")
+		if pqErr, ok := err.(*pq.Error); ok && (pqErr.Code == undefinedTable || pqErr.Code == undefinedColumn) {
+			return errors.Wrapf(ErrorSchemaOutdated, "sql/%s not applied", m.script)
+		}
+		if err != nil {
+			return errors.Wrap(err, "can't check schema")
+		}
+		rows.Close()
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"io/ioutil"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldCheckSchema(t *testing.T) {
+	db, mock, _ := dbMock(t)
+	defer db.Close()
+	for _, m := range schemaMigrations {
+		mock.ExpectQuery(regexp.QuoteMeta(m.query + " LIMIT 0")).WillReturnRows(sqlmock.NewRows([]string{"column"}))
+	}
+
+	err := NewTestPostgresPostStore(db).CheckSchema(context.Background())
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed schema check behaviour")
+}
+
+func TestShouldReportMissingMigration(t *testing.T) {
+	db, mock, _ := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT publish_at FROM posts").WillReturnRows(sqlmock.NewRows([]string{"publish_at"}))
+	mock.ExpectQuery("SELECT post_id FROM reactions").WillReturnError(&pq.Error{Code: undefinedTable})
+
+	err := NewTestPostgresPostStore(db).CheckSchema(context.Background())
+
+	assert.Equal(t, ErrorSchemaOutdated, errors.Cause(err))
+	assert.Contains(t, err.Error(), "sql/reaction-table.sql not applied")
+}
+
+func TestShouldNotTakeFailuresForMissingMigrations(t *testing.T) {
+	db, mock, _ := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT publish_at FROM posts").WillReturnError(errors.New("connection refused"))
+
+	err := NewTestPostgresPostStore(db).CheckSchema(context.Background())
+
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrorSchemaOutdated, errors.Cause(err))
+}
+
+func TestShouldIsolateTenantsWithRowLevelSecurity(t *testing.T) {
+	script, err := ioutil.ReadFile("../sql/tenant.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range []string{"posts", "outbox", "webhooks", "post_tombstones", "attachments", "reactions", "post_views"} {
+		for _, statement := range []string{
+			"ALTER TABLE " + table + " ENABLE ROW LEVEL SECURITY;",
+			"ALTER TABLE " + table + " FORCE ROW LEVEL SECURITY;",
+			"CREATE POLICY tenant_isolation ON " + table + "\n",
+		} {
+			assert.Contains(t, string(script), statement, "Table %s is not isolated", table)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// TenantScoper is implemented by stores shared by several tenants.
+type TenantScoper interface {
+	// ForTenant returns a view of the store that only reads and writes the
+	// data of tenant. The view implements the same interfaces as the store.
+	ForTenant(tenant string) TenantScoper
+}
+
+// ForTenant scopes every query by tenant_id. The queries of the view also run
+// in transactions that set app.tenant_id, so that the row-level security
+// policies reject whatever a query would let through, see sql/tenant.sql.
+//
+// The unscoped store serves every tenant; it is meant for the background
+// workers and the command line tools.
+func (p *PostgresPostStore) ForTenant(tenant string) TenantScoper {
+	scoped := *p
+	scoped.tenant = tenant
+	return &scoped
+}
+
+// RequestScoper is implemented by stores able to tell the database which
+// request their queries are made for.
+type RequestScoper interface {
+	// ForRequest returns a view of the store tagging its queries with
+	// requestID. The view implements the same interfaces as the store.
+	ForRequest(requestID string) RequestScoper
+}
+
+// ForRequest sets application_name to requestID in the transactions of the
+// view, so that pg_stat_activity and the server logs (%a in log_line_prefix)
+// tell the request behind their statements. Reads that need no transaction
+// don't get one for it: they stay single statements, untagged.
+func (p *PostgresPostStore) ForRequest(requestID string) RequestScoper {
+	scoped := *p
+	scoped.requestID = requestID
+	return &scoped
+}
+
+// TraceSpan is the trace span of a store operation, see trace.Span.
+type TraceSpan interface {
+	SetAttribute(key string, value interface{})
+	Traceparent() string
+}
+
+// SpanScoper is implemented by stores able to describe their queries to a
+// trace span.
+type SpanScoper interface {
+	// ForSpan returns a view of the store recording its queries in span.
+	// The view implements the same interfaces as the store.
+	ForSpan(span TraceSpan) SpanScoper
+}
+
+// ForSpan records the statements of the post operations in span, and the
+// trace context of span with the events of the changes, so that the webhook
+// deliveries continue the trace.
+func (p *PostgresPostStore) ForSpan(span TraceSpan) SpanScoper {
+	span.SetAttribute("db.system", "postgresql")
+	scoped := *p
+	scoped.span = span
+	return &scoped
+}
+
+// traceStatement records the statement of an operation in the span of the
+// store, if any.
+func (p *PostgresPostStore) traceStatement(q string) {
+	if p.span != nil {
+		p.span.SetAttribute("db.statement", q)
+	}
+}
+
+func (p *PostgresPostStore) traceparent() string {
+	if p.span == nil {
+		return ""
+	}
+	return p.span.Traceparent()
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// read runs fn on the db, or in a transaction bound to the tenant for a
+// tenant-scoped store. fn must be done with its rows when it returns.
+func (p *PostgresPostStore) read(fn func(q querier) error) error {
+	if p.tenant == "" {
+		return fn(p.db)
+	}
+	return p.inTx(func(tx *sql.Tx) error {
+		return fn(tx)
+	})
+}
+
+func (p *PostgresPostStore) bindTenant(tx *sql.Tx) error {
+	if p.tenant != "" {
+		_, err := tx.Exec("SELECT set_config('app.tenant_id', $1, true);", p.tenant)
+		if err != nil {
+			return errors.Wrap(err, "can't bind transaction to tenant")
+		}
+	}
+	if p.requestID != "" {
+		_, err := tx.Exec("SELECT set_config('application_name', $1, true);", p.requestID)
+		if err != nil {
+			return errors.Wrap(err, "can't bind transaction to request")
+		}
+	}
+	return nil
+}
+
+// tenantOf returns the tenant new rows are written for: the tenant of a
+// scoped store, whatever the row says.
+func (p *PostgresPostStore) tenantOf(tenant string) string {
+	if p.tenant != "" {
+		return p.tenant
+	}
+	if tenant == "" {
+		return DefaultTenant
+	}
+	return tenant
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// PostSource yields the posts to import. Next returns io.EOF after the last
+// post.
+type PostSource interface {
+	Next() (Post, error)
+}
+
+type ImportOptions struct {
+	// Upsert updates the posts whose ID exists instead of failing.
+	Upsert bool
+	// DryRun checks the posts without keeping them, nor taking IDs from the
+	// post ID sequence.
+	DryRun bool
+}
+
+// PostExporter is implemented by stores able to stream every post,
+// whatever its status, in ID order.
+type PostExporter interface {
+	ExportPosts(fn func(post Post) error) error
+}
+
+// PostImporter is implemented by stores able to load posts in bulk. The IDs
+// of the imported posts are kept; posts without one get a new ID.
+type PostImporter interface {
+	ImportPosts(source PostSource, options ImportOptions) (int, error)
+}
+
+// errorRollback aborts a transaction that succeeded, see ImportOptions.DryRun.
+var errorRollback = errors.New("rollback")
+
+// ExportPosts streams every post without loading them all in memory.
+func (p *PostgresPostStore) ExportPosts(fn func(post Post) error) error {
+	return p.read(func(db querier) error {
+		rows, err := db.Query("SELECT "+postColumns+" FROM posts WHERE ($1 = '' OR tenant_id = $1) ORDER BY id;", p.tenant)
+		if err != nil {
+			return errors.Wrap(err, "can't export posts")
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			post, err := scanPost(rows)
+			if err != nil {
+				return errors.Wrap(err, "can't scan post")
+			}
+			if err := fn(post); err != nil {
+				return err
+			}
+		}
+		return errors.Wrap(rows.Err(), "can't read posts")
+	})
+}
+
+// ImportPosts loads the posts with COPY into a staging table and moves them
+// to posts in one statement, so that an import is applied entirely or not at
+// all. Imports are not recorded in the outbox: they restore data rather than
+// change it, and must not flood the event consumers.
+func (p *PostgresPostStore) ImportPosts(source PostSource, options ImportOptions) (int, error) {
+	var count int
+	err := p.inTx(func(tx *sql.Tx) error {
+		q := `CREATE TEMP TABLE import_posts (
+			id INTEGER,
+			title TEXT,
+			content TEXT,
+			status TEXT,
+			publish_at TIMESTAMPTZ,
+			reaction_counts JSONB,
+			views BIGINT,
+			tenant_id TEXT,
+			author_id TEXT
+		) ON COMMIT DROP;`
+		if _, err := tx.Exec(q); err != nil {
+			return errors.Wrap(err, "can't create import table")
+		}
+		if err := copyPosts(tx, source, p.tenantOf); err != nil {
+			return err
+		}
+
+		// Sequences ignore rollbacks, a dry run gives the posts without an ID
+		// negative ones rather than taking them from the sequence.
+		newID := "nextval(pg_get_serial_sequence('posts', 'id'))"
+		if options.DryRun {
+			newID = "-(ROW_NUMBER() OVER ())::integer"
+		}
+		q = "INSERT INTO posts (" + postColumns + `)
+			SELECT COALESCE(id, ` + newID + `),
+				title, content, status, publish_at, reaction_counts, views, tenant_id, author_id
+			FROM import_posts`
+		if options.Upsert {
+			q += ` ON CONFLICT (id) DO UPDATE SET
+				title = EXCLUDED.title,
+				content = EXCLUDED.content,
+				status = EXCLUDED.status,
+				publish_at = EXCLUDED.publish_at,
+				reaction_counts = EXCLUDED.reaction_counts,
+				views = EXCLUDED.views,
+				author_id = EXCLUDED.author_id
+			WHERE posts.tenant_id = EXCLUDED.tenant_id`
+		}
+		result, err := tx.Exec(q + ";")
+		if err != nil {
+			return errors.Wrap(err, "can't import posts")
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "can't import posts")
+		}
+		count = int(affected)
+		if options.DryRun {
+			return errorRollback
+		}
+
+		// Imported IDs bypass the sequence, move it past them. It is never
+		// moved back: a scoped store doesn't see the posts of other tenants.
+		q = `SELECT setval(seq, GREATEST(nextval(seq), (SELECT COALESCE(MAX(id), 0) + 1 FROM import_posts)), false)
+			FROM pg_get_serial_sequence('posts', 'id') AS seq;`
+		if _, err := tx.Exec(q); err != nil {
+			return errors.Wrap(err, "can't reset post id sequence")
+		}
+		return nil
+	})
+	if err == errorRollback {
+		err = nil
+	}
+	return count, err
+}
+
+// copyPosts copies the posts to import_posts, each under the tenant returned
+// by tenantOf.
+func copyPosts(tx *sql.Tx, source PostSource, tenantOf func(tenant string) string) error {
+	stmt, err := tx.Prepare(pq.CopyIn("import_posts", "id", "title", "content", "status", "publish_at", "reaction_counts", "views", "tenant_id", "author_id"))
+	if err != nil {
+		return errors.Wrap(err, "can't start copying posts")
+	}
+	defer stmt.Close()
+
+	for {
+		post, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var id interface{}
+		if post.ID != 0 {
+			id = post.ID
+		}
+		reactions := []byte("{}")
+		if len(post.Reactions) > 0 {
+			if reactions, err = json.Marshal(post.Reactions); err != nil {
+				return errors.Wrap(err, "can't encode reaction counts")
+			}
+		}
+		// COPY would send []byte as bytea, the counts are passed as text.
+		if _, err := stmt.Exec(id, post.Title, post.Content, post.Status, post.PublishAt, string(reactions), post.Views, tenantOf(post.TenantID), post.AuthorID); err != nil {
+			return errors.Wrapf(err, "can't copy post %d", post.ID)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		return errors.Wrap(err, "can't copy posts")
+	}
+	return nil
+}
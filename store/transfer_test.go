@@ -0,0 +1,118 @@
+package store
+
+import (
+	"io"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type postSlice []Post
+
+func (s *postSlice) Next() (Post, error) {
+	if len(*s) == 0 {
+		return Post{}, io.EOF
+	}
+	post := (*s)[0]
+	*s = (*s)[1:]
+	return post, nil
+}
+
+func TestShouldExportPosts(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	rows := sqlmock.NewRows(postRowColumns).
+		AddRow(1, "title1", "text1", "draft", nil, []byte(`{}`), 0, DefaultTenant, "").
+		AddRow(2, "title2", "text2", "published", nil, []byte(`{"👍": 1}`), 3, DefaultTenant, "")
+	mock.ExpectQuery("SELECT (.+) FROM posts WHERE (.+) ORDER BY id").WithArgs("").WillReturnRows(rows)
+
+	store := NewTestPostgresPostStore(db)
+	var got []Post
+	err = store.ExportPosts(func(post Post) error {
+		got = append(got, post)
+		return nil
+	})
+
+	if assert.NoError(t, err, "Error was not expected while exporting posts") {
+		assert.Equal(t, []Post{
+			{ID: 1, Title: "title1", Content: "text1", Status: StatusDraft, TenantID: DefaultTenant},
+			{ID: 2, Title: "title2", Content: "text2", Status: StatusPublished, Reactions: map[string]int{"👍": 1}, Views: 3, TenantID: DefaultTenant},
+		}, got, "Unexpected posts")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed export behaviour")
+}
+
+func expectCopyPosts(mock sqlmock.Sqlmock, posts int) {
+	mock.ExpectExec("CREATE TEMP TABLE import_posts").WillReturnResult(sqlmock.NewResult(0, 0))
+	stmt := mock.ExpectPrepare(`COPY "import_posts" (.+) FROM STDIN`)
+	stmt.ExpectExec().WithArgs(1, "title", "text", StatusPublished, nil, `{"👍":2}`, int64(5), DefaultTenant, "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	stmt.ExpectExec().WithArgs(nil, "title", "text", StatusDraft, nil, "{}", int64(0), DefaultTenant, "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	stmt.ExpectExec().WithArgs().WillReturnResult(sqlmock.NewResult(0, int64(posts)))
+}
+
+func importedPosts() *postSlice {
+	return &postSlice{
+		{ID: 1, Title: "title", Content: "text", Status: StatusPublished, Reactions: map[string]int{"👍": 2}, Views: 5},
+		{Title: "title", Content: "text", Status: StatusDraft},
+	}
+}
+
+func TestShouldImportPostsWithCopy(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	expectCopyPosts(mock, 2)
+	mock.ExpectExec(`INSERT INTO posts (.+) SELECT COALESCE\(id, nextval(.+) FROM import_posts;`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("SELECT setval").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	count, err := store.ImportPosts(importedPosts(), ImportOptions{})
+
+	if assert.NoError(t, err, "Error was not expected while importing posts") {
+		assert.Equal(t, 2, count)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed import behaviour")
+}
+
+func TestShouldUpsertImportedPosts(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	expectCopyPosts(mock, 2)
+	mock.ExpectExec(`INSERT INTO posts (.+) FROM import_posts ON CONFLICT \(id\) DO UPDATE`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("SELECT setval").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	count, err := store.ImportPosts(importedPosts(), ImportOptions{Upsert: true})
+
+	if assert.NoError(t, err, "Error was not expected while upserting posts") {
+		assert.Equal(t, 2, count)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed upsert behaviour")
+}
+
+func TestShouldRollbackDryRunImport(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	expectCopyPosts(mock, 2)
+	mock.ExpectExec(`INSERT INTO posts (.+) SELECT COALESCE\(id, -\(ROW_NUMBER\(\) OVER \(\)\)::integer\)(.+) FROM import_posts`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectRollback()
+
+	store := NewTestPostgresPostStore(db)
+	count, err := store.ImportPosts(importedPosts(), ImportOptions{DryRun: true})
+
+	if assert.NoError(t, err, "Error was not expected while checking posts") {
+		assert.Equal(t, 2, count)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed dry run behaviour")
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// UserStore keeps the local accounts. Like the API keys, users are not
+// scoped by tenant: the user tells the tenant. Emails are unique per tenant
+// only, they are looked up within one.
+type UserStore interface {
+	// CreateUser returns ErrorUserExists if the email is taken in the tenant
+	// of the user.
+	CreateUser(user User) (User, error)
+	// GetUserByEmail returns the user of the email in tenant, the default
+	// tenant if empty.
+	GetUserByEmail(tenant, email string) (User, error)
+	GetUserByID(id int) (User, error)
+	SetUserRoles(id int, roles []string) error
+	// RecordLoginFailure counts a failed login of the user, and locks its
+	// logins for lockFor once maxFailures are reached. It returns until
+	// when the user is locked, if it is.
+	RecordLoginFailure(id, maxFailures int, lockFor time.Duration) (*time.Time, error)
+	ResetLoginFailures(id int) error
+	// VerifyUser marks the email of the user as verified.
+	VerifyUser(id int) error
+	// SetUserPassword also lifts the lockout of the user.
+	SetUserPassword(id int, hash string) error
+}
+
+// SessionStore keeps the sessions of the users, by token hash.
+type SessionStore interface {
+	CreateSession(session Session) error
+	GetSession(hash string) (Session, error)
+	DeleteSession(hash string) error
+	DeleteUserSessions(userID int) error
+}
+
+const userColumns = "id, email, password_hash, roles, tenant_id, created_at, failed_logins, locked_until, verified_at"
+
+// expiredSessionsPurge bounds the expired sessions deleted per login.
+const expiredSessionsPurge = 10
+
+func scanUser(row rowScanner) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, pq.Array(&u.Roles), &u.TenantID, &u.CreatedAt, &u.FailedLogins, &u.LockedUntil, &u.VerifiedAt)
+	return u, err
+}
+
+func (p *PostgresPostStore) CreateUser(u User) (User, error) {
+	u.TenantID = p.tenantOf(u.TenantID)
+	q := `INSERT INTO users(email, password_hash, roles, tenant_id) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant_id, email) DO NOTHING
+		RETURNING id, created_at;`
+	err := p.db.QueryRow(q, u.Email, u.PasswordHash, pq.Array(u.Roles), u.TenantID).Scan(&u.ID, &u.CreatedAt)
+	if err == sql.ErrNoRows {
+		return u, ErrorUserExists
+	}
+	return u, errors.Wrap(err, "can't create user")
+}
+
+func (p *PostgresPostStore) GetUserByEmail(tenant, email string) (User, error) {
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	q := "SELECT " + userColumns + " FROM users WHERE tenant_id = $1 AND email = $2;"
+	u, err := scanUser(p.db.QueryRow(q, tenant, email))
+	if err == sql.ErrNoRows {
+		return u, ErrorUserDoesNotExist
+	}
+	return u, errors.Wrap(err, "can't get user")
+}
+
+func (p *PostgresPostStore) GetUserByID(id int) (User, error) {
+	u, err := scanUser(p.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1;", id))
+	if err == sql.ErrNoRows {
+		return u, ErrorUserDoesNotExist
+	}
+	return u, errors.Wrapf(err, "can't get user %d", id)
+}
+
+func (p *PostgresPostStore) SetUserRoles(id int, roles []string) error {
+	result, err := p.db.Exec("UPDATE users SET roles = $2 WHERE id = $1;", id, pq.Array(roles))
+	return userUpdated(result, err, "can't set roles of user %d", id)
+}
+
+func (p *PostgresPostStore) RecordLoginFailure(id, maxFailures int, lockFor time.Duration) (*time.Time, error) {
+	q := `UPDATE users SET
+			failed_logins = CASE WHEN failed_logins + 1 >= $2 THEN 0 ELSE failed_logins + 1 END,
+			locked_until = CASE WHEN failed_logins + 1 >= $2 THEN now() + make_interval(secs => $3) ELSE locked_until END
+		WHERE id = $1
+		RETURNING locked_until;`
+	var lockedUntil *time.Time
+	err := p.db.QueryRow(q, id, maxFailures, lockFor.Seconds()).Scan(&lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, ErrorUserDoesNotExist
+	}
+	return lockedUntil, errors.Wrapf(err, "can't record login failure of user %d", id)
+}
+
+func (p *PostgresPostStore) ResetLoginFailures(id int) error {
+	result, err := p.db.Exec("UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = $1;", id)
+	return userUpdated(result, err, "can't reset login failures of user %d", id)
+}
+
+// VerifyUser keeps the first verification time.
+func (p *PostgresPostStore) VerifyUser(id int) error {
+	result, err := p.db.Exec("UPDATE users SET verified_at = COALESCE(verified_at, now()) WHERE id = $1;", id)
+	return userUpdated(result, err, "can't verify user %d", id)
+}
+
+func (p *PostgresPostStore) SetUserPassword(id int, hash string) error {
+	q := "UPDATE users SET password_hash = $2, failed_logins = 0, locked_until = NULL WHERE id = $1;"
+	result, err := p.db.Exec(q, id, hash)
+	return userUpdated(result, err, "can't set password of user %d", id)
+}
+
+func userUpdated(result sql.Result, err error, format string, id int) error {
+	if err != nil {
+		return errors.Wrapf(err, format, id)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, format, id)
+	}
+	if n == 0 {
+		return ErrorUserDoesNotExist
+	}
+	return nil
+}
+
+// CreateSession also deletes a few expired sessions, so that the table
+// doesn't grow with the sessions never logged out of.
+func (p *PostgresPostStore) CreateSession(s Session) error {
+	q := `DELETE FROM sessions WHERE id IN (
+		SELECT id FROM sessions WHERE expires_at < now() LIMIT $1);`
+	if _, err := p.db.Exec(q, expiredSessionsPurge); err != nil {
+		return errors.Wrap(err, "can't purge expired sessions")
+	}
+	q = "INSERT INTO sessions(id, user_id, expires_at) VALUES ($1, $2, $3);"
+	_, err := p.db.Exec(q, s.Hash, s.UserID, s.ExpiresAt)
+	return errors.Wrap(err, "can't create session")
+}
+
+// GetSession returns ErrorSessionDoesNotExist for expired sessions too.
+func (p *PostgresPostStore) GetSession(hash string) (Session, error) {
+	s := Session{Hash: hash}
+	q := "SELECT user_id, created_at, expires_at FROM sessions WHERE id = $1 AND expires_at > now();"
+	err := p.db.QueryRow(q, hash).Scan(&s.UserID, &s.CreatedAt, &s.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return s, ErrorSessionDoesNotExist
+	}
+	return s, errors.Wrap(err, "can't get session")
+}
+
+func (p *PostgresPostStore) DeleteSession(hash string) error {
+	_, err := p.db.Exec("DELETE FROM sessions WHERE id = $1;", hash)
+	return errors.Wrap(err, "can't delete session")
+}
+
+func (p *PostgresPostStore) DeleteUserSessions(userID int) error {
+	_, err := p.db.Exec("DELETE FROM sessions WHERE user_id = $1;", userID)
+	return errors.Wrapf(err, "can't delete sessions of user %d", userID)
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var userRowColumns = []string{"id", "email", "password_hash", "roles", "tenant_id", "created_at", "failed_logins", "locked_until", "verified_at"}
+
+func TestShouldCreateUser(t *testing.T) {
+	createdAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	db, mock, _ := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("INSERT INTO users(.+) ON CONFLICT (.+) DO NOTHING RETURNING id, created_at").
+		WithArgs("alice@example.com", "hash", "{\"author\"}", DefaultTenant).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, createdAt))
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("alice@example.com", "hash", "{\"author\"}", DefaultTenant).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}))
+
+	store := NewTestPostgresPostStore(db)
+	user := User{Email: "alice@example.com", PasswordHash: "hash", Roles: []string{"author"}}
+	got, err := store.CreateUser(user)
+	_, taken := store.CreateUser(user)
+
+	if assert.NoError(t, err, "Error was not expected while creating user") {
+		assert.Equal(t, 3, got.ID)
+		assert.Equal(t, createdAt, got.CreatedAt)
+		assert.Equal(t, DefaultTenant, got.TenantID)
+	}
+	assert.Equal(t, ErrorUserExists, taken)
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed create behaviour")
+}
+
+func TestShouldGetUserByEmail(t *testing.T) {
+	createdAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	want := User{ID: 3, Email: "alice@example.com", PasswordHash: "hash", Roles: []string{"author"}, TenantID: "acme", CreatedAt: createdAt, FailedLogins: 2}
+	db, mock, _ := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE tenant_id = (.+) AND email = ").
+		WithArgs("acme", "alice@example.com").
+		WillReturnRows(sqlmock.NewRows(userRowColumns).AddRow(3, "alice@example.com", "hash", "{author}", "acme", createdAt, 2, nil, nil))
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE tenant_id = (.+) AND email = ").
+		WithArgs(DefaultTenant, "bob@example.com").
+		WillReturnRows(sqlmock.NewRows(userRowColumns))
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.GetUserByEmail("acme", "alice@example.com")
+	_, missing := store.GetUserByEmail("", "bob@example.com")
+
+	if assert.NoError(t, err, "Error was not expected while getting user") {
+		assert.Equal(t, want, got, "Unexpected user")
+	}
+	assert.Equal(t, ErrorUserDoesNotExist, missing)
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed get behaviour")
+}
+
+func TestShouldRecordLoginFailure(t *testing.T) {
+	lockedUntil := time.Date(2019, 10, 1, 12, 15, 0, 0, time.UTC)
+	db, mock, _ := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("UPDATE users SET (.+) RETURNING locked_until").
+		WithArgs(3, 5, float64(900)).
+		WillReturnRows(sqlmock.NewRows([]string{"locked_until"}).AddRow(lockedUntil))
+	mock.ExpectExec("UPDATE users SET failed_logins = 0, locked_until = NULL").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.RecordLoginFailure(3, 5, 15*time.Minute)
+	reset := store.ResetLoginFailures(3)
+
+	if assert.NoError(t, err, "Error was not expected while recording login failure") {
+		assert.Equal(t, &lockedUntil, got)
+	}
+	assert.NoError(t, reset)
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed lockout behaviour")
+}
+
+func TestShouldCreateAndGetSession(t *testing.T) {
+	createdAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := createdAt.Add(24 * time.Hour)
+	db, mock, _ := dbMock(t)
+	defer db.Close()
+	mock.ExpectExec("DELETE FROM sessions WHERE id IN (.+) expires_at < now()").
+		WithArgs(expiredSessionsPurge).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO sessions").
+		WithArgs("hash", 3, expiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM sessions WHERE id = (.+) AND expires_at > now()").
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "created_at", "expires_at"}).AddRow(3, createdAt, expiresAt))
+	mock.ExpectQuery("SELECT (.+) FROM sessions").
+		WithArgs("expired").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "created_at", "expires_at"}))
+
+	store := NewTestPostgresPostStore(db)
+	err := store.CreateSession(Session{Hash: "hash", UserID: 3, ExpiresAt: expiresAt})
+	got, getErr := store.GetSession("hash")
+	_, missing := store.GetSession("expired")
+
+	assert.NoError(t, err, "Error was not expected while creating session")
+	if assert.NoError(t, getErr, "Error was not expected while getting session") {
+		assert.Equal(t, Session{Hash: "hash", UserID: 3, CreatedAt: createdAt, ExpiresAt: expiresAt}, got)
+	}
+	assert.Equal(t, ErrorSessionDoesNotExist, missing)
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed session behaviour")
+}
+
+func TestShouldResetPassword(t *testing.T) {
+	db, mock, _ := dbMock(t)
+	defer db.Close()
+	mock.ExpectExec("UPDATE users SET password_hash = (.+), failed_logins = 0, locked_until = NULL").
+		WithArgs(3, "new hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM sessions WHERE user_id = ").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE users SET verified_at = COALESCE").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	store := NewTestPostgresPostStore(db)
+
+	assert.NoError(t, store.SetUserPassword(3, "new hash"))
+	assert.NoError(t, store.DeleteUserSessions(3))
+	assert.Equal(t, ErrorUserDoesNotExist, store.VerifyUser(4))
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed reset behaviour")
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// ViewStore keeps the view counts of posts. Besides the total kept on the
+// post, views are rolled up per hour to rank recently popular posts.
+type ViewStore interface {
+	AddPostViews(views map[int]int64, at time.Time) error
+	GetPopularPosts(since time.Time, limit int) ([]Post, error)
+}
+
+// AddPostViews adds a batch of view counts in a single transaction.
+func (p *PostgresPostStore) AddPostViews(views map[int]int64, at time.Time) error {
+	if len(views) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(views))
+	counts := make([]int64, 0, len(views))
+	for id, n := range views {
+		ids = append(ids, int64(id))
+		counts = append(counts, n)
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "can't begin transaction")
+	}
+	defer tx.Rollback()
+
+	q := `UPDATE posts SET views = posts.views + v.n
+	FROM unnest($1::int[], $2::bigint[]) AS v(id, n)
+	WHERE posts.id = v.id;`
+	if _, err := tx.Exec(q, pq.Array(ids), pq.Array(counts)); err != nil {
+		return errors.Wrap(err, "can't add post views")
+	}
+	q = `INSERT INTO post_views(post_id, bucket, views)
+	SELECT v.id, date_trunc('hour', $3::timestamptz), v.n
+	FROM unnest($1::int[], $2::bigint[]) AS v(id, n)
+	JOIN posts ON posts.id = v.id
+	ON CONFLICT (post_id, bucket) DO UPDATE SET views = post_views.views + EXCLUDED.views;`
+	if _, err := tx.Exec(q, pq.Array(ids), pq.Array(counts), at); err != nil {
+		return errors.Wrap(err, "can't roll up post views")
+	}
+	return errors.Wrap(tx.Commit(), "can't commit post views")
+}
+
+// GetPopularPosts returns the published posts viewed most since the given
+// time. Views are rolled up per hour, so since is rounded down to the hour.
+func (p *PostgresPostStore) GetPopularPosts(since time.Time, limit int) ([]Post, error) {
+	q := `SELECT ` + qualifiedPostColumns + ` FROM posts
+	JOIN (
+		SELECT post_id, sum(views) AS recent FROM post_views
+		WHERE bucket >= date_trunc('hour', $1::timestamptz)
+		GROUP BY post_id
+	) v ON v.post_id = posts.id
+	WHERE posts.status = $2 AND ($4 = '' OR posts.tenant_id = $4)
+	ORDER BY v.recent DESC, posts.id
+	LIMIT $3;`
+	var posts []Post
+	err := p.read(func(db querier) error {
+		rows, err := db.Query(q, since, StatusPublished, limit, p.tenant)
+		if err != nil {
+			return errors.Wrap(err, "can't get popular posts")
+		}
+		posts, err = scanPosts(rows)
+		return err
+	})
+	return posts, err
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldAddPostViews(t *testing.T) {
+	at := time.Date(2019, 10, 1, 12, 30, 0, 0, time.UTC)
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE posts SET views = (.+) FROM unnest").
+		WithArgs("{1}", "{3}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO post_views(.+) ON CONFLICT").
+		WithArgs("{1}", "{3}", at).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	err = store.AddPostViews(map[int]int64{1: 3}, at)
+
+	assert.NoError(t, err, "Error was not expected while adding views")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed add views behaviour")
+}
+
+func TestShouldGetPopularPosts(t *testing.T) {
+	since := time.Date(2019, 10, 1, 12, 30, 0, 0, time.UTC)
+	want := []Post{
+		Post{ID: 2, Title: "title", Content: "text", Status: StatusPublished, Views: 42, TenantID: DefaultTenant},
+	}
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	rows := sqlmock.NewRows(postRowColumns).
+		AddRow(2, "title", "text", "published", nil, []byte(`{}`), 42, DefaultTenant, "")
+	mock.ExpectQuery("SELECT (.+) FROM posts JOIN (.+) FROM post_views").
+		WithArgs(since, StatusPublished, 5, "").
+		WillReturnRows(rows)
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.GetPopularPosts(since, 5)
+
+	if assert.NoError(t, err, "Error was not expected while getting popular posts") {
+		assert.Equal(t, want, got, "Unexpected posts")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed popular posts behaviour")
+}
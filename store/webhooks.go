@@ -0,0 +1,271 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+// WebhookStore keeps the registered webhooks and the durable queue of their
+// deliveries.
+type WebhookStore interface {
+	CreateWebhook(webhook Webhook) (Webhook, error)
+	GetWebhooks() ([]Webhook, error)
+	GetWebhook(id int) (Webhook, error)
+	UpdateWebhook(webhook Webhook) error
+	DeleteWebhook(id int) error
+	GetDeliveries(webhookID int, limit int) ([]Delivery, error)
+
+	// EnqueueDeliveries queues every event for the active webhooks
+	// subscribed to it. Enqueuing an event twice has no effect.
+	EnqueueDeliveries(events []Event) error
+	// ClaimDeliveries hands out up to limit pending deliveries due by now.
+	// They are not handed out again before lease has passed, unless the
+	// attempt is recorded earlier.
+	ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]DeliveryJob, error)
+	// RecordAttempt stores an attempt of a delivery along with the status
+	// of the delivery and the time of the next attempt, if any.
+	RecordAttempt(deliveryID int64, attempt DeliveryAttempt, status DeliveryStatus, next *time.Time) error
+}
+
+// DeliveryJob is a claimed delivery with all that's needed to attempt it.
+type DeliveryJob struct {
+	Delivery
+	Attempt int
+	URL     string
+	Secret  string
+	Payload []byte
+	// Traceparent is the trace context of the change delivered, if any.
+	Traceparent string
+}
+
+const webhookColumns = "id, url, secret, event_types, active, created_at, tenant_id"
+
+func scanWebhook(row rowScanner) (Webhook, error) {
+	var w Webhook
+	err := row.Scan(&w.ID, &w.URL, &w.Secret, pq.Array(&w.EventTypes), &w.Active, &w.CreatedAt, &w.TenantID)
+	return w, err
+}
+
+// CreateWebhook registers a webhook for the events of one tenant.
+func (p *PostgresPostStore) CreateWebhook(w Webhook) (Webhook, error) {
+	w.TenantID = p.tenantOf(w.TenantID)
+	err := p.read(func(db querier) error {
+		q := "INSERT INTO webhooks(url, secret, event_types, active, tenant_id) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at;"
+		err := db.QueryRow(q, w.URL, w.Secret, pq.Array(w.EventTypes), w.Active, w.TenantID).Scan(&w.ID, &w.CreatedAt)
+		return errors.Wrap(err, "can't create webhook")
+	})
+	return w, err
+}
+
+func (p *PostgresPostStore) GetWebhooks() ([]Webhook, error) {
+	webhooks := []Webhook{}
+	err := p.read(func(db querier) error {
+		rows, err := db.Query("SELECT "+webhookColumns+" FROM webhooks WHERE ($1 = '' OR tenant_id = $1) ORDER BY id;", p.tenant)
+		if err != nil {
+			return errors.Wrap(err, "can't get webhooks")
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			w, err := scanWebhook(rows)
+			if err != nil {
+				return errors.Wrap(err, "can't scan webhook")
+			}
+			webhooks = append(webhooks, w)
+		}
+		return errors.Wrap(rows.Err(), "can't read webhooks")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (p *PostgresPostStore) GetWebhook(id int) (Webhook, error) {
+	var w Webhook
+	err := p.read(func(db querier) error {
+		q := "SELECT " + webhookColumns + " FROM webhooks WHERE id = $1 AND ($2 = '' OR tenant_id = $2);"
+		var err error
+		w, err = scanWebhook(db.QueryRow(q, id, p.tenant))
+		if err == sql.ErrNoRows {
+			return ErrorWebhookDoesNotExist
+		}
+		return errors.Wrapf(err, "can't get webhook %d", id)
+	})
+	return w, err
+}
+
+func (p *PostgresPostStore) UpdateWebhook(w Webhook) error {
+	return p.read(func(db querier) error {
+		q := "UPDATE webhooks SET url = $2, secret = $3, event_types = $4, active = $5 WHERE id = $1 AND ($6 = '' OR tenant_id = $6);"
+		res, err := db.Exec(q, w.ID, w.URL, w.Secret, pq.Array(w.EventTypes), w.Active, p.tenant)
+		if err != nil {
+			return errors.Wrapf(err, "can't update webhook %d", w.ID)
+		}
+		return checkWebhookAffected(res)
+	})
+}
+
+func (p *PostgresPostStore) DeleteWebhook(id int) error {
+	return p.read(func(db querier) error {
+		res, err := db.Exec("DELETE FROM webhooks WHERE id = $1 AND ($2 = '' OR tenant_id = $2);", id, p.tenant)
+		if err != nil {
+			return errors.Wrapf(err, "can't delete webhook %d", id)
+		}
+		return checkWebhookAffected(res)
+	})
+}
+
+func checkWebhookAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrorWebhookDoesNotExist
+	}
+	return nil
+}
+
+// GetDeliveries returns the latest deliveries of a webhook along with all
+// their attempts, newest first.
+func (p *PostgresPostStore) GetDeliveries(webhookID int, limit int) ([]Delivery, error) {
+	var deliveries []Delivery
+	err := p.read(func(db querier) error {
+		var err error
+		deliveries, err = getDeliveries(db, webhookID, limit, p.tenant)
+		return err
+	})
+	return deliveries, err
+}
+
+func getDeliveries(db querier, webhookID int, limit int, tenant string) ([]Delivery, error) {
+	q := `SELECT id, webhook_id, event_id, event_type, status, next_attempt_at, created_at
+	FROM webhook_deliveries
+	WHERE webhook_id = $1 AND ($3 = '' OR webhook_id IN (SELECT id FROM webhooks WHERE tenant_id = $3))
+	ORDER BY id DESC LIMIT $2;`
+	rows, err := db.Query(q, webhookID, limit, tenant)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't get deliveries of webhook %d", webhookID)
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	index := make(map[int64]int)
+	var ids []int64
+	for rows.Next() {
+		d := Delivery{Attempts: []DeliveryAttempt{}}
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.NextAttemptAt, &d.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't scan delivery")
+		}
+		index[d.ID] = len(deliveries)
+		ids = append(ids, d.ID)
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "can't read deliveries")
+	}
+	if len(ids) == 0 {
+		return deliveries, nil
+	}
+
+	q = `SELECT delivery_id, attempt, COALESCE(status_code, 0), COALESCE(error, ''), duration, created_at
+	FROM webhook_attempts WHERE delivery_id = ANY($1) ORDER BY delivery_id, attempt;`
+	rows, err = db.Query(q, pq.Array(ids))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get delivery attempts")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var a DeliveryAttempt
+		if err := rows.Scan(&id, &a.Attempt, &a.StatusCode, &a.Error, &a.Duration, &a.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "can't scan delivery attempt")
+		}
+		d := &deliveries[index[id]]
+		d.Attempts = append(d.Attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "can't read delivery attempts")
+	}
+	return deliveries, nil
+}
+
+// EnqueueDeliveries queues an event for the webhooks of its tenant only.
+func (p *PostgresPostStore) EnqueueDeliveries(events []Event) error {
+	return p.inTx(func(tx *sql.Tx) error {
+		q := `INSERT INTO webhook_deliveries(webhook_id, event_id, event_type, payload, traceparent)
+		SELECT id, $1, $2, $3, $5 FROM webhooks WHERE active AND $2 = ANY(event_types) AND tenant_id = $4
+		ON CONFLICT (webhook_id, event_id) DO NOTHING;`
+		for _, e := range events {
+			payload, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(q, e.ID, e.Type, payload, p.tenantOf(e.TenantID), e.Traceparent); err != nil {
+				return errors.Wrapf(err, "can't enqueue deliveries of event %d", e.ID)
+			}
+		}
+		return nil
+	})
+}
+
+// ClaimDeliveries pushes the next attempt of the claimed deliveries out by
+// the lease, so other dispatchers skip them while they are in flight.
+func (p *PostgresPostStore) ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]DeliveryJob, error) {
+	q := `WITH due AS (
+		SELECT d.id FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = $1 AND d.next_attempt_at <= $2 AND w.active
+		ORDER BY d.next_attempt_at
+		LIMIT $4
+		FOR UPDATE OF d SKIP LOCKED
+	)
+	UPDATE webhook_deliveries d SET next_attempt_at = $3, attempts = d.attempts + 1
+	FROM due, webhooks w
+	WHERE d.id = due.id AND w.id = d.webhook_id
+	RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.status, d.created_at, d.attempts, w.url, w.secret, d.payload, d.traceparent;`
+	rows, err := p.db.Query(q, DeliveryPending, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't claim deliveries")
+	}
+	defer rows.Close()
+
+	var jobs []DeliveryJob
+	for rows.Next() {
+		var j DeliveryJob
+		err := rows.Scan(&j.ID, &j.WebhookID, &j.EventID, &j.EventType, &j.Status, &j.CreatedAt,
+			&j.Attempt, &j.URL, &j.Secret, &j.Payload, &j.Traceparent)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't scan delivery")
+		}
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "can't read deliveries")
+	}
+	return jobs, nil
+}
+
+func (p *PostgresPostStore) RecordAttempt(deliveryID int64, a DeliveryAttempt, status DeliveryStatus, next *time.Time) error {
+	return p.inTx(func(tx *sql.Tx) error {
+		q := `INSERT INTO webhook_attempts(delivery_id, attempt, status_code, error, duration, created_at)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, ''), $5, $6);`
+		_, err := tx.Exec(q, deliveryID, a.Attempt, a.StatusCode, a.Error, int64(a.Duration), a.CreatedAt)
+		if err != nil {
+			return errors.Wrapf(err, "can't record attempt of delivery %d", deliveryID)
+		}
+		q = "UPDATE webhook_deliveries SET status = $2, next_attempt_at = $3 WHERE id = $1;"
+		if _, err := tx.Exec(q, deliveryID, status, next); err != nil {
+			return errors.Wrapf(err, "can't update delivery %d", deliveryID)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldCreateWebhook(t *testing.T) {
+	createdAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	want := Webhook{ID: 3, URL: "http://localhost/hook", Secret: "secret", EventTypes: []string{EventPostCreated}, Active: true, CreatedAt: createdAt, TenantID: DefaultTenant}
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("INSERT INTO webhooks(.+) RETURNING id, created_at").
+		WithArgs(want.URL, want.Secret, "{\"post.created\"}", true, DefaultTenant).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, createdAt))
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.CreateWebhook(Webhook{URL: want.URL, Secret: want.Secret, EventTypes: want.EventTypes, Active: true})
+
+	if assert.NoError(t, err, "Error was not expected while creating webhook") {
+		assert.Equal(t, want, got, "Unexpected webhook")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed create behaviour")
+}
+
+func TestShouldEnqueueDeliveries(t *testing.T) {
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO webhook_deliveries(.+) SELECT (.+) FROM webhooks (.+) ON CONFLICT (.+) DO NOTHING").
+		WithArgs(7, EventPostDeleted, sqlmock.AnyArg(), DefaultTenant, "").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	store := NewTestPostgresPostStore(db)
+	err = store.EnqueueDeliveries([]Event{{ID: 7, Type: EventPostDeleted, PostID: 1}})
+
+	assert.NoError(t, err, "Error was not expected while enqueuing deliveries")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed enqueue behaviour")
+}
+
+func TestShouldClaimDeliveries(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	rows := sqlmock.NewRows([]string{"id", "webhook_id", "event_id", "event_type", "status", "created_at", "attempts", "url", "secret", "payload", "traceparent"}).
+		AddRow(9, 3, 7, EventPostDeleted, DeliveryPending, now, 2, "http://localhost/hook", "secret", []byte(`{"id":7}`), "")
+	mock.ExpectQuery("SELECT (.+) FROM webhook_deliveries (.+) FOR UPDATE OF d SKIP LOCKED").
+		WithArgs(DeliveryPending, now, now.Add(time.Minute), 10).
+		WillReturnRows(rows)
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.ClaimDeliveries(now, time.Minute, 10)
+
+	if assert.NoError(t, err, "Error was not expected while claiming deliveries") && assert.Len(t, got, 1) {
+		assert.Equal(t, int64(9), got[0].ID)
+		assert.Equal(t, 2, got[0].Attempt)
+		assert.Equal(t, "secret", got[0].Secret)
+		assert.Equal(t, []byte(`{"id":7}`), got[0].Payload)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed claim behaviour")
+}
+
+func TestShouldGetDeliveriesWithAttempts(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	want := []Delivery{
+		Delivery{ID: 9, WebhookID: 3, EventID: 7, EventType: EventPostDeleted, Status: DeliveryDelivered, CreatedAt: now,
+			Attempts: []DeliveryAttempt{
+				{Attempt: 1, Error: "connection refused", Duration: time.Millisecond, CreatedAt: now},
+				{Attempt: 2, StatusCode: 200, Duration: time.Millisecond, CreatedAt: now},
+			}},
+	}
+	db, mock, err := dbMock(t)
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM webhook_deliveries WHERE webhook_id = (.+)").
+		WithArgs(3, 50, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "event_id", "event_type", "status", "next_attempt_at", "created_at"}).
+			AddRow(9, 3, 7, EventPostDeleted, DeliveryDelivered, nil, now))
+	mock.ExpectQuery("SELECT (.+) FROM webhook_attempts").
+		WithArgs("{9}").
+		WillReturnRows(sqlmock.NewRows([]string{"delivery_id", "attempt", "status_code", "error", "duration", "created_at"}).
+			AddRow(9, 1, 0, "connection refused", int64(time.Millisecond), now).
+			AddRow(9, 2, 200, "", int64(time.Millisecond), now))
+
+	store := NewTestPostgresPostStore(db)
+	got, err := store.GetDeliveries(3, 50)
+
+	if assert.NoError(t, err, "Error was not expected while getting deliveries") {
+		assert.Equal(t, want, got, "Unexpected deliveries")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "Failed read behaviour")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+const tenantHeader = "X-Tenant-ID"
+
+// TenantResolver identifies the tenant a request is made for. It returns
+// ErrorTenantMissing or ErrorTenantInvalid for requests that don't tell a
+// usable tenant, and any other error for requests that are not allowed to
+// act for the tenant they tell.
+type TenantResolver func(r *http.Request) (string, error)
+
+// HeaderTenant reads the tenant from the X-Tenant-ID header. It trusts the
+// client and is meant to run behind a gateway that sets the header.
+func HeaderTenant(r *http.Request) (string, error) {
+	tenant := r.Header.Get(tenantHeader)
+	if tenant == "" {
+		return "", ErrorTenantMissing
+	}
+	if !ValidTenant(tenant) {
+		return "", ErrorTenantInvalid
+	}
+	return tenant, nil
+}
+
+// WithTenants makes the server multi-tenant: every request is served with
+// stores scoped to the tenant resolved for it, so that a post of another
+// tenant is reported missing whatever its ID. The configured stores must
+// implement TenantScoper.
+func WithTenants(resolve TenantResolver) ServerOption {
+	return func(p *PostServer) {
+		p.resolveTenant = resolve
+	}
+}
+
+// scoped serves the requests with handler, called on a copy of the server
+// scoped to the request, see forRequest, and to its tenant when the server
+// is multi-tenant.
+func (p *PostServer) scoped(handler func(p *PostServer, w http.ResponseWriter, r *http.Request)) http.Handler {
+	if p.resolveTenant == nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(p.forRequest(r), w, r)
+		})
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, err := p.resolveTenant(r)
+		if err == nil && !ValidTenant(tenant) {
+			err = ErrorTenantInvalid
+		}
+		switch err {
+		case nil:
+			handler(p.forTenant(tenant).forRequest(r), w, r)
+		case ErrorTenantMissing, ErrorTenantInvalid:
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+}
+
+// forTenant returns a copy of the server whose stores only see the data of
+// tenant. The views counter is shared: it only counts the views of posts
+// that were read through a scoped store.
+func (p *PostServer) forTenant(tenant string) *PostServer {
+	scoped := p.withStores(func(store interface{}) interface{} {
+		return scopeStore(store, tenant)
+	})
+	scoped.tenant = tenant
+	return scoped
+}
+
+// withStores returns a copy of the server with the stores scope returns for
+// its configured ones.
+func (p *PostServer) withStores(scope func(store interface{}) interface{}) *PostServer {
+	scoped := *p
+	scoped.store = scope(p.store).(PostStore)
+	if p.attachments != nil {
+		scoped.attachments = scope(p.attachments).(AttachmentStore)
+	}
+	if p.reactions != nil {
+		scoped.reactions = scope(p.reactions).(ReactionStore)
+	}
+	if p.viewStore != nil {
+		scoped.viewStore = scope(p.viewStore).(ViewStore)
+	}
+	if p.eventLog != nil {
+		scoped.eventLog = scope(p.eventLog).(EventLog)
+	}
+	if p.webhooks != nil {
+		scoped.webhooks = scope(p.webhooks).(WebhookStore)
+	}
+	if p.changes != nil {
+		scoped.changes = scope(p.changes).(ChangeStore)
+	}
+	if p.audit != nil {
+		scoped.audit = scope(p.audit).(AuditLog)
+	}
+	if p.idempotency != nil {
+		scoped.idempotency = scope(p.idempotency).(IdempotencyStore)
+	}
+	return &scoped
+}
+
+func scopeStore(store interface{}, tenant string) TenantScoper {
+	return store.(TenantScoper).ForTenant(tenant)
+}
+
+// checkTenantScopers panics unless every configured store can be scoped,
+// rather than failing on the first request.
+func (p *PostServer) checkTenantScopers() {
+	stores := []interface{}{p.store, p.attachments, p.reactions, p.viewStore, p.eventLog, p.webhooks, p.changes, p.audit, p.idempotency}
+	for _, store := range stores {
+		if store == nil {
+			continue
+		}
+		if _, ok := store.(TenantScoper); !ok {
+			panic(fmt.Sprintf("%T can't be scoped by tenant", store))
+		}
+	}
+}
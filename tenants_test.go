@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	store := EmptyInMemoryPostStore()
+	server := NewPostServer(std, store,
+		WithTenants(HeaderTenant),
+		WithReactions(store),
+		WithChanges(store))
+
+	response := serveForTenant(server, "acme", newCreatePostRequest("title", "text"))
+	assertStatus(t, response.Code, http.StatusCreated)
+	post := getSinglePostFromResponse(t, response.Body)
+	if post.TenantID != "acme" {
+		t.Fatalf("got tenant %q, want acme", post.TenantID)
+	}
+	store.Posts[post.ID] = Post{ID: post.ID, Title: "title", Content: "text", Status: StatusPublished, TenantID: "acme"}
+
+	t.Run("owner sees the post", func(t *testing.T) {
+		response := serveForTenant(server, "acme", newGetPostByIDRequest(post.ID))
+
+		assertStatus(t, response.Code, http.StatusOK)
+	})
+
+	t.Run("other tenants can't reach the post by ID", func(t *testing.T) {
+		for _, request := range []*http.Request{
+			newGetPostByIDRequest(post.ID),
+			newUpdatePostRequest(post.ID, "stolen", "text"),
+			newDeletePostRequest(post.ID),
+			newReactionRequest(http.MethodPost, post.ID, "mallory", "👍"),
+		} {
+			response := serveForTenant(server, "globex", request)
+
+			assertStatus(t, response.Code, http.StatusNotFound)
+		}
+		if got := store.Posts[post.ID]; got.Title != "title" || got.Reactions != nil {
+			t.Errorf("post was changed by another tenant: %+v", got)
+		}
+	})
+
+	t.Run("other tenants get an empty list and feed", func(t *testing.T) {
+		response := serveForTenant(server, "globex", newGetAllPostsRequest())
+		assertPosts(t, []Post{}, getPostsFromResponse(t, response.Body))
+
+		request, _ := http.NewRequest(http.MethodGet, "/posts/changes", nil)
+		response = serveForTenant(server, "globex", request)
+		var feed ChangeFeed
+		json.NewDecoder(response.Body).Decode(&feed)
+		if len(feed.Changes) != 0 {
+			t.Errorf("got changes of another tenant %+v", feed.Changes)
+		}
+	})
+
+	t.Run("return 400 without a valid tenant", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newGetAllPostsRequest())
+		assertStatus(t, response.Code, http.StatusBadRequest)
+
+		response = serveForTenant(server, "../acme", newGetAllPostsRequest())
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+}
+
+func TestTenantsRequireScopableStores(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a store that can't be scoped")
+		}
+	}()
+	NewPostServer(std, &StubFailedPostStore{}, WithTenants(HeaderTenant))
+}
+
+func serveForTenant(server http.Handler, tenant string, request *http.Request) *httptest.ResponseRecorder {
+	request.Header.Set(tenantHeader, tenant)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
@@ -0,0 +1,116 @@
+package testdata
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+)
+
+// FakeSMTPServer accepts every mail on a local port and keeps it.
+type FakeSMTPServer struct {
+	Addr     string
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []SMTPMessage
+}
+
+type SMTPMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+func NewFakeSMTPServer() (*FakeSMTPServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &FakeSMTPServer{Addr: listener.Addr().String(), listener: listener}
+	go s.serve()
+	return s, nil
+}
+
+func (s *FakeSMTPServer) Close() error {
+	return s.listener.Close()
+}
+
+// Messages returns the mails received so far.
+func (s *FakeSMTPServer) Messages() []SMTPMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SMTPMessage{}, s.messages...)
+}
+
+func (s *FakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *FakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+	reply("220 localhost fake ESMTP")
+	var msg SMTPMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case verb == "EHLO":
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case verb == "HELO" || verb == "NOOP":
+			reply("250 OK")
+		case verb == "RSET":
+			msg = SMTPMessage{}
+			reply("250 OK")
+		case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+			msg = SMTPMessage{From: address(line)}
+			reply("250 OK")
+		case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+			msg.To = append(msg.To, address(line))
+			reply("250 OK")
+		case verb == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			msg.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 OK")
+		case verb == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func address(line string) string {
+	line = line[strings.Index(line, ":")+1:]
+	return strings.Trim(strings.Fields(line)[0], "<>")
+}
@@ -0,0 +1,26 @@
+package testdata
+
+import (
+	"sync"
+
+	"github.com/dsphub/go-simple-crud-sample/trace"
+)
+
+// RecordingExporter keeps the exported spans.
+type RecordingExporter struct {
+	mu    sync.Mutex
+	spans []trace.SpanData
+}
+
+func (e *RecordingExporter) Export(span trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the exported spans, in the order they ended.
+func (e *RecordingExporter) Spans() []trace.SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]trace.SpanData(nil), e.spans...)
+}
@@ -0,0 +1,62 @@
+package testdata
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+)
+
+// SignToken signs claims with key, a []byte HS256 secret, an RS256
+// *rsa.PrivateKey or an ES256 *ecdsa.PrivateKey.
+func SignToken(key interface{}, kid string, claims map[string]interface{}) string {
+	header := map[string]string{"typ": "JWT", "kid": kid}
+	switch key.(type) {
+	case []byte:
+		header["alg"] = "HS256"
+	case *rsa.PrivateKey:
+		header["alg"] = "RS256"
+	case *ecdsa.PrivateKey:
+		header["alg"] = "ES256"
+	}
+	signed := encodeSegment(header) + "." + encodeSegment(claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(key, signed))
+}
+
+func sign(key interface{}, signed string) []byte {
+	digest := sha256.Sum256([]byte(signed))
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		return mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			panic(err)
+		}
+		return signature
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			panic(err)
+		}
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature
+	}
+	panic("unsupported key")
+}
+
+func encodeSegment(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
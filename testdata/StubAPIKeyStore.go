@@ -0,0 +1,55 @@
+package testdata
+
+import (
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+type StubAPIKeyStore struct {
+	Keys []APIKey
+}
+
+// AddKey creates a key with scopes for tenant and returns it.
+func (s *StubAPIKeyStore) AddKey(tenant string, scopes ...string) string {
+	key, hash, err := NewAPIKey()
+	if err != nil {
+		panic(err)
+	}
+	s.CreateAPIKey(APIKey{Name: "test", Prefix: key[:12], Hash: hash, Scopes: scopes, TenantID: tenant})
+	return key
+}
+
+func (s *StubAPIKeyStore) CreateAPIKey(k APIKey) (APIKey, error) {
+	k.ID = len(s.Keys) + 1
+	k.CreatedAt = time.Now().UTC()
+	if k.TenantID == "" {
+		k.TenantID = DefaultTenant
+	}
+	s.Keys = append(s.Keys, k)
+	return k, nil
+}
+
+func (s *StubAPIKeyStore) GetAPIKeyByHash(hash string) (APIKey, error) {
+	for _, k := range s.Keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return APIKey{}, ErrorAPIKeyDoesNotExist
+}
+
+func (s *StubAPIKeyStore) GetAPIKeys() ([]APIKey, error) {
+	return append([]APIKey{}, s.Keys...), nil
+}
+
+func (s *StubAPIKeyStore) RevokeAPIKey(id int) error {
+	if id < 1 || id > len(s.Keys) {
+		return ErrorAPIKeyDoesNotExist
+	}
+	if s.Keys[id-1].RevokedAt == nil {
+		now := time.Now().UTC()
+		s.Keys[id-1].RevokedAt = &now
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package testdata
+
+import (
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+// StubAuditedPostStore is the view of a stub store returned by ForAudit. It
+// appends the entry of every change it makes to Log, failing the change if
+// it can't.
+type StubAuditedPostStore struct {
+	PostStore
+	Log   AuditLog
+	Entry AuditEntry
+	Chain bool
+}
+
+func (s *StubPostStore) ForAudit(entry AuditEntry, chain bool) AuditScoper {
+	return &StubAuditedPostStore{s, s, entry, chain}
+}
+
+func (s *StubTenantPostStore) ForAudit(entry AuditEntry, chain bool) AuditScoper {
+	return &StubAuditedPostStore{s, s, entry, chain}
+}
+
+func (s *StubAuditedPostStore) ForAudit(entry AuditEntry, chain bool) AuditScoper {
+	return &StubAuditedPostStore{s.PostStore, s.Log, entry, chain}
+}
+
+func (s *StubAuditedPostStore) CreatePost(post Post) (Post, error) {
+	post, err := s.PostStore.CreatePost(post)
+	if err != nil {
+		return post, err
+	}
+	return post, s.append(EventPostCreated, post.ID)
+}
+
+func (s *StubAuditedPostStore) UpdatePost(post Post) error {
+	before, err := s.PostStore.GetPostByID(post.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.PostStore.UpdatePost(post); err != nil {
+		return err
+	}
+	return s.append(UpdateAuditAction(before, post), post.ID)
+}
+
+func (s *StubAuditedPostStore) DeletePost(id int) error {
+	if err := s.PostStore.DeletePost(id); err != nil {
+		return err
+	}
+	return s.append(EventPostDeleted, id)
+}
+
+func (s *StubAuditedPostStore) append(action string, postID int) error {
+	entry := s.Entry
+	entry.Action = action
+	entry.PostID = postID
+	entry.At = time.Now().UTC()
+	_, err := s.Log.AppendAudit(entry, s.Chain)
+	return err
+}
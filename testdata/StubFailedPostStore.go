@@ -23,11 +23,11 @@ func (s *StubFailedPostStore) GetPostByID(id int) (Post, error) {
 	return Post{}, ErrorPostDoesNotExist
 }
 
-func (s *StubFailedPostStore) CreatePost(title, text string) error {
-	return ErrorPostIsNotCreated
+func (s *StubFailedPostStore) CreatePost(post Post) (Post, error) {
+	return Post{}, ErrorPostIsNotCreated
 }
 
-func (s *StubFailedPostStore) UpdatePost(id int, title, text string) error {
+func (s *StubFailedPostStore) UpdatePost(post Post) error {
 	return ErrorPostDoesNotExist
 }
 
@@ -1,10 +1,34 @@
 package testdata
 
-import . "github.com/dsphub/go-simple-crud-sample/model"
+import (
+	"sort"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
 
 type StubPostStore struct {
-	Counter int
-	Posts   map[int]Post
+	Counter     int
+	Posts       map[int]Post
+	Attachments []Attachment
+	// Reactions holds the reaction of every user by post.
+	Reactions map[int]map[string]string
+	ViewLog   []ViewBatch
+	// Outbox holds the post change events, the first Delivered of them
+	// were delivered.
+	Outbox    []Event
+	Delivered int
+	// Publish, if set, is sent every event as its change is made, like the
+	// Postgres store notifies them, see sql/outbox-notify.sql.
+	Publish func(events []Event) error
+	Audit   []AuditEntry
+	// IdempotencyKeys holds the records by actor/key.
+	IdempotencyKeys map[string]IdempotencyRecord
+}
+
+type ViewBatch struct {
+	Views map[int]int64
+	At    time.Time
 }
 
 func (s *StubPostStore) Connect() error {
@@ -18,8 +42,11 @@ func (s *StubPostStore) Disconnect() error {
 func (s *StubPostStore) GetAllPosts() ([]Post, error) {
 	values := make([]Post, 0, len(s.Posts))
 	for _, v := range s.Posts {
-		values = append(values, v)
+		if v.Status == StatusPublished {
+			values = append(values, v)
+		}
 	}
+	sort.Slice(values, func(i, j int) bool { return values[i].ID < values[j].ID })
 	return values, nil
 }
 
@@ -32,30 +59,320 @@ func (s *StubPostStore) GetPostByID(id int) (Post, error) {
 	return post, nil
 }
 
-func (s *StubPostStore) CreatePost(title, text string) error {
-	if title == "" || text == "" {
-		return ErrorPostIsNotCreated
+func (s *StubPostStore) CreatePost(post Post) (Post, error) {
+	if post.Title == "" || post.Content == "" {
+		return post, ErrorPostIsNotCreated
 	}
 	s.Counter++
-	s.Posts[s.Counter] = Post{s.Counter, title, text}
-	return nil
+	post.ID = s.Counter
+	s.Posts[post.ID] = post
+	s.recordEvent(EventPostCreated, post.ID, nil, &post)
+	return post, nil
 }
 
-func (s *StubPostStore) UpdatePost(id int, title, text string) error {
-	_, err := s.GetPostByID(id)
+func (s *StubPostStore) UpdatePost(post Post) error {
+	before, err := s.GetPostByID(post.ID)
 	if err != nil {
 		return err
 	}
-	s.Posts[id] = Post{id, title, text}
+	s.Posts[post.ID] = post
+	s.recordEvent(EventPostUpdated, post.ID, &before, &post)
 	return nil
 }
 
 func (s *StubPostStore) DeletePost(id int) error {
-	_, err := s.GetPostByID(id)
+	before, err := s.GetPostByID(id)
 	if err != nil {
 		return err
 	}
 	delete(s.Posts, id)
+	s.recordEvent(EventPostDeleted, id, &before, nil)
+	return nil
+}
+
+func (s *StubPostStore) PublishDuePosts(now time.Time, limit int) ([]Post, error) {
+	var published []Post
+	for id, post := range s.Posts {
+		if len(published) == limit {
+			break
+		}
+		if post.Status == StatusScheduled && post.PublishAt != nil && !post.PublishAt.After(now) {
+			before := post
+			post.Status = StatusPublished
+			s.Posts[id] = post
+			s.recordEvent(EventPostUpdated, id, &before, &post)
+			published = append(published, post)
+		}
+	}
+	return published, nil
+}
+
+func (s *StubPostStore) ExportPosts(fn func(post Post) error) error {
+	posts := make([]Post, 0, len(s.Posts))
+	for _, post := range s.Posts {
+		posts = append(posts, post)
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	for _, post := range posts {
+		if err := fn(post); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StubPostStore) CreateAttachment(a Attachment) (Attachment, error) {
+	a.ID = len(s.Attachments) + 1
+	a.CreatedAt = time.Now().UTC()
+	s.Attachments = append(s.Attachments, a)
+	return a, nil
+}
+
+func (s *StubPostStore) GetAttachments(postID int) ([]Attachment, error) {
+	attachments := []Attachment{}
+	for _, a := range s.Attachments {
+		if a.PostID == postID {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments, nil
+}
+
+func (s *StubPostStore) GetAttachmentsByHash(hash string) ([]Attachment, error) {
+	var attachments []Attachment
+	for _, a := range s.Attachments {
+		if a.Hash == hash {
+			attachments = append(attachments, a)
+		}
+	}
+	if len(attachments) == 0 {
+		return nil, ErrorAttachmentDoesNotExist
+	}
+	return attachments, nil
+}
+
+func (s *StubPostStore) React(postID int, user, emoji string) (map[string]int, error) {
+	if !ValidReaction(emoji) {
+		return nil, ErrorReactionInvalid
+	}
+	return s.setReaction(postID, user, emoji)
+}
+
+func (s *StubPostStore) Unreact(postID int, user string) (map[string]int, error) {
+	return s.setReaction(postID, user, "")
+}
+
+func (s *StubPostStore) setReaction(postID int, user, emoji string) (map[string]int, error) {
+	post, err := s.GetPostByID(postID)
+	if err != nil {
+		return nil, err
+	}
+	if s.Reactions == nil {
+		s.Reactions = make(map[int]map[string]string)
+	}
+	if s.Reactions[postID] == nil {
+		s.Reactions[postID] = make(map[string]string)
+	}
+	if emoji == "" {
+		delete(s.Reactions[postID], user)
+	} else {
+		s.Reactions[postID][user] = emoji
+	}
+
+	counts := make(map[string]int)
+	for _, e := range s.Reactions[postID] {
+		counts[e]++
+	}
+	post.Reactions = counts
+	if len(counts) == 0 {
+		post.Reactions = nil
+	}
+	s.Posts[postID] = post
+	return counts, nil
+}
+
+func (s *StubPostStore) AddPostViews(views map[int]int64, at time.Time) error {
+	for id, n := range views {
+		if post, ok := s.Posts[id]; ok {
+			post.Views += n
+			s.Posts[id] = post
+		}
+	}
+	s.ViewLog = append(s.ViewLog, ViewBatch{views, at})
+	return nil
+}
+
+func (s *StubPostStore) GetPopularPosts(since time.Time, limit int) ([]Post, error) {
+	recent := make(map[int]int64)
+	for _, batch := range s.ViewLog {
+		if batch.At.Before(since) {
+			continue
+		}
+		for id, n := range batch.Views {
+			recent[id] += n
+		}
+	}
+	var posts []Post
+	for id := range recent {
+		if post, ok := s.Posts[id]; ok && post.Status == StatusPublished {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if recent[posts[i].ID] != recent[posts[j].ID] {
+			return recent[posts[i].ID] > recent[posts[j].ID]
+		}
+		return posts[i].ID < posts[j].ID
+	})
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts, nil
+}
+
+func (s *StubPostStore) recordEvent(eventType string, postID int, before, after *Post) {
+	var tenant string
+	if after != nil {
+		tenant = after.TenantID
+	} else if before != nil {
+		tenant = before.TenantID
+	}
+	// The stub commits its events in order: their seq is their ID.
+	e := Event{
+		ID:        int64(len(s.Outbox) + 1),
+		Seq:       int64(len(s.Outbox) + 1),
+		Type:      eventType,
+		PostID:    postID,
+		Before:    before,
+		After:     after,
+		CreatedAt: time.Now().UTC(),
+		TenantID:  tenant,
+	}
+	s.Outbox = append(s.Outbox, e)
+	if s.Publish != nil {
+		s.Publish([]Event{e})
+	}
+}
+
+func (s *StubPostStore) DeliverEvents(limit int, deliver func(events []Event) error) (int, error) {
+	pending := s.Outbox[s.Delivered:]
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	if err := deliver(pending); err != nil {
+		return 0, err
+	}
+	s.Delivered += len(pending)
+	return len(pending), nil
+}
+
+func (s *StubPostStore) GetEvent(id int64) (Event, error) {
+	if id < 1 || id > int64(len(s.Outbox)) {
+		return Event{}, ErrorEventDoesNotExist
+	}
+	return s.Outbox[id-1], nil
+}
+
+func (s *StubPostStore) GetEventsSince(seq int64, limit int) ([]Event, error) {
+	if seq < 0 || seq > int64(len(s.Outbox)) {
+		return nil, nil
+	}
+	events := s.Outbox[seq:]
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// GetChangesSince derives the changes from the outbox: the seq of a post is
+// the ID of its latest event.
+func (s *StubPostStore) GetChangesSince(seq int64, limit int) ([]Change, error) {
+	latest := make(map[int]int64)
+	for _, e := range s.Outbox {
+		latest[e.PostID] = e.ID
+	}
+	var changes []Change
+	for _, e := range s.Outbox {
+		if e.ID <= seq || latest[e.PostID] != e.ID {
+			continue
+		}
+		change := Change{Seq: e.ID, Type: ChangeDelete, PostID: e.PostID}
+		if post, ok := s.Posts[e.PostID]; ok && post.Status == StatusPublished {
+			change.Type = ChangeUpsert
+			change.Post = &post
+		}
+		changes = append(changes, change)
+		if len(changes) == limit {
+			break
+		}
+	}
+	return changes, nil
+}
+
+func (s *StubPostStore) AppendAudit(entry AuditEntry, chain bool) (AuditEntry, error) {
+	entry.ID = int64(len(s.Audit) + 1)
+	if chain {
+		for _, e := range s.Audit {
+			if e.TenantID == entry.TenantID && e.Hash != "" {
+				entry.PrevHash = e.Hash
+			}
+		}
+		entry.Hash = entry.ChainHash()
+	}
+	s.Audit = append(s.Audit, entry)
+	return entry, nil
+}
+
+func (s *StubPostStore) GetAuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	for _, e := range s.Audit {
+		if len(entries) == filter.Limit {
+			break
+		}
+		if e.ID > filter.AfterID && matchAudit(filter, e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func matchAudit(filter AuditFilter, e AuditEntry) bool {
+	return (filter.PostID == 0 || e.PostID == filter.PostID) &&
+		(filter.Actor == "" || e.Actor == filter.Actor) &&
+		(filter.Action == "" || e.Action == filter.Action) &&
+		(filter.Since.IsZero() || !e.At.Before(filter.Since)) &&
+		(filter.Until.IsZero() || e.At.Before(filter.Until))
+}
+
+func (s *StubPostStore) ReserveIdempotencyKey(actor, key, fingerprint string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	if s.IdempotencyKeys == nil {
+		s.IdempotencyKeys = make(map[string]IdempotencyRecord)
+	}
+	now := time.Now()
+	if record, ok := s.IdempotencyKeys[actor+"/"+key]; ok && record.ExpiresAt.After(now) {
+		return record, false, nil
+	}
+	record := IdempotencyRecord{Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}
+	s.IdempotencyKeys[actor+"/"+key] = record
+	return record, true, nil
+}
+
+func (s *StubPostStore) CompleteIdempotencyKey(actor, key string, status int, body []byte) error {
+	if record, ok := s.IdempotencyKeys[actor+"/"+key]; ok {
+		record.Status = status
+		record.Body = body
+		s.IdempotencyKeys[actor+"/"+key] = record
+	}
+	return nil
+}
+
+func (s *StubPostStore) ReleaseIdempotencyKey(actor, key string) error {
+	if record, ok := s.IdempotencyKeys[actor+"/"+key]; ok && record.Status == 0 {
+		delete(s.IdempotencyKeys, actor+"/"+key)
+	}
 	return nil
 }
 
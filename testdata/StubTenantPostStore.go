@@ -0,0 +1,192 @@
+package testdata
+
+import (
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+// StubTenantPostStore is the view of a StubPostStore returned by ForTenant.
+// It hides the posts, attachments, events and changes of other tenants.
+type StubTenantPostStore struct {
+	*StubPostStore
+	Tenant string
+}
+
+func (s *StubPostStore) ForTenant(tenant string) TenantScoper {
+	return &StubTenantPostStore{s, tenant}
+}
+
+func (s *StubTenantPostStore) ForTenant(tenant string) TenantScoper {
+	return s.StubPostStore.ForTenant(tenant)
+}
+
+func (s *StubTenantPostStore) owns(postID int) bool {
+	post, ok := s.Posts[postID]
+	return ok && post.TenantID == s.Tenant
+}
+
+func (s *StubTenantPostStore) ownPosts(posts []Post) []Post {
+	own := make([]Post, 0, len(posts))
+	for _, post := range posts {
+		if post.TenantID == s.Tenant {
+			own = append(own, post)
+		}
+	}
+	return own
+}
+
+func (s *StubTenantPostStore) GetAllPosts() ([]Post, error) {
+	posts, err := s.StubPostStore.GetAllPosts()
+	return s.ownPosts(posts), err
+}
+
+func (s *StubTenantPostStore) GetPostByID(id int) (Post, error) {
+	if !s.owns(id) {
+		return Post{}, ErrorPostDoesNotExist
+	}
+	return s.StubPostStore.GetPostByID(id)
+}
+
+func (s *StubTenantPostStore) CreatePost(post Post) (Post, error) {
+	post.TenantID = s.Tenant
+	return s.StubPostStore.CreatePost(post)
+}
+
+func (s *StubTenantPostStore) UpdatePost(post Post) error {
+	if !s.owns(post.ID) {
+		return ErrorPostDoesNotExist
+	}
+	post.TenantID = s.Tenant
+	return s.StubPostStore.UpdatePost(post)
+}
+
+func (s *StubTenantPostStore) DeletePost(id int) error {
+	if !s.owns(id) {
+		return ErrorPostDoesNotExist
+	}
+	return s.StubPostStore.DeletePost(id)
+}
+
+func (s *StubTenantPostStore) ExportPosts(fn func(post Post) error) error {
+	return s.StubPostStore.ExportPosts(func(post Post) error {
+		if post.TenantID != s.Tenant {
+			return nil
+		}
+		return fn(post)
+	})
+}
+
+func (s *StubTenantPostStore) CreateAttachment(a Attachment) (Attachment, error) {
+	if !s.owns(a.PostID) {
+		return a, ErrorPostDoesNotExist
+	}
+	return s.StubPostStore.CreateAttachment(a)
+}
+
+func (s *StubTenantPostStore) GetAttachments(postID int) ([]Attachment, error) {
+	if !s.owns(postID) {
+		return []Attachment{}, nil
+	}
+	return s.StubPostStore.GetAttachments(postID)
+}
+
+func (s *StubTenantPostStore) GetAttachmentsByHash(hash string) ([]Attachment, error) {
+	var attachments []Attachment
+	for _, a := range s.Attachments {
+		if a.Hash == hash && s.owns(a.PostID) {
+			attachments = append(attachments, a)
+		}
+	}
+	if len(attachments) == 0 {
+		return nil, ErrorAttachmentDoesNotExist
+	}
+	return attachments, nil
+}
+
+func (s *StubTenantPostStore) React(postID int, user, emoji string) (map[string]int, error) {
+	if !s.owns(postID) {
+		return nil, ErrorPostDoesNotExist
+	}
+	return s.StubPostStore.React(postID, user, emoji)
+}
+
+func (s *StubTenantPostStore) Unreact(postID int, user string) (map[string]int, error) {
+	if !s.owns(postID) {
+		return nil, ErrorPostDoesNotExist
+	}
+	return s.StubPostStore.Unreact(postID, user)
+}
+
+func (s *StubTenantPostStore) GetPopularPosts(since time.Time, limit int) ([]Post, error) {
+	posts, err := s.StubPostStore.GetPopularPosts(since, len(s.Posts))
+	posts = s.ownPosts(posts)
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts, err
+}
+
+func (s *StubTenantPostStore) GetEvent(id int64) (Event, error) {
+	e, err := s.StubPostStore.GetEvent(id)
+	if err == nil && e.TenantID != s.Tenant {
+		return Event{}, ErrorEventDoesNotExist
+	}
+	return e, err
+}
+
+func (s *StubTenantPostStore) GetEventsSince(seq int64, limit int) ([]Event, error) {
+	all, err := s.StubPostStore.GetEventsSince(seq, len(s.Outbox))
+	var events []Event
+	for _, e := range all {
+		if e.TenantID == s.Tenant && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, err
+}
+
+func (s *StubTenantPostStore) GetChangesSince(seq int64, limit int) ([]Change, error) {
+	all, err := s.StubPostStore.GetChangesSince(seq, len(s.Outbox))
+	var changes []Change
+	for _, change := range all {
+		if s.Outbox[change.Seq-1].TenantID == s.Tenant && len(changes) < limit {
+			changes = append(changes, change)
+		}
+	}
+	return changes, err
+}
+
+func (s *StubTenantPostStore) AppendAudit(entry AuditEntry, chain bool) (AuditEntry, error) {
+	entry.TenantID = s.Tenant
+	return s.StubPostStore.AppendAudit(entry, chain)
+}
+
+func (s *StubTenantPostStore) GetAuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	limit := filter.Limit
+	filter.Limit = len(s.Audit)
+	all, err := s.StubPostStore.GetAuditEntries(filter)
+	var entries []AuditEntry
+	for _, e := range all {
+		if e.TenantID == s.Tenant && len(entries) < limit {
+			entries = append(entries, e)
+		}
+	}
+	return entries, err
+}
+
+// The idempotency keys of a tenant are prefixed with the tenant.
+func (s *StubTenantPostStore) ReserveIdempotencyKey(actor, key, fingerprint string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	record, reserved, err := s.StubPostStore.ReserveIdempotencyKey(actor, s.Tenant+"/"+key, fingerprint, ttl)
+	record.Key = key
+	return record, reserved, err
+}
+
+func (s *StubTenantPostStore) CompleteIdempotencyKey(actor, key string, status int, body []byte) error {
+	return s.StubPostStore.CompleteIdempotencyKey(actor, s.Tenant+"/"+key, status, body)
+}
+
+func (s *StubTenantPostStore) ReleaseIdempotencyKey(actor, key string) error {
+	return s.StubPostStore.ReleaseIdempotencyKey(actor, s.Tenant+"/"+key)
+}
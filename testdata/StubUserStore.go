@@ -0,0 +1,126 @@
+package testdata
+
+import (
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+type StubUserStore struct {
+	Users    []User
+	Sessions map[string]Session
+}
+
+func NewStubUserStore() *StubUserStore {
+	return &StubUserStore{Sessions: make(map[string]Session)}
+}
+
+func (s *StubUserStore) CreateUser(u User) (User, error) {
+	if u.TenantID == "" {
+		u.TenantID = DefaultTenant
+	}
+	if _, err := s.GetUserByEmail(u.TenantID, u.Email); err == nil {
+		return u, ErrorUserExists
+	}
+	u.ID = len(s.Users) + 1
+	u.CreatedAt = time.Now().UTC()
+	s.Users = append(s.Users, u)
+	return u, nil
+}
+
+func (s *StubUserStore) GetUserByEmail(tenant, email string) (User, error) {
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	for _, u := range s.Users {
+		if u.TenantID == tenant && u.Email == email {
+			return u, nil
+		}
+	}
+	return User{}, ErrorUserDoesNotExist
+}
+
+func (s *StubUserStore) GetUserByID(id int) (User, error) {
+	if id < 1 || id > len(s.Users) {
+		return User{}, ErrorUserDoesNotExist
+	}
+	return s.Users[id-1], nil
+}
+
+func (s *StubUserStore) SetUserRoles(id int, roles []string) error {
+	if id < 1 || id > len(s.Users) {
+		return ErrorUserDoesNotExist
+	}
+	s.Users[id-1].Roles = roles
+	return nil
+}
+
+func (s *StubUserStore) RecordLoginFailure(id, maxFailures int, lockFor time.Duration) (*time.Time, error) {
+	if id < 1 || id > len(s.Users) {
+		return nil, ErrorUserDoesNotExist
+	}
+	u := &s.Users[id-1]
+	u.FailedLogins++
+	if u.FailedLogins >= maxFailures {
+		u.FailedLogins = 0
+		until := time.Now().Add(lockFor)
+		u.LockedUntil = &until
+	}
+	return u.LockedUntil, nil
+}
+
+func (s *StubUserStore) ResetLoginFailures(id int) error {
+	if id < 1 || id > len(s.Users) {
+		return ErrorUserDoesNotExist
+	}
+	s.Users[id-1].FailedLogins = 0
+	s.Users[id-1].LockedUntil = nil
+	return nil
+}
+
+func (s *StubUserStore) VerifyUser(id int) error {
+	if id < 1 || id > len(s.Users) {
+		return ErrorUserDoesNotExist
+	}
+	if s.Users[id-1].VerifiedAt == nil {
+		now := time.Now().UTC()
+		s.Users[id-1].VerifiedAt = &now
+	}
+	return nil
+}
+
+func (s *StubUserStore) SetUserPassword(id int, hash string) error {
+	if id < 1 || id > len(s.Users) {
+		return ErrorUserDoesNotExist
+	}
+	s.Users[id-1].PasswordHash = hash
+	return s.ResetLoginFailures(id)
+}
+
+func (s *StubUserStore) CreateSession(session Session) error {
+	session.CreatedAt = time.Now().UTC()
+	s.Sessions[session.Hash] = session
+	return nil
+}
+
+func (s *StubUserStore) GetSession(hash string) (Session, error) {
+	session, ok := s.Sessions[hash]
+	if !ok || !time.Now().Before(session.ExpiresAt) {
+		return Session{}, ErrorSessionDoesNotExist
+	}
+	return session, nil
+}
+
+func (s *StubUserStore) DeleteSession(hash string) error {
+	delete(s.Sessions, hash)
+	return nil
+}
+
+func (s *StubUserStore) DeleteUserSessions(userID int) error {
+	for hash, session := range s.Sessions {
+		if session.UserID == userID {
+			delete(s.Sessions, hash)
+		}
+	}
+	return nil
+}
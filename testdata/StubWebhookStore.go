@@ -0,0 +1,166 @@
+package testdata
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+type StubWebhookStore struct {
+	mu         sync.Mutex
+	Webhooks   map[int]Webhook
+	Deliveries []Delivery
+	payloads   map[int64][]byte
+	attempts   map[int64]int
+	traces     map[int64]string
+}
+
+func NewStubWebhookStore() *StubWebhookStore {
+	return &StubWebhookStore{
+		Webhooks: make(map[int]Webhook),
+		payloads: make(map[int64][]byte),
+		attempts: make(map[int64]int),
+		traces:   make(map[int64]string),
+	}
+}
+
+func (s *StubWebhookStore) CreateWebhook(w Webhook) (Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.ID = len(s.Webhooks) + 1
+	w.CreatedAt = time.Now().UTC()
+	s.Webhooks[w.ID] = w
+	return w, nil
+}
+
+func (s *StubWebhookStore) GetWebhooks() ([]Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	webhooks := []Webhook{}
+	for _, w := range s.Webhooks {
+		webhooks = append(webhooks, w)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (s *StubWebhookStore) GetWebhook(id int) (Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.Webhooks[id]
+	if !ok {
+		return w, ErrorWebhookDoesNotExist
+	}
+	return w, nil
+}
+
+func (s *StubWebhookStore) UpdateWebhook(w Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Webhooks[w.ID]; !ok {
+		return ErrorWebhookDoesNotExist
+	}
+	s.Webhooks[w.ID] = w
+	return nil
+}
+
+func (s *StubWebhookStore) DeleteWebhook(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Webhooks[id]; !ok {
+		return ErrorWebhookDoesNotExist
+	}
+	delete(s.Webhooks, id)
+	return nil
+}
+
+func (s *StubWebhookStore) GetDeliveries(webhookID int, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deliveries := []Delivery{}
+	for i := len(s.Deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if s.Deliveries[i].WebhookID == webhookID {
+			deliveries = append(deliveries, s.Deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+func (s *StubWebhookStore) EnqueueDeliveries(events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		for _, w := range s.Webhooks {
+			if !w.Active || !w.Subscribes(e.Type) || s.queued(w.ID, e.ID) {
+				continue
+			}
+			now := time.Now().UTC()
+			d := Delivery{
+				ID:            int64(len(s.Deliveries) + 1),
+				WebhookID:     w.ID,
+				EventID:       e.ID,
+				EventType:     e.Type,
+				Status:        DeliveryPending,
+				NextAttemptAt: &now,
+				Attempts:      []DeliveryAttempt{},
+				CreatedAt:     now,
+			}
+			s.Deliveries = append(s.Deliveries, d)
+			s.payloads[d.ID] = payload
+			s.traces[d.ID] = e.Traceparent
+		}
+	}
+	return nil
+}
+
+func (s *StubWebhookStore) queued(webhookID int, eventID int64) bool {
+	for _, d := range s.Deliveries {
+		if d.WebhookID == webhookID && d.EventID == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *StubWebhookStore) ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]DeliveryJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []DeliveryJob
+	for i := range s.Deliveries {
+		d := &s.Deliveries[i]
+		w := s.Webhooks[d.WebhookID]
+		if len(jobs) == limit || d.Status != DeliveryPending || d.NextAttemptAt.After(now) || !w.Active {
+			continue
+		}
+		next := now.Add(lease)
+		d.NextAttemptAt = &next
+		s.attempts[d.ID]++
+		jobs = append(jobs, DeliveryJob{
+			Delivery:    *d,
+			Attempt:     s.attempts[d.ID],
+			URL:         w.URL,
+			Secret:      w.Secret,
+			Payload:     s.payloads[d.ID],
+			Traceparent: s.traces[d.ID],
+		})
+	}
+	return jobs, nil
+}
+
+func (s *StubWebhookStore) RecordAttempt(deliveryID int64, a DeliveryAttempt, status DeliveryStatus, next *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := &s.Deliveries[deliveryID-1]
+	d.Attempts = append(d.Attempts, a)
+	d.Status = status
+	d.NextAttemptAt = next
+	return nil
+}
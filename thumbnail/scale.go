@@ -0,0 +1,80 @@
+package thumbnail
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// fit returns the dimensions of a w x h image scaled down to fit into a
+// size x size square while keeping its aspect ratio. Images that already fit
+// keep their dimensions.
+func fit(w, h, size int) (int, int) {
+	if w <= size && h <= size {
+		return w, h
+	}
+	if w >= h {
+		return size, max(1, h*size/w)
+	}
+	return max(1, w*size/h), size
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// scale resizes src to w x h by averaging the source pixels covered by
+// every destination pixel. It is meant for shrinking, which is all
+// thumbnails need. The pixels are read from a premultiplied RGBA copy of
+// src, whose size is bounded by maxPixels.
+func scale(src image.Image, w, h int) *image.NRGBA {
+	rgba := toRGBA(src)
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	b := rgba.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*sh/h
+		y1 := b.Min.Y + max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*sw/w
+			x1 := b.Min.X + max((x+1)*sw/w, x*sw/w+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[rgba.PixOffset(x0, sy):rgba.PixOffset(x1, sy)]
+				for i := 0; i < len(row); i += 4 {
+					r += uint64(row[i])
+					g += uint64(row[i+1])
+					bl += uint64(row[i+2])
+					a += uint64(row[i+3])
+				}
+				n += uint64(x1 - x0)
+			}
+			if a == 0 {
+				continue
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r * 0xff / a),
+				G: uint8(g * 0xff / a),
+				B: uint8(bl * 0xff / a),
+				A: uint8(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// toRGBA returns src as an *image.RGBA, converting it if need be.
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok {
+		return rgba
+	}
+	b := src.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, src, b.Min, draw.Src)
+	return rgba
+}
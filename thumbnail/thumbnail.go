@@ -0,0 +1,234 @@
+package thumbnail
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/dsphub/go-simple-crud-sample/blob"
+)
+
+// ErrorThumbnailDoesNotExist is returned for thumbnails that were not
+// generated (yet) or sizes that are not configured.
+var ErrorThumbnailDoesNotExist = errors.New("could not find the thumbnail")
+
+// ErrorImageTooLarge is returned for images of more than maxPixels pixels,
+// which would take too much memory to decode, e.g. decompression bombs.
+var ErrorImageTooLarge = errors.New("image too large")
+
+// maxPixels bounds the size of the decoded images, that of a 24 megapixel
+// photo takes about 100 MB.
+const maxPixels = 24000000
+
+var formats = map[string]struct {
+	decodeConfig func(io.Reader) (image.Config, error)
+	decode       func(io.Reader) (image.Image, error)
+	encode       func(io.Writer, image.Image) error
+	contentType  string
+	ext          string
+}{
+	"image/jpeg": {jpeg.DecodeConfig, jpeg.Decode, encodeJPEG, "image/jpeg", ".jpg"},
+	"image/png":  {png.DecodeConfig, png.Decode, png.Encode, "image/png", ".png"},
+	"image/gif":  {gif.DecodeConfig, gif.Decode, png.Encode, "image/png", ".png"},
+}
+
+func encodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+}
+
+// Supported reports whether thumbnails can be generated for contentType.
+func Supported(contentType string) bool {
+	_, ok := formats[mediaType(contentType)]
+	return ok
+}
+
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return t
+}
+
+type job struct {
+	hash        string
+	contentType string
+}
+
+// Generator renders thumbnails of image blobs on a bounded pool of
+// workers. Thumbnails are stored under dir/<hash>/<size><ext>, so a blob
+// shared by several attachments is only rendered once.
+type Generator struct {
+	log   *log.Logger
+	blobs *blob.Store
+	dir   string
+	sizes map[int]bool
+
+	workers int
+	jobs    chan job
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+func NewGenerator(log *log.Logger, blobs *blob.Store, dir string, sizes []int, workers, queue int) (*Generator, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "can't create thumbnail directory")
+	}
+	g := &Generator{
+		log:     log,
+		blobs:   blobs,
+		dir:     dir,
+		sizes:   make(map[int]bool),
+		workers: workers,
+		jobs:    make(chan job, queue),
+		stop:    make(chan struct{}),
+	}
+	for _, size := range sizes {
+		g.sizes[size] = true
+	}
+	return g, nil
+}
+
+// Start launches the workers.
+func (g *Generator) Start() {
+	for i := 0; i < g.workers; i++ {
+		g.wg.Add(1)
+		go g.work()
+	}
+}
+
+// Stop waits for the workers to finish the thumbnails they are rendering.
+// Queued jobs are dropped; missing thumbnails are requested again on demand.
+func (g *Generator) Stop() {
+	g.once.Do(func() { close(g.stop) })
+	g.wg.Wait()
+}
+
+func (g *Generator) work() {
+	defer g.wg.Done()
+	for {
+		select {
+		case <-g.stop:
+			return
+		case j := <-g.jobs:
+			if err := g.Generate(j.hash, j.contentType); err != nil {
+				g.log.Printf("thumbnail: can't render %s: %v", j.hash, err)
+			}
+		}
+	}
+}
+
+// Enqueue schedules thumbnail generation for a blob without blocking. It
+// returns false when the content type is not supported or the queue is full.
+func (g *Generator) Enqueue(hash, contentType string) bool {
+	if !Supported(contentType) {
+		return false
+	}
+	select {
+	case g.jobs <- job{hash, contentType}:
+		return true
+	default:
+		g.log.Printf("thumbnail: queue is full, skip %s", hash)
+		return false
+	}
+}
+
+// Generate renders every configured thumbnail size of a blob that is not
+// rendered yet. Images of more than maxPixels pixels are rejected with
+// ErrorImageTooLarge before they are decoded.
+func (g *Generator) Generate(hash, contentType string) error {
+	format, ok := formats[mediaType(contentType)]
+	if !ok {
+		return errors.Errorf("unsupported content type %q", contentType)
+	}
+
+	var missing []int
+	for size := range g.sizes {
+		if _, err := os.Stat(g.path(hash, size, format.ext)); os.IsNotExist(err) {
+			missing = append(missing, size)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	content, err := g.blobs.Open(hash)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	config, err := format.decodeConfig(content)
+	if err != nil {
+		return errors.Wrap(err, "can't decode image")
+	}
+	if int64(config.Width)*int64(config.Height) > maxPixels {
+		return ErrorImageTooLarge
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "can't read image")
+	}
+	src, err := format.decode(content)
+	if err != nil {
+		return errors.Wrap(err, "can't decode image")
+	}
+
+	if err := os.MkdirAll(filepath.Join(g.dir, hash), 0755); err != nil {
+		return errors.Wrap(err, "can't create thumbnail directory")
+	}
+	for _, size := range missing {
+		w, h := fit(src.Bounds().Dx(), src.Bounds().Dy(), size)
+		if err := g.write(g.path(hash, size, format.ext), scale(src, w, h), format.encode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Generator) write(path string, img image.Image, encode func(io.Writer, image.Image) error) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".render-")
+	if err != nil {
+		return errors.Wrap(err, "can't create thumbnail")
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := encode(tmp, img); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "can't encode thumbnail")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "can't write thumbnail")
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open returns a rendered thumbnail of a blob along with its content type.
+func (g *Generator) Open(hash, contentType string, size int) (*os.File, string, error) {
+	format, ok := formats[mediaType(contentType)]
+	if !ok || !g.sizes[size] || !blob.ValidHash(hash) {
+		return nil, "", ErrorThumbnailDoesNotExist
+	}
+	f, err := os.Open(g.path(hash, size, format.ext))
+	if os.IsNotExist(err) {
+		return nil, "", ErrorThumbnailDoesNotExist
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return f, format.contentType, nil
+}
+
+func (g *Generator) path(hash string, size int, ext string) string {
+	return filepath.Join(g.dir, hash, fmt.Sprintf("%d%s", size, ext))
+}
@@ -0,0 +1,150 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/blob"
+	"github.com/stretchr/testify/assert"
+)
+
+var discard = log.New(ioutil.Discard, "", 0)
+
+func TestFit(t *testing.T) {
+	cases := []struct{ w, h, size, wantW, wantH int }{
+		{400, 200, 100, 100, 50},
+		{200, 400, 100, 50, 100},
+		{50, 20, 100, 50, 20},
+		{1000, 1, 100, 100, 1},
+	}
+	for _, c := range cases {
+		w, h := fit(c.w, c.h, c.size)
+		assert.Equal(t, []int{c.wantW, c.wantH}, []int{w, h}, "fit(%d, %d, %d)", c.w, c.h, c.size)
+	}
+}
+
+func TestScaleAveragesPixels(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 255})
+	src.SetNRGBA(1, 0, color.NRGBA{B: 255, A: 255})
+
+	dst := scale(src, 1, 1)
+
+	assert.Equal(t, color.NRGBA{R: 127, B: 127, A: 255}, dst.NRGBAAt(0, 0))
+}
+
+func TestScaleWeighsPixelsByAlpha(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 255})
+	src.SetNRGBA(1, 0, color.NRGBA{B: 255, A: 0})
+
+	dst := scale(src, 1, 1)
+
+	assert.Equal(t, color.NRGBA{R: 255, A: 127}, dst.NRGBAAt(0, 0), "transparent pixels have no color")
+}
+
+func TestGenerateAndOpen(t *testing.T) {
+	g, hash, cleanup := newTestGenerator(t, []int{16, 64})
+	defer cleanup()
+
+	assert.NoError(t, g.Generate(hash, "image/png"))
+
+	f, contentType, err := g.Open(hash, "image/png", 16)
+	if assert.NoError(t, err) {
+		defer f.Close()
+		img, err := png.Decode(f)
+		if assert.NoError(t, err) {
+			assert.Equal(t, image.Rect(0, 0, 16, 8), img.Bounds())
+		}
+		assert.Equal(t, "image/png", contentType)
+	}
+
+	_, _, err = g.Open(hash, "image/png", 32)
+	assert.Equal(t, ErrorThumbnailDoesNotExist, err, "unconfigured size")
+}
+
+// pngHeader returns the start of a PNG declaring a w x h image, enough for
+// png.DecodeConfig.
+func pngHeader(w, h uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], w)
+	binary.BigEndian.PutUint32(ihdr[8:], h)
+	ihdr[12], ihdr[13] = 8, 6 // 8 bit RGBA
+	b := append([]byte("\x89PNG\r\n\x1a\n"), 0, 0, 0, 13)
+	b = append(b, ihdr...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(ihdr))
+	return append(b, crc...)
+}
+
+func TestGenerateRejectsDecompressionBombs(t *testing.T) {
+	g, _, cleanup := newTestGenerator(t, []int{16})
+	defer cleanup()
+	hash, _, err := g.blobs.Put(bytes.NewReader(pngHeader(50000, 50000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ErrorImageTooLarge, g.Generate(hash, "image/png"))
+
+	_, _, err = g.Open(hash, "image/png", 16)
+	assert.Equal(t, ErrorThumbnailDoesNotExist, err)
+}
+
+func TestWorkersRenderEnqueuedBlobs(t *testing.T) {
+	g, hash, cleanup := newTestGenerator(t, []int{16})
+	defer cleanup()
+	g.Start()
+	defer g.Stop()
+
+	assert.True(t, g.Enqueue(hash, "image/png"))
+	assert.False(t, g.Enqueue(hash, "text/plain"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f, _, err := g.Open(hash, "image/png", 16)
+		if err == nil {
+			f.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("thumbnail was not rendered: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestGenerator(t *testing.T, sizes []int) (*Generator, string, func()) {
+	dir, err := ioutil.TempDir("", "thumbnails")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := blob.NewStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := new(bytes.Buffer)
+	png.Encode(content, image.NewNRGBA(image.Rect(0, 0, 128, 64)))
+	hash, _, err := blobs.Put(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := NewGenerator(discard, blobs, filepath.Join(dir, "thumbs"), sizes, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, hash, func() { os.RemoveAll(dir) }
+}
@@ -0,0 +1,50 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// WriterExporter writes every span as a line of JSON, e.g. to stdout or to
+// a file, so that traces can be looked at without a collector.
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+// NewFileExporter appends the spans to the file at path.
+func NewFileExporter(path string) (*WriterExporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't open trace file")
+	}
+	return NewWriterExporter(f), nil
+}
+
+func (e *WriterExporter) Export(span SpanData) {
+	line, err := json.Marshal(span)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w.Write(append(line, '\n'))
+}
+
+// Close closes the file the spans are written to, if any.
+func (e *WriterExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c, ok := e.w.(io.Closer); ok && e.w != os.Stdout && e.w != os.Stderr {
+		return c.Close()
+	}
+	return nil
+}
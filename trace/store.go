@@ -0,0 +1,83 @@
+package trace
+
+import (
+	"context"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+// InstrumentStore returns store recording a span for every operation, a
+// child of the span of ctx. Stores implementing SpanScoper add the
+// statements they run. It returns store itself when ctx has no span.
+func InstrumentStore(ctx context.Context, store PostStore) PostStore {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return store
+	}
+	return &tracedStore{PostStore: store, ctx: ctx, tracer: parent.tracer}
+}
+
+type tracedStore struct {
+	PostStore
+	ctx    context.Context
+	tracer *Tracer
+}
+
+// start starts the span of operation and returns the store recording its
+// statements in it.
+func (s *tracedStore) start(operation string) (*Span, PostStore) {
+	_, span := s.tracer.Start(s.ctx, "PostStore."+operation, Client)
+	span.SetAttribute("db.operation", operation)
+	if scoper, ok := s.PostStore.(SpanScoper); ok {
+		return span, scoper.ForSpan(span).(PostStore)
+	}
+	return span, s.PostStore
+}
+
+// endStoreSpan ends span, a missing post is no failure.
+func endStoreSpan(span *Span, err error) {
+	if err != ErrorPostDoesNotExist {
+		span.SetError(err)
+	}
+	span.End()
+}
+
+func (s *tracedStore) GetAllPosts() ([]Post, error) {
+	span, store := s.start("GetAllPosts")
+	posts, err := store.GetAllPosts()
+	endStoreSpan(span, err)
+	return posts, err
+}
+
+func (s *tracedStore) GetPostByID(id int) (Post, error) {
+	span, store := s.start("GetPostByID")
+	span.SetAttribute("post.id", id)
+	post, err := store.GetPostByID(id)
+	endStoreSpan(span, err)
+	return post, err
+}
+
+func (s *tracedStore) CreatePost(post Post) (Post, error) {
+	span, store := s.start("CreatePost")
+	post, err := store.CreatePost(post)
+	span.SetAttribute("post.id", post.ID)
+	endStoreSpan(span, err)
+	return post, err
+}
+
+func (s *tracedStore) UpdatePost(post Post) error {
+	span, store := s.start("UpdatePost")
+	span.SetAttribute("post.id", post.ID)
+	err := store.UpdatePost(post)
+	endStoreSpan(span, err)
+	return err
+}
+
+func (s *tracedStore) DeletePost(id int) error {
+	span, store := s.start("DeletePost")
+	span.SetAttribute("post.id", id)
+	err := store.DeletePost(id)
+	endStoreSpan(span, err)
+	return err
+}
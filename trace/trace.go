@@ -0,0 +1,266 @@
+// Package trace records spans, the timed operations of a request, and
+// propagates their context to other services with the W3C traceparent
+// header, see https://www.w3.org/TR/trace-context/.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Header carries the context of the span a request is made for.
+const Header = "traceparent"
+
+var ErrorTraceparentInvalid = errors.New("invalid traceparent")
+
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext identifies a span across services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Sampled tells whether the caller records the trace; spans of traces
+	// that are not sampled are not exported.
+	Sampled bool
+}
+
+func (c SpanContext) IsValid() bool {
+	return c.TraceID != (TraceID{}) && c.SpanID != (SpanID{})
+}
+
+// Traceparent returns the value of the traceparent header, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func (c SpanContext) Traceparent() string {
+	flags := "00"
+	if c.Sampled {
+		flags = "01"
+	}
+	return "00-" + c.TraceID.String() + "-" + c.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header. Versions after 00 are read
+// as 00, ignoring the fields they may add.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var c SpanContext
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return c, ErrorTraceparentInvalid
+	}
+	version, err := hex.DecodeString(s[:2])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(s) != 55) || (len(s) > 55 && s[55] != '-') {
+		return c, ErrorTraceparentInvalid
+	}
+	if !decodeLowerHex(c.TraceID[:], s[3:35]) || !decodeLowerHex(c.SpanID[:], s[36:52]) {
+		return c, ErrorTraceparentInvalid
+	}
+	var flags [1]byte
+	if !decodeLowerHex(flags[:], s[53:55]) || !c.IsValid() {
+		return c, ErrorTraceparentInvalid
+	}
+	c.Sampled = flags[0]&1 == 1
+	return c, nil
+}
+
+func decodeLowerHex(dst []byte, s string) bool {
+	for _, c := range s {
+		if ('0' > c || c > '9') && ('a' > c || c > 'f') {
+			return false
+		}
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+type Kind string
+
+const (
+	Server   Kind = "server"
+	Client   Kind = "client"
+	Internal Kind = "internal"
+)
+
+// Span is an operation of a trace. Its methods do nothing on a nil span, the
+// one started by a nil tracer, so that tracing can be optional.
+type Span struct {
+	tracer   *Tracer
+	context  SpanContext
+	parentID SpanID
+	name     string
+	kind     Kind
+	start    time.Time
+
+	mu         sync.Mutex
+	attributes map[string]interface{}
+	err        string
+	ended      bool
+}
+
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// Traceparent returns the traceparent header of the requests made for the
+// span, or "" for a nil span.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return s.context.Traceparent()
+}
+
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// SetError marks the span failed, unless err is nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End exports the span once.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	attributes := make(map[string]interface{}, len(s.attributes))
+	for key, value := range s.attributes {
+		attributes[key] = value
+	}
+	data := SpanData{
+		TraceID:    s.context.TraceID.String(),
+		SpanID:     s.context.SpanID.String(),
+		Name:       s.name,
+		Kind:       s.kind,
+		Service:    s.tracer.service,
+		Start:      s.start,
+		End:        s.tracer.now(),
+		Attributes: attributes,
+		Error:      s.err,
+	}
+	s.mu.Unlock()
+	if s.parentID != (SpanID{}) {
+		data.ParentID = s.parentID.String()
+	}
+	if s.context.Sampled {
+		s.tracer.exporter.Export(data)
+	}
+}
+
+// SpanData is an ended span, as exported.
+type SpanData struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+	Name       string                 `json:"name"`
+	Kind       Kind                   `json:"kind"`
+	Service    string                 `json:"service"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// Exporter sends the ended spans somewhere they can be looked at. Export is
+// called by the goroutine ending the span, it should not block for long.
+type Exporter interface {
+	Export(span SpanData)
+}
+
+// Tracer starts the spans of a service. A nil tracer starts nil spans.
+type Tracer struct {
+	service  string
+	exporter Exporter
+	now      func() time.Time
+}
+
+func NewTracer(service string, exporter Exporter) *Tracer {
+	return &Tracer{service: service, exporter: exporter, now: time.Now}
+}
+
+// Start starts a span which is a child of the span of ctx, or else of the
+// remote parent of ctx, or else the root of a new sampled trace. It returns
+// ctx with the span.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, name: name, kind: kind, start: t.now(), attributes: make(map[string]interface{})}
+	if parent := FromContext(ctx); parent != nil {
+		s.context = parent.context
+		s.parentID = parent.context.SpanID
+	} else if remote, ok := ctx.Value(remoteContextKey{}).(SpanContext); ok {
+		s.context = remote
+		s.parentID = remote.SpanID
+	} else {
+		s.context.TraceID = newTraceID()
+		s.context.Sampled = true
+	}
+	s.context.SpanID = newSpanID()
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+type spanContextKey struct{}
+type remoteContextKey struct{}
+
+// FromContext returns the span of ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanContextKey{}).(*Span)
+	return s
+}
+
+// WithRemoteParent returns a copy of ctx whose spans continue the trace of
+// parent, e.g. the one of an incoming request.
+func WithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteContextKey{}, parent)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		randomBytes(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		randomBytes(id[:])
+	}
+	return id
+}
+
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+}
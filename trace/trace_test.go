@@ -0,0 +1,172 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (r *recorder) Export(span SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func TestParseTraceparent(t *testing.T) {
+	t.Run("parse a sampled context", func(t *testing.T) {
+		header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		c, err := ParseTraceparent(header)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", c.TraceID.String())
+			assert.Equal(t, "00f067aa0ba902b7", c.SpanID.String())
+			assert.True(t, c.Sampled)
+			assert.Equal(t, header, c.Traceparent())
+		}
+	})
+
+	t.Run("read later versions as 00", func(t *testing.T) {
+		c, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+
+		assert.NoError(t, err)
+		assert.False(t, c.Sampled)
+	})
+
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0x",
+	} {
+		if _, err := ParseTraceparent(header); err != ErrorTraceparentInvalid {
+			t.Errorf("got error %v for %q, want it invalid", err, header)
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	t.Run("export children in the trace of their parent", func(t *testing.T) {
+		r := &recorder{}
+		tracer := NewTracer("test", r)
+
+		ctx, parent := tracer.Start(context.Background(), "request", Server)
+		_, child := tracer.Start(ctx, "query", Client)
+		child.SetAttribute("db.statement", "SELECT 1")
+		child.SetError(errors.New("boom"))
+		child.End()
+		parent.End()
+		parent.End()
+
+		if assert.Len(t, r.spans, 2) {
+			assert.Equal(t, r.spans[1].TraceID, r.spans[0].TraceID)
+			assert.Equal(t, r.spans[1].SpanID, r.spans[0].ParentID)
+			assert.Empty(t, r.spans[1].ParentID)
+			assert.Equal(t, "SELECT 1", r.spans[0].Attributes["db.statement"])
+			assert.Equal(t, "boom", r.spans[0].Error)
+			assert.Equal(t, "test", r.spans[1].Service)
+		}
+	})
+
+	t.Run("continue a remote trace", func(t *testing.T) {
+		r := &recorder{}
+		remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		_, span := NewTracer("test", r).Start(WithRemoteParent(context.Background(), remote), "request", Server)
+		span.End()
+
+		if assert.Len(t, r.spans, 1) {
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", r.spans[0].TraceID)
+			assert.Equal(t, "00f067aa0ba902b7", r.spans[0].ParentID)
+		}
+		assert.NotEqual(t, remote.SpanID, span.Context().SpanID)
+	})
+
+	t.Run("not export the traces the caller doesn't sample", func(t *testing.T) {
+		r := &recorder{}
+		remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+		_, span := NewTracer("test", r).Start(WithRemoteParent(context.Background(), remote), "request", Server)
+		span.End()
+
+		assert.Empty(t, r.spans)
+		assert.False(t, span.Context().Sampled)
+	})
+
+	t.Run("do nothing without a tracer", func(t *testing.T) {
+		var tracer *Tracer
+
+		ctx, span := tracer.Start(context.Background(), "request", Server)
+		span.SetAttribute("key", "value")
+		span.End()
+
+		assert.Nil(t, span)
+		assert.Nil(t, FromContext(ctx))
+		assert.Equal(t, "", span.Traceparent())
+	})
+}
+
+func TestWriterExporter(t *testing.T) {
+	var b bytes.Buffer
+	_, span := NewTracer("test", NewWriterExporter(&b)).Start(context.Background(), "request", Server)
+
+	span.End()
+
+	var got SpanData
+	if assert.NoError(t, json.Unmarshal(b.Bytes(), &got)) {
+		assert.Equal(t, "request", got.Name)
+		assert.Equal(t, Server, got.Kind)
+	}
+}
+
+// spanStore fails to create posts, has none to get and records a statement
+// in the span it is scoped to.
+type spanStore struct {
+	PostStore
+}
+
+func (s spanStore) GetPostByID(int) (Post, error) { return Post{}, ErrorPostDoesNotExist }
+
+func (s spanStore) CreatePost(post Post) (Post, error) {
+	return post, errors.New("connection refused")
+}
+
+func (s spanStore) ForSpan(span TraceSpan) SpanScoper {
+	span.SetAttribute("db.statement", "SELECT")
+	return s
+}
+
+func TestInstrumentStore(t *testing.T) {
+	r := &recorder{}
+	ctx, request := NewTracer("test", r).Start(context.Background(), "request", Server)
+	store := InstrumentStore(ctx, spanStore{})
+
+	store.GetPostByID(1)
+	store.CreatePost(Post{})
+	request.End()
+
+	if assert.Len(t, r.spans, 3) {
+		get, create := r.spans[0], r.spans[1]
+		assert.Equal(t, "PostStore.GetPostByID", get.Name)
+		assert.Equal(t, request.Context().SpanID.String(), get.ParentID)
+		assert.Equal(t, "SELECT", get.Attributes["db.statement"])
+		assert.Empty(t, get.Error, "a missing post is no failure")
+		assert.Equal(t, "connection refused", create.Error)
+	}
+	assert.Equal(t, store, InstrumentStore(context.Background(), store), "no span to record in")
+}
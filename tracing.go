@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/dsphub/go-simple-crud-sample/trace"
+)
+
+// WithTracing records a span for every request, continuing the trace of its
+// traceparent header if valid, and a child span for every operation of the
+// post store.
+func WithTracing(tracer *trace.Tracer) ServerOption {
+	return func(p *PostServer) {
+		p.tracer = tracer
+	}
+}
+
+// traced serves the requests in a server span named by their route, see
+// metricsRoute.
+func (p *PostServer) traced(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if parent, err := trace.ParseTraceparent(r.Header.Get(trace.Header)); err == nil {
+			ctx = trace.WithRemoteParent(ctx, parent)
+		}
+		route := metricsRoute(r)
+		ctx, span := p.tracer.Start(ctx, r.Method+" "+route, trace.Server)
+		defer span.End()
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", r.URL.Path)
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", sw.Status())
+		if sw.Status() >= http.StatusInternalServerError {
+			span.SetError(httpError(sw.Status()))
+		}
+	})
+}
+
+type httpError int
+
+func (e httpError) Error() string {
+	return http.StatusText(int(e))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dsphub/go-simple-crud-sample/logging"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+	"github.com/dsphub/go-simple-crud-sample/trace"
+)
+
+func TestTracing(t *testing.T) {
+	t.Run("continue the trace of the caller down to the store", func(t *testing.T) {
+		exporter := &RecordingExporter{}
+		var b bytes.Buffer
+		server := NewPostServer(logging.New(&b, logging.JSON, logging.Info), NewInMemoryPostStore(),
+			WithTracing(trace.NewTracer("test", exporter)))
+		request := newGetPostByIDRequest(1)
+		request.Header.Set(trace.Header, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		spans := exporter.Spans()
+		if len(spans) != 2 {
+			t.Fatalf("got spans %v, want the store and the request ones", spans)
+		}
+		store, served := spans[0], spans[1]
+		if served.Name != "GET /posts/{id}" || served.Kind != trace.Server {
+			t.Errorf("got request span %q of kind %q, want GET /posts/{id} of kind server", served.Name, served.Kind)
+		}
+		if served.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || served.ParentID != "00f067aa0ba902b7" {
+			t.Errorf("got request span in %s under %s, want it in the trace of the caller", served.TraceID, served.ParentID)
+		}
+		if served.Attributes["http.status_code"] != http.StatusOK {
+			t.Errorf("got status %v, want 200", served.Attributes["http.status_code"])
+		}
+		if store.Name != "PostStore.GetPostByID" || store.TraceID != served.TraceID || store.ParentID != served.SpanID {
+			t.Errorf("got store span %+v, want PostStore.GetPostByID under the request span", store)
+		}
+		records := logRecords(t, &b)
+		if got := records[0]["trace_id"]; got != served.TraceID {
+			t.Errorf("got access log trace ID %v, want %s", got, served.TraceID)
+		}
+	})
+
+	t.Run("start a trace for requests without a valid traceparent", func(t *testing.T) {
+		exporter := &RecordingExporter{}
+		server := NewPostServer(logging.Discard, NewInMemoryPostStore(),
+			WithTracing(trace.NewTracer("test", exporter)))
+		request := newGetPostByIDRequest(1)
+		request.Header.Set(trace.Header, "garbage")
+
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		spans := exporter.Spans()
+		if len(spans) != 2 || spans[1].ParentID != "" {
+			t.Errorf("got spans %v, want a new trace", spans)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// maxLineSize bounds a JSONL line, i.e. a post.
+const maxLineSize = 16 << 20
+
+var csvHeader = []string{"id", "title", "content", "status", "publish_at", "reactions", "views", "tenant_id", "author_id"}
+
+// Encoder writes posts one at a time. Flush must be called after the last
+// post.
+type Encoder interface {
+	Encode(post Post) error
+	Flush() error
+}
+
+func NewEncoder(w io.Writer, format string) (Encoder, error) {
+	switch format {
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlEncoder{bw, json.NewEncoder(bw)}, nil
+	case FormatCSV:
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	}
+	return nil, errors.Errorf("unknown format %q", format)
+}
+
+// NewDecoder returns a source of the posts read from r. The posts are
+// checked as they are read; errors tell the line or CSV record of the faulty
+// post.
+func NewDecoder(r io.Reader, format string) (PostSource, error) {
+	switch format {
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxLineSize)
+		return &jsonlDecoder{scanner: scanner}, nil
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = len(csvHeader)
+		return &csvDecoder{r: reader}, nil
+	}
+	return nil, errors.Errorf("unknown format %q", format)
+}
+
+type jsonlEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *jsonlEncoder) Encode(post Post) error {
+	return e.enc.Encode(post)
+}
+
+func (e *jsonlEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+type jsonlDecoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (d *jsonlDecoder) Next() (Post, error) {
+	for d.scanner.Scan() {
+		d.line++
+		if len(d.scanner.Bytes()) == 0 {
+			continue
+		}
+		var post Post
+		if err := json.Unmarshal(d.scanner.Bytes(), &post); err != nil {
+			return post, errors.Wrapf(err, "line %d", d.line)
+		}
+		return post, errors.Wrapf(checkPost(post), "line %d", d.line)
+	}
+	if err := d.scanner.Err(); err != nil {
+		return Post{}, errors.Wrapf(err, "line %d", d.line+1)
+	}
+	return Post{}, io.EOF
+}
+
+type csvEncoder struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (e *csvEncoder) Encode(post Post) error {
+	if !e.wroteHeader {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+		e.wroteHeader = true
+	}
+	var publishAt, reactions string
+	if post.PublishAt != nil {
+		publishAt = post.PublishAt.Format(time.RFC3339Nano)
+	}
+	if len(post.Reactions) > 0 {
+		raw, err := json.Marshal(post.Reactions)
+		if err != nil {
+			return err
+		}
+		reactions = string(raw)
+	}
+	return e.w.Write([]string{
+		strconv.Itoa(post.ID),
+		post.Title,
+		post.Content,
+		string(post.Status),
+		publishAt,
+		reactions,
+		strconv.FormatInt(post.Views, 10),
+		post.TenantID,
+		post.AuthorID,
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type csvDecoder struct {
+	r          *csv.Reader
+	readHeader bool
+	record     int
+}
+
+func (d *csvDecoder) Next() (Post, error) {
+	if !d.readHeader {
+		header, err := d.r.Read()
+		if err != nil {
+			return Post{}, err
+		}
+		for i, name := range csvHeader {
+			if header[i] != name {
+				return Post{}, errors.Errorf("line 1: unexpected column %q, want %q", header[i], name)
+			}
+		}
+		d.readHeader = true
+	}
+	record, err := d.r.Read()
+	if err != nil {
+		return Post{}, err
+	}
+	d.record++
+	post, err := parseCSVRecord(record)
+	if err == nil {
+		err = checkPost(post)
+	}
+	return post, errors.Wrapf(err, "record %d", d.record)
+}
+
+func parseCSVRecord(record []string) (Post, error) {
+	post := Post{Title: record[1], Content: record[2], Status: PostStatus(record[3]), TenantID: record[7], AuthorID: record[8]}
+	var err error
+	if record[0] != "" {
+		if post.ID, err = strconv.Atoi(record[0]); err != nil {
+			return post, errors.Wrap(err, "invalid id")
+		}
+	}
+	if record[4] != "" {
+		publishAt, err := time.Parse(time.RFC3339Nano, record[4])
+		if err != nil {
+			return post, errors.Wrap(err, "invalid publish_at")
+		}
+		post.PublishAt = &publishAt
+	}
+	if record[5] != "" {
+		if err := json.Unmarshal([]byte(record[5]), &post.Reactions); err != nil {
+			return post, errors.Wrap(err, "invalid reactions")
+		}
+	}
+	if record[6] != "" {
+		if post.Views, err = strconv.ParseInt(record[6], 10, 64); err != nil {
+			return post, errors.Wrap(err, "invalid views")
+		}
+	}
+	return post, nil
+}
+
+// checkPost rejects the posts the store would refuse, so that a dry run
+// catches them whatever the store.
+func checkPost(post Post) error {
+	switch {
+	case post.ID < 0:
+		return errors.Errorf("invalid id %d", post.ID)
+	case post.Title == "" || post.Content == "":
+		return ErrorPostIsNotCreated
+	case !post.Status.Valid():
+		return ErrorPostStatusInvalid
+	case post.Status == StatusScheduled && post.PublishAt == nil:
+		return ErrorPostStatusInvalid
+	case post.Views < 0:
+		return errors.Errorf("invalid views %d", post.Views)
+	case post.TenantID != "" && !ValidTenant(post.TenantID):
+		return ErrorTenantInvalid
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Package transfer moves posts between a PostStore and JSONL or CSV files.
+package transfer
+
+import (
+	"io"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+// Export writes the posts of store to w and returns how many were written.
+// Stores that are not a PostExporter only export their published posts.
+func Export(store PostStore, w io.Writer, format string) (int, error) {
+	enc, err := NewEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	write := func(post Post) error {
+		count++
+		return enc.Encode(post)
+	}
+
+	if exporter, ok := store.(PostExporter); ok {
+		err = exporter.ExportPosts(write)
+	} else {
+		err = exportAllPosts(store, write)
+	}
+	if err != nil {
+		return count, err
+	}
+	return count, enc.Flush()
+}
+
+func exportAllPosts(store PostStore, fn func(post Post) error) error {
+	posts, err := store.GetAllPosts()
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		if err := fn(post); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import loads the posts of source into store and returns how many were
+// imported. Stores that are not a PostImporter get the posts one by one, the
+// new ones under an ID of their choosing, and a dry run only checks the
+// input.
+func Import(store PostStore, source PostSource, options ImportOptions) (int, error) {
+	if importer, ok := store.(PostImporter); ok {
+		return importer.ImportPosts(source, options)
+	}
+
+	count := 0
+	for {
+		post, err := source.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		if !options.DryRun {
+			if err := importPost(store, post, options.Upsert); err != nil {
+				return count, err
+			}
+		}
+		count++
+	}
+}
+
+func importPost(store PostStore, post Post, upsert bool) error {
+	if post.ID != 0 {
+		_, err := store.GetPostByID(post.ID)
+		switch {
+		case err == nil && upsert:
+			return store.UpdatePost(post)
+		case err == nil:
+			return PostError("post already exists")
+		case err != ErrorPostDoesNotExist:
+			return err
+		}
+	}
+	_, err := store.CreatePost(post)
+	return err
+}
@@ -0,0 +1,109 @@
+package transfer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStore() *StubPostStore {
+	publishAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	return &StubPostStore{
+		Counter: 3,
+		Posts: map[int]Post{
+			1: {ID: 1, Title: "title", Content: "text, with a comma", Status: StatusPublished, Reactions: map[string]int{"👍": 2}, Views: 7, TenantID: "acme"},
+			2: {ID: 2, Title: "draft", Content: "multi\nline", Status: StatusDraft},
+			3: {ID: 3, Title: "later", Content: "text", Status: StatusScheduled, PublishAt: &publishAt},
+		},
+	}
+}
+
+func TestExportAndImportRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatJSONL, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			source := newStore()
+			var buf bytes.Buffer
+
+			exported, err := Export(source, &buf, format)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, exported, "drafts and scheduled posts are exported too")
+
+			target := &StubPostStore{Posts: map[int]Post{}}
+			decoder, err := NewDecoder(&buf, format)
+			assert.NoError(t, err)
+			imported, err := Import(target, decoder, ImportOptions{})
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, 3, imported)
+				assert.Equal(t, source.Posts, target.Posts)
+			}
+		})
+	}
+}
+
+func TestImportUpsertsExistingPosts(t *testing.T) {
+	store := newStore()
+	input := `{"id":1,"title":"changed","content":"text","status":"archived"}` + "\n" +
+		`{"id":9,"title":"new","content":"text","status":"draft"}` + "\n"
+	decoder, _ := NewDecoder(strings.NewReader(input), FormatJSONL)
+
+	count, err := Import(store, decoder, ImportOptions{Upsert: true})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, count)
+		assert.Equal(t, "changed", store.Posts[1].Title)
+		assert.Len(t, store.Posts, 4)
+	}
+}
+
+func TestImportFailsOnExistingPostsWithoutUpsert(t *testing.T) {
+	store := newStore()
+	decoder, _ := NewDecoder(strings.NewReader(`{"id":1,"title":"changed","content":"text","status":"archived"}`), FormatJSONL)
+
+	_, err := Import(store, decoder, ImportOptions{})
+
+	assert.Error(t, err)
+	assert.Equal(t, "title", store.Posts[1].Title)
+}
+
+func TestDryRunImportChecksEveryPost(t *testing.T) {
+	input := `{"title":"ok","content":"text","status":"published"}` + "\n\n" +
+		`{"title":"no status","content":"text"}` + "\n"
+
+	t.Run("reports the faulty line", func(t *testing.T) {
+		store := newStore()
+		decoder, _ := NewDecoder(strings.NewReader(input), FormatJSONL)
+
+		count, err := Import(store, decoder, ImportOptions{DryRun: true})
+
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "line 3")
+		}
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("writes nothing", func(t *testing.T) {
+		store := newStore()
+		decoder, _ := NewDecoder(strings.NewReader(input[:strings.Index(input, "\n")]), FormatJSONL)
+
+		count, err := Import(store, decoder, ImportOptions{DryRun: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Len(t, store.Posts, 3)
+	})
+}
+
+func TestCSVDecoderRejectsUnknownColumns(t *testing.T) {
+	decoder, _ := NewDecoder(strings.NewReader("id,name\n1,title\n"), FormatCSV)
+
+	_, err := decoder.Next()
+
+	assert.Error(t, err)
+}
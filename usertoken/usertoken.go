@@ -0,0 +1,118 @@
+// Package usertoken signs the tokens mailed to users, e.g. to reset their
+// password. Tokens are not stored: they are bound to the state of the user
+// that their use changes, hence are single-use.
+package usertoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+type Purpose string
+
+const (
+	// VerifyEmail tokens are spent by the verification of the email.
+	VerifyEmail Purpose = "verify-email"
+	// ResetPassword tokens are spent by the change of the password.
+	ResetPassword Purpose = "reset-password"
+)
+
+var (
+	ErrorTokenInvalid = errors.New("invalid token")
+	ErrorTokenExpired = errors.New("token is expired")
+)
+
+type Signer struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret, now: time.Now}
+}
+
+// Sign returns a token for purpose on user, valid for ttl.
+func (s *Signer) Sign(purpose Purpose, user User, ttl time.Duration) string {
+	payload := fmt.Sprintf("%s|%d|%d", purpose, user.ID, s.now().Add(ttl).Unix())
+	return encode([]byte(payload)) + "." + encode(s.mac(payload, purpose, user))
+}
+
+// Verify returns the user of a token for purpose, looked up with getUser.
+// It fails with ErrorTokenInvalid if the token was already used.
+func (s *Signer) Verify(token string, purpose Purpose, getUser func(id int) (User, error)) (User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return User{}, ErrorTokenInvalid
+	}
+	payload, err := decode(parts[0])
+	if err != nil {
+		return User{}, ErrorTokenInvalid
+	}
+	mac, err := decode(parts[1])
+	if err != nil {
+		return User{}, ErrorTokenInvalid
+	}
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 3 || Purpose(fields[0]) != purpose {
+		return User{}, ErrorTokenInvalid
+	}
+	id, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return User{}, ErrorTokenInvalid
+	}
+	expires, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return User{}, ErrorTokenInvalid
+	}
+	if !s.now().Before(time.Unix(expires, 0)) {
+		return User{}, ErrorTokenExpired
+	}
+
+	user, err := getUser(id)
+	if err == ErrorUserDoesNotExist {
+		return User{}, ErrorTokenInvalid
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if !hmac.Equal(mac, s.mac(string(payload), purpose, user)) {
+		return User{}, ErrorTokenInvalid
+	}
+	return user, nil
+}
+
+// mac signs the payload along with the state of the user that the use of
+// the token changes.
+func (s *Signer) mac(payload string, purpose Purpose, user User) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(payload))
+	h.Write([]byte{0})
+	h.Write([]byte(user.Email))
+	h.Write([]byte{0})
+	switch purpose {
+	case VerifyEmail:
+		if user.VerifiedAt != nil {
+			h.Write([]byte(user.VerifiedAt.UTC().Format(time.RFC3339Nano)))
+		}
+	case ResetPassword:
+		h.Write([]byte(user.PasswordHash))
+	}
+	return h.Sum(nil)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,65 @@
+package usertoken
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+)
+
+func TestTokens(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	signer := NewSigner([]byte("secret"))
+	signer.now = func() time.Time { return now }
+	user := User{ID: 3, Email: "alice@example.com", PasswordHash: "hash"}
+	getUser := func(id int) (User, error) {
+		if id != user.ID {
+			return User{}, ErrorUserDoesNotExist
+		}
+		return user, nil
+	}
+
+	verify := signer.Sign(VerifyEmail, user, time.Hour)
+	reset := signer.Sign(ResetPassword, user, time.Hour)
+
+	t.Run("accept a token for its purpose", func(t *testing.T) {
+		got, err := signer.Verify(verify, VerifyEmail, getUser)
+		assert.NoError(t, err)
+		assert.Equal(t, user, got)
+
+		_, err = signer.Verify(verify, ResetPassword, getUser)
+		assert.Equal(t, ErrorTokenInvalid, err)
+	})
+
+	t.Run("reject altered and foreign tokens", func(t *testing.T) {
+		other := NewSigner([]byte("other secret")).Sign(VerifyEmail, user, time.Hour)
+		for _, token := range []string{"", "a.b.c", "!.!", verify[:len(verify)-2], other} {
+			_, err := signer.Verify(token, VerifyEmail, getUser)
+			assert.Equal(t, ErrorTokenInvalid, err, token)
+		}
+	})
+
+	t.Run("reject expired tokens", func(t *testing.T) {
+		signer.now = func() time.Time { return now.Add(time.Hour) }
+		defer func() { signer.now = func() time.Time { return now } }()
+
+		_, err := signer.Verify(verify, VerifyEmail, getUser)
+		assert.Equal(t, ErrorTokenExpired, err)
+	})
+
+	t.Run("tokens are single-use", func(t *testing.T) {
+		verifiedAt := now
+		user.VerifiedAt = &verifiedAt
+		_, err := signer.Verify(verify, VerifyEmail, getUser)
+		assert.Equal(t, ErrorTokenInvalid, err)
+
+		_, err = signer.Verify(reset, ResetPassword, getUser)
+		assert.NoError(t, err, "verifying the email doesn't spend a reset token")
+
+		user.PasswordHash = "new hash"
+		_, err = signer.Verify(reset, ResetPassword, getUser)
+		assert.Equal(t, ErrorTokenInvalid, err)
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+	"github.com/dsphub/go-simple-crud-sample/views"
+)
+
+const (
+	defaultPopularWindow = 24 * time.Hour
+	maxPopularWindow     = 30 * 24 * time.Hour
+	defaultPopularLimit  = 10
+	maxPopularLimit      = 100
+)
+
+// WithViews counts post views and enables GET /posts/popular.
+func WithViews(counter *views.Counter, store ViewStore) ServerOption {
+	return func(p *PostServer) {
+		p.views = counter
+		p.viewStore = store
+	}
+}
+
+// getPopularPosts lists the posts viewed most within the window given as
+// a duration such as 24h, e.g. /posts/popular?window=24h&limit=10.
+func (p *PostServer) getPopularPosts(w http.ResponseWriter, r *http.Request) {
+	window := defaultPopularWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		var err error
+		window, err = time.ParseDuration(value)
+		if err != nil || window <= 0 || window > maxPopularWindow {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	limit := defaultPopularLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxPopularLimit {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	posts, err := p.viewStore.GetPopularPosts(time.Now().Add(-window), limit)
+	if err != nil {
+		p.log.Error("can't get popular posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if posts == nil {
+		posts = []Post{}
+	}
+	setResponseContentTypeAsJSON(w)
+	json.NewEncoder(w).Encode(posts)
+}
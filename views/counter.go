@@ -0,0 +1,108 @@
+package views
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/health"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+)
+
+// Counter buffers post views in memory and writes them behind to the store
+// in batches, so reading a post costs no database write.
+type Counter struct {
+	log      *log.Logger
+	store    ViewStore
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	pending map[int]int64
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	heartbeat health.Heartbeat
+}
+
+func NewCounter(log *log.Logger, store ViewStore, interval time.Duration) *Counter {
+	return &Counter{
+		log:      log,
+		store:    store,
+		interval: interval,
+		now:      time.Now,
+		pending:  make(map[int]int64),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Record counts one view of a post.
+func (c *Counter) Record(postID int) {
+	c.mu.Lock()
+	c.pending[postID]++
+	c.mu.Unlock()
+}
+
+// Start flushes the buffered views periodically in a background goroutine.
+func (c *Counter) Start() {
+	go c.run()
+}
+
+// Stop ends the periodic flushing and writes the remaining views.
+func (c *Counter) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+	<-c.done
+}
+
+// Heartbeat beats when the loop starts and after every flush, failed ones
+// included.
+func (c *Counter) Heartbeat() *health.Heartbeat {
+	return &c.heartbeat
+}
+
+func (c *Counter) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.heartbeat.Beat()
+	for {
+		select {
+		case <-c.stop:
+			if err := c.Flush(); err != nil {
+				c.log.Printf("views: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := c.Flush(); err != nil {
+				c.log.Printf("views: %v", err)
+			}
+			c.heartbeat.Beat()
+		}
+	}
+}
+
+// Flush writes the buffered views to the store. Views that could not be
+// written are kept for the next flush.
+func (c *Counter) Flush() error {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = make(map[int]int64)
+	c.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	err := c.store.AddPostViews(batch, c.now())
+	if err != nil {
+		c.mu.Lock()
+		for id, n := range batch {
+			c.pending[id] += n
+		}
+		c.mu.Unlock()
+	}
+	return err
+}
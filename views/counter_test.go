@@ -0,0 +1,72 @@
+package views
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var discard = log.New(ioutil.Discard, "", 0)
+
+type recordingViewStore struct {
+	mu      sync.Mutex
+	fail    bool
+	batches []map[int]int64
+}
+
+func (s *recordingViewStore) AddPostViews(views map[int]int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("database is down")
+	}
+	s.batches = append(s.batches, views)
+	return nil
+}
+
+func (s *recordingViewStore) GetPopularPosts(since time.Time, limit int) ([]Post, error) {
+	return nil, nil
+}
+
+func TestFlushWritesOneBatch(t *testing.T) {
+	store := &recordingViewStore{}
+	c := NewCounter(discard, store, time.Hour)
+	c.Record(1)
+	c.Record(1)
+	c.Record(2)
+
+	assert.NoError(t, c.Flush())
+	assert.NoError(t, c.Flush())
+
+	assert.Equal(t, []map[int]int64{{1: 2, 2: 1}}, store.batches)
+}
+
+func TestFailedFlushKeepsViews(t *testing.T) {
+	store := &recordingViewStore{fail: true}
+	c := NewCounter(discard, store, time.Hour)
+	c.Record(1)
+
+	assert.Error(t, c.Flush())
+	c.Record(1)
+	store.fail = false
+	assert.NoError(t, c.Flush())
+
+	assert.Equal(t, []map[int]int64{{1: 2}}, store.batches)
+}
+
+func TestStopFlushesPendingViews(t *testing.T) {
+	store := &recordingViewStore{}
+	c := NewCounter(discard, store, time.Hour)
+	c.Start()
+	c.Record(3)
+
+	c.Stop()
+
+	assert.Equal(t, []map[int]int64{{3: 1}}, store.batches)
+}
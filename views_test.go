@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/logging"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	"github.com/dsphub/go-simple-crud-sample/views"
+)
+
+func TestPostViews(t *testing.T) {
+	store := NewInMemoryPostStore()
+	store.CreatePost(Post{Title: "second", Content: "text", Status: StatusPublished})
+	counter := views.NewCounter(std.Std(logging.Info), store, time.Hour)
+	server := NewPostServer(std, store, WithViews(counter, store))
+
+	t.Run("reading a post writes nothing until the flush", func(t *testing.T) {
+		server.ServeHTTP(httptest.NewRecorder(), newGetPostByIDRequest(2))
+		server.ServeHTTP(httptest.NewRecorder(), newGetPostByIDRequest(2))
+		server.ServeHTTP(httptest.NewRecorder(), newGetPostByIDRequest(1))
+
+		if len(store.ViewLog) != 0 {
+			t.Fatalf("views were written on read: %v", store.ViewLog)
+		}
+		counter.Flush()
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newGetPostByIDRequest(2))
+		got := getSinglePostFromResponse(t, response.Body)
+		if got.Views != 2 {
+			t.Errorf("got %d views, want 2", got.Views)
+		}
+	})
+
+	t.Run("list the most viewed posts first", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/posts/popular?window=24h", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		got := getPostsFromResponse(t, response.Body)
+		assertStatus(t, response.Code, http.StatusOK)
+		if len(got) != 2 || got[0].ID != 2 || got[1].ID != 1 {
+			t.Errorf("unexpected popular posts %v", got)
+		}
+	})
+
+	t.Run("return 422 on invalid window", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/posts/popular?window=yesterday", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnprocessableEntity)
+	})
+}
@@ -0,0 +1,244 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dsphub/go-simple-crud-sample/health"
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+	"github.com/dsphub/go-simple-crud-sample/trace"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+const (
+	defaultBatchSize   = 20
+	defaultTimeout     = 10 * time.Second
+	defaultMaxAttempts = 8
+	defaultBackoff     = 30 * time.Second
+	maxBackoff         = 6 * time.Hour
+)
+
+// Sign returns the signature of a delivery sent at timestamp, i.e. the hex
+// encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret.
+// Receivers recompute it to check that the delivery is authentic and reject
+// old timestamps to prevent replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher delivers post change events to the registered webhooks. As an
+// outbox sink it only queues the deliveries; a background loop attempts the
+// due ones and retries failures with exponential backoff.
+type Dispatcher struct {
+	log         *log.Logger
+	store       WebhookStore
+	client      *http.Client
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	backoff     time.Duration
+	now         func() time.Time
+	tracer      *trace.Tracer
+	// checkIP vets the addresses deliveries connect to, see CheckTargetIP.
+	checkIP func(net.IP) error
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	heartbeat health.Heartbeat
+}
+
+func NewDispatcher(log *log.Logger, store WebhookStore, interval time.Duration) *Dispatcher {
+	d := &Dispatcher{
+		log:         log,
+		store:       store,
+		interval:    interval,
+		batchSize:   defaultBatchSize,
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultBackoff,
+		now:         time.Now,
+		checkIP:     CheckTargetIP,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	// No proxy is used: the address connected to must be the one checked.
+	dialer := &net.Dialer{
+		Timeout: defaultTimeout,
+		Control: controlTarget(func(ip net.IP) error { return d.checkIP(ip) }),
+	}
+	d.client = &http.Client{
+		Timeout:   defaultTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: defaultTimeout},
+	}
+	return d
+}
+
+// SetTracer records a span for every delivery attempt, in the trace of the
+// change delivered. Without a tracer the deliveries still carry the trace
+// context of the change.
+func (d *Dispatcher) SetTracer(tracer *trace.Tracer) {
+	d.tracer = tracer
+}
+
+// Send queues the deliveries of events, see outbox.Sink.
+func (d *Dispatcher) Send(events []Event) error {
+	return d.store.EnqueueDeliveries(events)
+}
+
+// Start runs the delivery loop in a background goroutine.
+func (d *Dispatcher) Start() {
+	go d.run()
+}
+
+// Stop signals the loop to exit and waits until the current pass is over.
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() { close(d.stop) })
+	<-d.done
+}
+
+// Heartbeat beats after every pass of the loop, failed ones included.
+func (d *Dispatcher) Heartbeat() *health.Heartbeat {
+	return &d.heartbeat
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Dispatch(); err != nil {
+			d.log.Printf("webhook: %v", err)
+		}
+		d.heartbeat.Beat()
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch attempts the deliveries due by now and returns how many were
+// attempted.
+func (d *Dispatcher) Dispatch() (int, error) {
+	total := 0
+	for {
+		// Claimed deliveries are leased for longer than their attempts
+		// can take.
+		lease := time.Duration(d.batchSize+1) * d.client.Timeout
+		jobs, err := d.store.ClaimDeliveries(d.now(), lease, d.batchSize)
+		if err != nil {
+			return total, err
+		}
+		for _, job := range jobs {
+			if err := d.attempt(job); err != nil {
+				return total, err
+			}
+		}
+		total += len(jobs)
+		if len(jobs) < d.batchSize {
+			return total, nil
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(job DeliveryJob) error {
+	ctx := context.Background()
+	if parent, err := trace.ParseTraceparent(job.Traceparent); err == nil {
+		ctx = trace.WithRemoteParent(ctx, parent)
+	}
+	_, span := d.tracer.Start(ctx, "webhook delivery", trace.Client)
+	span.SetAttribute("webhook.delivery_id", job.ID)
+	span.SetAttribute("webhook.attempt", job.Attempt)
+	span.SetAttribute("http.url", job.URL)
+	defer span.End()
+
+	started := d.now()
+	traceparent := job.Traceparent
+	if span != nil {
+		traceparent = span.Traceparent()
+	}
+	statusCode, err := d.post(job, started, traceparent)
+	span.SetAttribute("http.status_code", statusCode)
+	attempt := DeliveryAttempt{
+		Attempt:    job.Attempt,
+		StatusCode: statusCode,
+		Duration:   d.now().Sub(started),
+		CreatedAt:  started,
+	}
+	if err == nil && (statusCode < 200 || statusCode > 299) {
+		err = fmt.Errorf("unexpected status %d", statusCode)
+	}
+	if err == nil {
+		return d.store.RecordAttempt(job.ID, attempt, DeliveryDelivered, nil)
+	}
+
+	attempt.Error = err.Error()
+	span.SetError(err)
+	if job.Attempt >= d.maxAttempts {
+		d.log.Printf("webhook: giving up delivery %d to %s: %v", job.ID, job.URL, err)
+		return d.store.RecordAttempt(job.ID, attempt, DeliveryFailed, nil)
+	}
+	next := started.Add(d.retryDelay(job.Attempt))
+	return d.store.RecordAttempt(job.ID, attempt, DeliveryPending, &next)
+}
+
+// retryDelay doubles the backoff with every failed attempt.
+func (d *Dispatcher) retryDelay(attempt int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+func (d *Dispatcher) post(job DeliveryJob, now time.Time, traceparent string) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, job.URL, bytes.NewReader(job.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, job.EventType)
+	request.Header.Set(DeliveryHeader, strconv.FormatInt(job.ID, 10))
+	request.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(SignatureHeader, Sign(job.Secret, timestamp, job.Payload))
+	if traceparent != "" {
+		request.Header.Set(trace.Header, traceparent)
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64<<10))
+	return response.StatusCode, nil
+}
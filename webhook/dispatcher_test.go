@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+	"github.com/dsphub/go-simple-crud-sample/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+var discard = log.New(ioutil.Discard, "", 0)
+
+// newTestDispatcher returns a dispatcher that may deliver to the loopback
+// receivers of the tests.
+func newTestDispatcher(store *StubWebhookStore) *Dispatcher {
+	d := NewDispatcher(discard, store, time.Minute)
+	d.checkIP = func(net.IP) error { return nil }
+	return d
+}
+
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	received []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	body, _ := ioutil.ReadAll(req.Body)
+	r.received = append(r.received, req)
+	r.bodies = append(r.bodies, body)
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestDeliveryRefusedToForbiddenAddress(t *testing.T) {
+	recv := &receiver{}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+	store := NewStubWebhookStore()
+	store.CreateWebhook(Webhook{URL: server.URL, EventTypes: []string{EventPostCreated}, Active: true})
+	d := NewDispatcher(discard, store, time.Minute)
+
+	assert.NoError(t, d.Send([]Event{{ID: 1, Type: EventPostCreated, PostID: 1}}))
+	d.Dispatch()
+
+	assert.Empty(t, recv.received, "loopback receivers are never delivered to")
+	deliveries, _ := store.GetDeliveries(1, 1)
+	if assert.Len(t, deliveries, 1) && assert.Len(t, deliveries[0].Attempts, 1) {
+		assert.Contains(t, deliveries[0].Attempts[0].Error, ErrorTargetForbidden.Error())
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	for _, target := range []string{
+		"http://127.0.0.1/hook",
+		"http://localhost:8080/hook",
+		"http://10.1.2.3/hook",
+		"http://172.16.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://[fe80::1]/hook",
+		"http://[fd00::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		if err := CheckTarget(target); err != ErrorTargetForbidden {
+			t.Errorf("got error %v for %s, want it forbidden", err, target)
+		}
+	}
+	assert.NoError(t, CheckTarget("https://203.0.113.10/hook"))
+}
+
+func TestDeliverySignedWithSecret(t *testing.T) {
+	recv := &receiver{}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+	store := NewStubWebhookStore()
+	store.CreateWebhook(Webhook{URL: server.URL, Secret: "s3cr3t", EventTypes: []string{EventPostCreated}, Active: true})
+	d := newTestDispatcher(store)
+
+	assert.NoError(t, d.Send([]Event{{ID: 1, Type: EventPostCreated, PostID: 1}, {ID: 2, Type: EventPostDeleted, PostID: 1}}))
+	n, err := d.Dispatch()
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, n, "only subscribed events are delivered")
+	}
+	if assert.Len(t, recv.received, 1) {
+		req := recv.received[0]
+		timestamp, _ := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+		assert.Equal(t, Sign("s3cr3t", timestamp, recv.bodies[0]), req.Header.Get(SignatureHeader))
+		assert.Equal(t, EventPostCreated, req.Header.Get(EventHeader))
+	}
+	deliveries, _ := store.GetDeliveries(1, 10)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, DeliveryDelivered, deliveries[0].Status)
+		assert.Len(t, deliveries[0].Attempts, 1)
+	}
+}
+
+func TestFailedDeliveriesAreRetriedWithBackoff(t *testing.T) {
+	recv := &receiver{failures: 2}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+	store := NewStubWebhookStore()
+	store.CreateWebhook(Webhook{URL: server.URL, Secret: "s3cr3t", EventTypes: []string{EventPostCreated}, Active: true})
+	now := time.Now().Add(time.Second)
+	d := newTestDispatcher(store)
+	d.now = func() time.Time { return now }
+	d.Send([]Event{{ID: 1, Type: EventPostCreated, PostID: 1}})
+
+	d.Dispatch()
+	deliveries, _ := store.GetDeliveries(1, 10)
+	assert.WithinDuration(t, now.Add(d.backoff), *deliveries[0].NextAttemptAt, 0)
+
+	n, _ := d.Dispatch()
+	assert.Equal(t, 0, n, "retried before the backoff passed")
+
+	now = now.Add(d.backoff)
+	d.Dispatch()
+	deliveries, _ = store.GetDeliveries(1, 10)
+	assert.WithinDuration(t, now.Add(2*d.backoff), *deliveries[0].NextAttemptAt, 0)
+
+	now = now.Add(2 * d.backoff)
+	d.Dispatch()
+	deliveries, _ = store.GetDeliveries(1, 10)
+	assert.Equal(t, DeliveryDelivered, deliveries[0].Status)
+	if assert.Len(t, deliveries[0].Attempts, 3) {
+		assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].Attempts[0].StatusCode)
+		assert.NotEmpty(t, deliveries[0].Attempts[0].Error)
+		assert.Equal(t, http.StatusNoContent, deliveries[0].Attempts[2].StatusCode)
+	}
+}
+
+func TestDeliveryGivesUpAfterMaxAttempts(t *testing.T) {
+	recv := &receiver{failures: 10}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+	store := NewStubWebhookStore()
+	store.CreateWebhook(Webhook{URL: server.URL, Secret: "s3cr3t", EventTypes: []string{EventPostCreated}, Active: true})
+	now := time.Now().Add(time.Second)
+	d := newTestDispatcher(store)
+	d.now = func() time.Time { return now }
+	d.maxAttempts = 2
+	d.Send([]Event{{ID: 1, Type: EventPostCreated, PostID: 1}})
+
+	d.Dispatch()
+	now = now.Add(time.Hour)
+	d.Dispatch()
+
+	deliveries, _ := store.GetDeliveries(1, 10)
+	assert.Equal(t, DeliveryFailed, deliveries[0].Status)
+	assert.Len(t, deliveries[0].Attempts, 2)
+}
+
+func TestDeliveryContinuesTheTraceOfTheEvent(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	event := Event{ID: 1, Type: EventPostCreated, PostID: 1, Traceparent: traceparent}
+
+	t.Run("forward the trace context without a tracer", func(t *testing.T) {
+		recv := &receiver{}
+		server := httptest.NewServer(recv)
+		defer server.Close()
+		store := NewStubWebhookStore()
+		store.CreateWebhook(Webhook{URL: server.URL, EventTypes: []string{EventPostCreated}, Active: true})
+		d := newTestDispatcher(store)
+
+		assert.NoError(t, d.Send([]Event{event}))
+		d.Dispatch()
+
+		if assert.Len(t, recv.received, 1) {
+			assert.Equal(t, traceparent, recv.received[0].Header.Get(trace.Header))
+		}
+	})
+
+	t.Run("send the context of the delivery span", func(t *testing.T) {
+		recv := &receiver{}
+		server := httptest.NewServer(recv)
+		defer server.Close()
+		store := NewStubWebhookStore()
+		store.CreateWebhook(Webhook{URL: server.URL, EventTypes: []string{EventPostCreated}, Active: true})
+		exporter := &RecordingExporter{}
+		d := newTestDispatcher(store)
+		d.SetTracer(trace.NewTracer("test", exporter))
+
+		assert.NoError(t, d.Send([]Event{event}))
+		d.Dispatch()
+
+		spans := exporter.Spans()
+		if assert.Len(t, spans, 1) && assert.Len(t, recv.received, 1) {
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].TraceID)
+			assert.Equal(t, "00f067aa0ba902b7", spans[0].ParentID)
+			assert.Equal(t, http.StatusNoContent, spans[0].Attributes["http.status_code"])
+			assert.Equal(t, "00-"+spans[0].TraceID+"-"+spans[0].SpanID+"-01", recv.received[0].Header.Get(trace.Header))
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"net"
+	"net/url"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+var ErrorTargetForbidden = errors.New("webhook target address is forbidden")
+
+// forbiddenNetworks are the networks deliveries are never sent to: the ones
+// of the host itself, of its links and of private networks, which webhooks
+// could otherwise be used to reach from within.
+var forbiddenNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// CheckTargetIP returns ErrorTargetForbidden if ip is in one of the
+// forbidden networks. IPv4-mapped IPv6 addresses are checked as IPv4 ones.
+func CheckTargetIP(ip net.IP) error {
+	if ip == nil {
+		return ErrorTargetForbidden
+	}
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return ErrorTargetForbidden
+		}
+	}
+	return nil
+}
+
+// CheckTarget resolves the host of a webhook URL and returns
+// ErrorTargetForbidden if any of its addresses is forbidden. The addresses
+// may change once checked: deliveries check the one they connect to again.
+func CheckTarget(rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	ips, err := net.LookupIP(target.Hostname())
+	if err != nil {
+		return errors.Wrap(err, "can't resolve webhook target")
+	}
+	for _, ip := range ips {
+		if err := CheckTargetIP(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// controlTarget checks the address a delivery is about to connect to, after
+// its host was resolved and whatever redirects it followed.
+func controlTarget(check func(net.IP) error) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		return check(net.ParseIP(host))
+	}
+}
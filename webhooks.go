@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/store"
+	"github.com/dsphub/go-simple-crud-sample/webhook"
+)
+
+const (
+	defaultDeliveriesLimit = 20
+	maxDeliveriesLimit     = 100
+	webhookSecretSize      = 32
+)
+
+var webhookEventTypes = []string{EventPostCreated, EventPostUpdated, EventPostDeleted}
+
+// WithWebhooks enables the management of outgoing webhooks under /webhooks/,
+// for admins only: it is not served unless the server authenticates its
+// callers. Webhooks can't target loopback, link-local or private addresses.
+func WithWebhooks(webhooks WebhookStore) ServerOption {
+	return func(p *PostServer) {
+		p.webhooks = webhooks
+		p.checkWebhookTarget = webhook.CheckTarget
+	}
+}
+
+func (p *PostServer) webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(r.URL.Path[len("/webhooks/"):], "/")
+	switch {
+	case path[0] == "" && len(path) == 1:
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		p.getWebhooks(w)
+	case path[0] == "new" && len(path) == 1:
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		r.ParseForm()
+		p.createWebhook(w, r.Form)
+	default:
+		id, err := strconv.Atoi(path[0])
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		switch {
+		case len(path) == 1:
+			p.webhookHandler(w, r, id)
+		case len(path) == 2 && path[1] == "deliveries" && r.Method == http.MethodGet:
+			p.getDeliveries(w, r, id)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func (p *PostServer) webhookHandler(w http.ResponseWriter, r *http.Request, id int) {
+	switch r.Method {
+	case http.MethodGet:
+		p.getWebhook(w, id)
+	case http.MethodPut:
+		r.ParseForm()
+		p.updateWebhook(w, id, r.Form)
+	case http.MethodDelete:
+		p.deleteWebhook(w, id)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (p *PostServer) getWebhooks(w http.ResponseWriter) {
+	webhooks, err := p.webhooks.GetWebhooks()
+	if err != nil {
+		p.log.Error("can't get webhooks", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	setResponseContentTypeAsJSON(w)
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+func (p *PostServer) getWebhook(w http.ResponseWriter, id int) {
+	webhook, err := p.webhooks.GetWebhook(id)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	webhook.Secret = ""
+	setResponseContentTypeAsJSON(w)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// createWebhook registers a webhook from the url, events and optional secret
+// form fields. A random secret is generated when none is given; this is the
+// only response the secret is shown in.
+func (p *PostServer) createWebhook(w http.ResponseWriter, form url.Values) {
+	webhook := Webhook{Secret: form.Get("secret"), Active: true}
+	if err := p.applyWebhookForm(&webhook, form); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	if webhook.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			p.log.Error("can't generate webhook secret", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		webhook.Secret = secret
+	}
+
+	webhook, err := p.webhooks.CreateWebhook(webhook)
+	if err != nil {
+		p.log.Error("can't create webhook", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	setResponseContentTypeAsJSON(w)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (p *PostServer) updateWebhook(w http.ResponseWriter, id int, form url.Values) {
+	webhook, err := p.webhooks.GetWebhook(id)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	if secret := form.Get("secret"); secret != "" {
+		webhook.Secret = secret
+	}
+	if err := p.applyWebhookForm(&webhook, form); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	if err := p.webhooks.UpdateWebhook(webhook); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *PostServer) deleteWebhook(w http.ResponseWriter, id int) {
+	if err := p.webhooks.DeleteWebhook(id); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getDeliveries lists the latest deliveries of a webhook along with their
+// attempts, e.g. /webhooks/1/deliveries?limit=20.
+func (p *PostServer) getDeliveries(w http.ResponseWriter, r *http.Request, id int) {
+	limit := defaultDeliveriesLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxDeliveriesLimit {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	if _, err := p.webhooks.GetWebhook(id); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	deliveries, err := p.webhooks.GetDeliveries(id, limit)
+	if err != nil {
+		p.log.Error("can't get deliveries", "webhook", id, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	setResponseContentTypeAsJSON(w)
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrorWebhookDoesNotExist:
+		w.WriteHeader(http.StatusNotFound)
+	case ErrorWebhookInvalid:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// applyWebhookForm overlays the url, events and active form fields on
+// webhook. Events are given as repeated fields or a comma separated list.
+func (p *PostServer) applyWebhookForm(webhook *Webhook, form url.Values) error {
+	if _, ok := form["url"]; ok {
+		webhook.URL = form.Get("url")
+	}
+	if values, ok := form["events"]; ok {
+		webhook.EventTypes = nil
+		for _, value := range values {
+			for _, eventType := range strings.Split(value, ",") {
+				if eventType = strings.TrimSpace(eventType); eventType != "" {
+					webhook.EventTypes = append(webhook.EventTypes, eventType)
+				}
+			}
+		}
+	}
+	if value := form.Get("active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			return ErrorWebhookInvalid
+		}
+		webhook.Active = active
+	}
+
+	target, err := url.Parse(webhook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return ErrorWebhookInvalid
+	}
+	if len(webhook.EventTypes) == 0 {
+		return ErrorWebhookInvalid
+	}
+	for _, eventType := range webhook.EventTypes {
+		if !validWebhookEventType(eventType) {
+			return ErrorWebhookInvalid
+		}
+	}
+	if err := p.checkWebhookTarget(webhook.URL); err != nil {
+		p.log.Warn("webhook target refused", "url", webhook.URL, "err", err)
+		return ErrorWebhookInvalid
+	}
+	return nil
+}
+
+func validWebhookEventType(eventType string) bool {
+	for _, t := range webhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, webhookSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	. "github.com/dsphub/go-simple-crud-sample/model"
+	. "github.com/dsphub/go-simple-crud-sample/testdata"
+)
+
+func TestWebhooks(t *testing.T) {
+	store := NewStubWebhookStore()
+	keys := &StubAPIKeyStore{}
+	admin := keys.AddKey(DefaultTenant, ScopeAdmin)
+	writer := keys.AddKey(DefaultTenant, ScopePostsRead, ScopePostsWrite)
+	server := NewPostServer(std, EmptyInMemoryPostStore(), WithWebhooks(store), WithAPIKeys(keys))
+	newWebhookRequest := func(method, path string, form url.Values) *http.Request {
+		request := newWebhookFormRequest(method, path, form)
+		request.Header.Set(apiKeyHeader, admin)
+		return request
+	}
+
+	t.Run("return 403 to non admins", func(t *testing.T) {
+		request := newWebhookFormRequest(http.MethodGet, "/webhooks/", nil)
+		request.Header.Set(apiKeyHeader, writer)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusForbidden)
+	})
+
+	t.Run("create a webhook with a generated secret", func(t *testing.T) {
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newWebhookRequest(http.MethodPost, "/webhooks/new", url.Values{
+			"url":    {"https://203.0.113.10/hook"},
+			"events": {"post.created,post.deleted"},
+		}))
+
+		assertStatus(t, response.Code, http.StatusCreated)
+		var got Webhook
+		json.NewDecoder(response.Body).Decode(&got)
+		if len(got.Secret) != 2*webhookSecretSize {
+			t.Errorf("got secret %q", got.Secret)
+		}
+		if len(got.EventTypes) != 2 || !got.Active {
+			t.Errorf("got webhook %+v", got)
+		}
+	})
+
+	t.Run("the secret is never listed", func(t *testing.T) {
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newWebhookRequest(http.MethodGet, "/webhooks/", nil))
+
+		assertStatus(t, response.Code, http.StatusOK)
+		if strings.Contains(response.Body.String(), store.Webhooks[1].Secret) {
+			t.Errorf("secret is listed: %s", response.Body.String())
+		}
+	})
+
+	t.Run("deactivate the webhook", func(t *testing.T) {
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newWebhookRequest(http.MethodPut, "/webhooks/1", url.Values{"active": {"false"}}))
+
+		assertStatus(t, response.Code, http.StatusNoContent)
+		if store.Webhooks[1].Active {
+			t.Error("webhook is still active")
+		}
+	})
+
+	t.Run("list the deliveries", func(t *testing.T) {
+		store.Webhooks[1] = Webhook{ID: 1, URL: "https://203.0.113.10/hook", EventTypes: []string{EventPostCreated}, Active: true}
+		store.EnqueueDeliveries([]Event{{ID: 7, Type: EventPostCreated, PostID: 1}})
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newWebhookRequest(http.MethodGet, "/webhooks/1/deliveries", nil))
+
+		assertStatus(t, response.Code, http.StatusOK)
+		var got []Delivery
+		json.NewDecoder(response.Body).Decode(&got)
+		if len(got) != 1 || got[0].EventID != 7 || got[0].Status != DeliveryPending {
+			t.Errorf("got deliveries %+v", got)
+		}
+	})
+
+	for name, form := range map[string]url.Values{
+		"relative url":  {"url": {"/hook"}, "events": {EventPostCreated}},
+		"other scheme":  {"url": {"ftp://example.com"}, "events": {EventPostCreated}},
+		"no events":     {"url": {"https://example.com"}},
+		"unknown event": {"url": {"https://example.com"}, "events": {"post.liked"}},
+		"loopback url":  {"url": {"http://127.0.0.1:8080/hook"}, "events": {EventPostCreated}},
+		"private url":   {"url": {"http://10.0.0.5/hook"}, "events": {EventPostCreated}},
+		"metadata url":  {"url": {"http://169.254.169.254/latest"}, "events": {EventPostCreated}},
+	} {
+		t.Run(fmt.Sprintf("return 422 on %s", name), func(t *testing.T) {
+			response := httptest.NewRecorder()
+
+			server.ServeHTTP(response, newWebhookRequest(http.MethodPost, "/webhooks/new", form))
+
+			assertStatus(t, response.Code, http.StatusUnprocessableEntity)
+		})
+	}
+
+	t.Run("delete the webhook", func(t *testing.T) {
+		server.ServeHTTP(httptest.NewRecorder(), newWebhookRequest(http.MethodDelete, "/webhooks/1", nil))
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, newWebhookRequest(http.MethodGet, "/webhooks/1", nil))
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+	})
+}
+
+func TestWebhooksNotServedWithoutAuthentication(t *testing.T) {
+	server := NewPostServer(std, EmptyInMemoryPostStore(), WithWebhooks(NewStubWebhookStore()))
+	response := httptest.NewRecorder()
+
+	server.ServeHTTP(response, newWebhookFormRequest(http.MethodGet, "/webhooks/", nil))
+
+	assertStatus(t, response.Code, http.StatusNotFound)
+}
+
+func newWebhookFormRequest(method, path string, form url.Values) *http.Request {
+	request, _ := http.NewRequest(method, path, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return request
+}